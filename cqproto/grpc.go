@@ -83,11 +83,15 @@ func (g GRPCClient) FetchResources(ctx context.Context, request *FetchResourcesR
 	}
 
 	res, err := g.client.FetchResources(ctx, &internal.FetchResources_Request{
-		Resources:             request.Resources,
-		ParallelFetchingLimit: request.ParallelFetchingLimit,
-		MaxGoroutines:         request.MaxGoroutines,
-		Timeout:               int64(request.Timeout.Seconds()),
-		Metadata:              md,
+		Resources:                 request.Resources,
+		ParallelFetchingLimit:     request.ParallelFetchingLimit,
+		MaxGoroutines:             request.MaxGoroutines,
+		Timeout:                   int64(request.Timeout.Seconds()),
+		Metadata:                  md,
+		StopOnError:               request.StopOnError,
+		MaxConnectionsPerResource: int64(request.MaxConnectionsPerResource),
+		MaxTotalRows:              int64(request.MaxTotalRows),
+		MaxItemsPerResource:       int64(request.MaxItemsPerResource),
 	})
 	if err != nil {
 		return nil, err
@@ -189,11 +193,15 @@ func (g *GRPCServer) FetchResources(request *internal.FetchResources_Request, se
 	return g.Impl.FetchResources(
 		server.Context(),
 		&FetchResourcesRequest{
-			Resources:             request.GetResources(),
-			ParallelFetchingLimit: request.ParallelFetchingLimit,
-			MaxGoroutines:         request.MaxGoroutines,
-			Metadata:              md,
-			Timeout:               time.Duration(request.GetTimeout()) * time.Second,
+			Resources:                 request.GetResources(),
+			ParallelFetchingLimit:     request.ParallelFetchingLimit,
+			MaxGoroutines:             request.MaxGoroutines,
+			Metadata:                  md,
+			Timeout:                   time.Duration(request.GetTimeout()) * time.Second,
+			StopOnError:               request.GetStopOnError(),
+			MaxConnectionsPerResource: int(request.GetMaxConnectionsPerResource()),
+			MaxTotalRows:              int(request.GetMaxTotalRows()),
+			MaxItemsPerResource:       int(request.GetMaxItemsPerResource()),
 		},
 		&GRPCFetchResourcesServer{server: server},
 	)
@@ -0,0 +1,65 @@
+package cqproto
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/cqproto/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fetchRecorder is a CQProviderServer recording the FetchResourcesRequest it's called with
+type fetchRecorder struct {
+	CQProviderServer
+	request *FetchResourcesRequest
+}
+
+func (f *fetchRecorder) FetchResources(_ context.Context, request *FetchResourcesRequest, _ FetchResourcesSender) error {
+	f.request = request
+	return nil
+}
+
+// newTestGRPCClient serves impl over an in memory connection, returning a client connected to it
+func newTestGRPCClient(t *testing.T, impl CQProviderServer) GRPCClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	internal.RegisterProviderServer(s, &GRPCServer{Impl: impl})
+	go func() { _ = s.Serve(listener) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return GRPCClient{client: internal.NewProviderClient(conn)}
+}
+
+func TestGRPC_FetchResourcesRequest(t *testing.T) {
+	recorder := &fetchRecorder{}
+	client := newTestGRPCClient(t, recorder)
+
+	request := &FetchResourcesRequest{
+		Resources:                 []string{"a", "b"},
+		ParallelFetchingLimit:     2,
+		MaxGoroutines:             10,
+		Timeout:                   time.Minute,
+		Metadata:                  map[string]interface{}{"key": "value"},
+		StopOnError:               true,
+		MaxItemsPerResource:       5,
+		MaxTotalRows:              100,
+		MaxConnectionsPerResource: 3,
+	}
+	stream, err := client.FetchResources(context.Background(), request)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, request, recorder.request)
+}
@@ -101,6 +101,20 @@ type FetchResourcesRequest struct {
 	Timeout time.Duration
 	// Metadata for the fetch
 	Metadata map[string]interface{}
+	// StopOnError cancels the remaining fetch once any resource returns an ERROR severity diagnostic;
+	// resources that didn't complete are reported with ResourceFetchCanceled status
+	StopOnError bool
+	// MaxItemsPerResource limits the items fetched by the top level table of each resource if more than 0, the rest
+	// of the items sent by its resolver are discarded
+	MaxItemsPerResource int
+	// MaxTotalRows limits the rows fetched across all resources, their tables and relations, if more than 0. Once
+	// exceeded the fetch is aborted with an ERROR diagnostic, the remaining resources are reported as canceled
+	MaxTotalRows int
+	// MaxConnectionsPerResource limits the database connections each resource uses at a time if more than 0, so a
+	// resource can't starve the connection pool shared by the fetch. Resources fetched in parallel, see
+	// ParallelFetchingLimit, each get their own limit: up to ParallelFetchingLimit * MaxConnectionsPerResource
+	// connections are used at a time, which should be kept within the pool size.
+	MaxConnectionsPerResource int
 }
 
 // FetchResourcesStream represents a CloudQuery RPC stream of fetch updates from the provider
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 
+	"github.com/cloudquery/cq-provider-sdk/database/dsn"
 	"github.com/cloudquery/cq-provider-sdk/database/postgres"
 	"github.com/cloudquery/cq-provider-sdk/provider/execution"
 	"github.com/cloudquery/cq-provider-sdk/provider/schema"
@@ -16,19 +17,55 @@ type DB struct {
 	dialectType schema.DialectType
 }
 
+// Option allows setting optional parameters of a DB
+type Option func(*options)
+
+type options struct {
+	applicationName string
+	queryObserver   postgres.QueryObserver
+}
+
+// WithApplicationName sets the application_name of the DB's connections, shown in pg_stat_activity, unless the DSN sets
+// it already. Postgres truncates it to 63 characters.
+func WithApplicationName(name string) Option {
+	return func(o *options) {
+		o.applicationName = name
+	}
+}
+
+// WithQueryObserver reports every statement executed by the DB to observer, once it completed
+func WithQueryObserver(observer func(sql string, args []interface{})) Option {
+	return func(o *options) {
+		o.queryObserver = observer
+	}
+}
+
 // New creates a new DB using the provided DSN. It will auto-detect the dialect based on the DSN and pass that info to NewPgDatabase
-func New(ctx context.Context, logger hclog.Logger, dsn string) (*DB, error) {
+func New(ctx context.Context, logger hclog.Logger, dsn string, opts ...Option) (*DB, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	dType, newDSN, err := ParseDialectDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
+	if o.applicationName != "" {
+		if newDSN, err = setApplicationName(newDSN, o.applicationName); err != nil {
+			return nil, err
+		}
+	}
 
 	dialect, err := schema.GetDialect(dType)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := postgres.NewPgDatabase(ctx, logger, newDSN, dialect)
+	var pgOpts []postgres.Option
+	if o.queryObserver != nil {
+		pgOpts = append(pgOpts, postgres.WithQueryObserver(o.queryObserver))
+	}
+	db, err := postgres.NewPgDatabase(ctx, logger, newDSN, dialect, pgOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -43,3 +80,15 @@ func New(ctx context.Context, logger hclog.Logger, dsn string) (*DB, error) {
 func (d *DB) DialectType() schema.DialectType {
 	return d.dialectType
 }
+
+// setApplicationName sets the application_name parameter of connString to name, unless already set
+func setApplicationName(connString, name string) (string, error) {
+	u, err := dsn.ParseConnectionString(connString)
+	if err != nil {
+		return "", err
+	}
+	if u.Query().Get("application_name") != "" {
+		return connString, nil
+	}
+	return dsn.SetDSNElement(connString, map[string]string{"application_name": name})
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"strings"
 
 	"github.com/cloudquery/cq-provider-sdk/database/dsn"
 	"github.com/jackc/pgtype"
@@ -9,8 +10,24 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// Option allows setting optional parameters of a pool's configuration
+type Option func(*pgxpool.Config)
+
+// QueryObserver is called with every statement executed on a pool's connections and its arguments, once the statement
+// completed. The arguments are the ones logged by pgx, long strings and byte slices are truncated.
+type QueryObserver func(sql string, args []interface{})
+
+// WithQueryObserver reports every statement executed on the pool to observer, including the ones executed in
+// transactions and the COPY statements of CopyFrom
+func WithQueryObserver(observer QueryObserver) Option {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.Logger = queryLogger(observer)
+		cfg.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
+}
+
 // Connect connects to the given DSN and returns a pgxpool
-func Connect(ctx context.Context, dsnURI string) (*pgxpool.Pool, error) {
+func Connect(ctx context.Context, dsnURI string, opts ...Option) (*pgxpool.Pool, error) {
 	poolCfg, err := pgxpool.ParseConfig(dsnURI)
 	if err != nil {
 		return nil, dsn.RedactParseError(err)
@@ -26,5 +43,28 @@ func Connect(ctx context.Context, dsnURI string) (*pgxpool.Pool, error) {
 		return nil
 	}
 	poolCfg.LazyConnect = true
+	for _, opt := range opts {
+		opt(poolCfg)
+	}
 	return pgxpool.ConnectConfig(ctx, poolCfg)
 }
+
+// queryLogger is a pgx.Logger passing the statements pgx logs to a QueryObserver
+type queryLogger QueryObserver
+
+func (l queryLogger) Log(_ context.Context, _ pgx.LogLevel, msg string, data map[string]interface{}) {
+	switch msg {
+	case "Exec", "Query", "BatchResult.Exec", "BatchResult.Query":
+		sql, _ := data["sql"].(string)
+		args, _ := data["args"].([]interface{})
+		l(sql, args)
+	case "CopyFrom":
+		table, _ := data["tableName"].(pgx.Identifier)
+		columns, _ := data["columnNames"].([]string)
+		quoted := make([]string, len(columns))
+		for i, c := range columns {
+			quoted[i] = pgx.Identifier{c}.Sanitize()
+		}
+		l("COPY "+table.Sanitize()+" ("+strings.Join(quoted, ", ")+") FROM STDIN", nil)
+	}
+}
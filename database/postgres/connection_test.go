@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryLogger(t *testing.T) {
+	var (
+		statements []string
+		args       [][]interface{}
+	)
+	logger := queryLogger(func(sql string, a []interface{}) {
+		statements = append(statements, sql)
+		args = append(args, a)
+	})
+	ctx := context.Background()
+	logger.Log(ctx, pgx.LogLevelInfo, "Exec", map[string]interface{}{"sql": "INSERT INTO test_table (id) VALUES ($1)", "args": []interface{}{"a"}})
+	logger.Log(ctx, pgx.LogLevelError, "Query", map[string]interface{}{"sql": "SELECT * FROM test_table", "args": []interface{}{}})
+	logger.Log(ctx, pgx.LogLevelInfo, "CopyFrom", map[string]interface{}{"tableName": pgx.Identifier{"test_table"}, "columnNames": []string{"cq_id", "name"}})
+	// messages not about statements are ignored
+	logger.Log(ctx, pgx.LogLevelInfo, "closed connection", nil)
+
+	assert.Equal(t, []string{
+		"INSERT INTO test_table (id) VALUES ($1)",
+		"SELECT * FROM test_table",
+		`COPY "test_table" ("cq_id", "name") FROM STDIN`,
+	}, statements)
+	assert.Equal(t, [][]interface{}{{"a"}, {}, nil}, args)
+}
@@ -35,8 +35,8 @@ type PgTx struct {
 
 var _ execution.Storage = (*PgDatabase)(nil)
 
-func NewPgDatabase(ctx context.Context, logger hclog.Logger, dsn string, sd schema.Dialect) (*PgDatabase, error) {
-	pool, err := Connect(ctx, dsn)
+func NewPgDatabase(ctx context.Context, logger hclog.Logger, dsn string, sd schema.Dialect, opts ...Option) (*PgDatabase, error) {
+	pool, err := Connect(ctx, dsn, opts...)
 	if err != nil {
 		return nil, err
 	}
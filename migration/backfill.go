@@ -0,0 +1,85 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// backfillBatchSize is the amount of rows read and updated by every statement of Backfill
+const backfillBatchSize = 500
+
+// BackfillResolver computes the value of the backfilled column of an existing row, given the row's values of the
+// table's declared columns keyed by name, and its cq_id as text
+type BackfillResolver func(ctx context.Context, row map[string]interface{}) (interface{}, error)
+
+// Backfill sets column of every existing row of table to the value computed by resolver, e.g. once a column was added
+// by Diff, so existing rows get a value without fetching them again. Rows are read in batches ordered by cq_id, each
+// batch updated by a single statement, so rows inserted while backfilling may be missed. Values are validated as
+// ValidateType does when resources are stored. Encrypted columns can't be backfilled. It returns the amount of rows
+// updated.
+func Backfill(ctx context.Context, execer execution.QueryExecer, table *schema.Table, column string, resolver BackfillResolver) (int, error) {
+	c := table.Column(column)
+	if c == nil {
+		return 0, fmt.Errorf("column %s doesn't exist in table %s", column, table.Name)
+	}
+	if c.Encrypt != nil {
+		return 0, fmt.Errorf("column %s of table %s is encrypted, encrypted columns can't be backfilled", column, table.Name)
+	}
+	columns := []string{"cq_id::text AS cq_id"}
+	for _, tc := range table.Columns {
+		if tc.Name != "cq_id" {
+			columns = append(columns, strconv.Quote(tc.Name))
+		}
+	}
+	name := strconv.Quote(table.Name)
+	typ := columnType(schema.PostgresDialect{}, table, *c)
+
+	var updated int
+	var last interface{}
+	for {
+		// rows are paged by cq_id rather than offset, so later batches don't scan the rows of earlier ones
+		var where string
+		var args []interface{}
+		if last != nil {
+			where, args = " WHERE cq_id > $1::uuid", []interface{}{last}
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY cq_id LIMIT %d", strings.Join(columns, ", "), name, where, backfillBatchSize)
+		var rows []map[string]interface{}
+		if err := pgxscan.Select(ctx, execer, &rows, query, args...); err != nil {
+			return updated, fmt.Errorf("failed to read rows of table %s: %w", table.Name, err)
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		values := make([]string, len(rows))
+		updateArgs := make([]interface{}, 0, 2*len(rows))
+		for i, row := range rows {
+			v, err := resolver(ctx, row)
+			if err != nil {
+				return updated, fmt.Errorf("failed to backfill column %s of table %s for row %v: %w", column, table.Name, row["cq_id"], err)
+			}
+			if err := c.ValidateType(v); err != nil {
+				return updated, fmt.Errorf("failed to backfill column %s of table %s for row %v: %w", column, table.Name, row["cq_id"], err)
+			}
+			values[i] = fmt.Sprintf("($%d::uuid, $%d::%s)", 2*i+1, 2*i+2, typ)
+			updateArgs = append(updateArgs, row["cq_id"], v)
+		}
+		update := fmt.Sprintf("UPDATE %[1]s SET %[2]s = v.value FROM (VALUES %[3]s) AS v(cq_id, value) WHERE %[1]s.cq_id = v.cq_id",
+			name, strconv.Quote(column), strings.Join(values, ", "))
+		if err := execer.Exec(ctx, update, updateArgs...); err != nil {
+			return updated, fmt.Errorf("failed to backfill column %s of table %s: %w", column, table.Name, err)
+		}
+		updated += len(rows)
+		if len(rows) < backfillBatchSize {
+			return updated, nil
+		}
+		last = rows[len(rows)-1]["cq_id"]
+	}
+}
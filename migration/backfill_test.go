@@ -0,0 +1,72 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/database"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	old := &schema.Table{
+		Name:    "test_backfill",
+		Columns: []schema.Column{{Name: "name", Type: schema.TypeString}},
+	}
+	ups, err := CreateTableDefinitions(ctx, schema.PostgresDialect{}, old, nil)
+	require.NoError(t, err)
+
+	db, err := database.New(ctx, hclog.NewNullLogger(), getDBUrl())
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Exec(ctx, `DROP TABLE IF EXISTS "test_backfill"`))
+	require.NoError(t, db.Exec(ctx, ups[0]))
+	// more rows than a single batch
+	const seeded = 2*backfillBatchSize + 7
+	require.NoError(t, db.Exec(ctx, `INSERT INTO "test_backfill" (cq_id, name) SELECT md5(i::text)::uuid, repeat('a', i % 10) FROM generate_series(1, $1) AS i`, seeded))
+
+	table := &schema.Table{
+		Name: "test_backfill",
+		Columns: []schema.Column{
+			{Name: "name", Type: schema.TypeString},
+			{Name: "name_length", Type: schema.TypeBigInt},
+		},
+	}
+	ups, err = Diff(schema.PostgresDialect{}, old, table)
+	require.NoError(t, err)
+	for _, up := range ups {
+		require.NoError(t, db.Exec(ctx, up))
+	}
+
+	updated, err := Backfill(ctx, db, table, "name_length", func(_ context.Context, row map[string]interface{}) (interface{}, error) {
+		return len(row["name"].(string)), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, seeded, updated)
+
+	var mismatched, missing int
+	require.NoError(t, pgxscan.Get(ctx, db, &mismatched, `SELECT count(*) FROM "test_backfill" WHERE name_length <> length(name)`))
+	require.NoError(t, pgxscan.Get(ctx, db, &missing, `SELECT count(*) FROM "test_backfill" WHERE name_length IS NULL`))
+	assert.Zero(t, mismatched)
+	assert.Zero(t, missing)
+}
+
+func TestBackfill_InvalidColumn(t *testing.T) {
+	table := &schema.Table{
+		Name: "test_backfill",
+		Columns: []schema.Column{
+			{Name: "name", Type: schema.TypeString},
+			{Name: "token", Type: schema.TypeString, Encrypt: func(b []byte) ([]byte, error) { return b, nil }},
+		},
+	}
+	resolver := func(context.Context, map[string]interface{}) (interface{}, error) { return nil, nil }
+	_, err := Backfill(context.Background(), nil, table, "missing", resolver)
+	assert.EqualError(t, err, "column missing doesn't exist in table test_backfill")
+	_, err = Backfill(context.Background(), nil, table, "token", resolver)
+	assert.EqualError(t, err, "column token of table test_backfill is encrypted, encrypted columns can't be backfilled")
+}
@@ -0,0 +1,99 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+)
+
+// Diff returns the statements upgrading the tables of old, as created by CreateTableDefinitions, to those of new,
+// keeping the data of the columns both declare. Tables and their relations are matched by name:
+//   - columns only in new are added, as nullable even if new declares them NOT NULL, since existing rows have no value
+//   - columns only in old are dropped
+//   - columns whose type changed are converted with a cast of their values
+//   - relations only in new are created, relations only in old are dropped with their own relations
+//
+// Changes to constraints, such as primary keys, aren't migrated.
+func Diff(dialect schema.Dialect, old, new *schema.Table) ([]string, error) {
+	if old.Name != new.Name {
+		return nil, fmt.Errorf("can't diff table %s with table %s, renaming tables isn't supported", old.Name, new.Name)
+	}
+	return diffTable(dialect, old, new)
+}
+
+func diffTable(dialect schema.Dialect, old, new *schema.Table) ([]string, error) {
+	var ups []string
+	table := strconv.Quote(new.Name)
+
+	oldColumns := make(map[string]schema.Column)
+	for _, c := range dialect.Columns(old) {
+		oldColumns[c.Name] = c
+	}
+	newColumns := make(map[string]bool)
+	for _, c := range dialect.Columns(new) {
+		newColumns[c.Name] = true
+		oc, ok := oldColumns[c.Name]
+		if !ok {
+			if c.Type == schema.TypeEnum {
+				ups = append(ups, createEnumType(new, c))
+			}
+			ups = append(ups, fmt.Sprintf("ALTER TABLE IF EXISTS %s ADD COLUMN IF NOT EXISTS %s %s;", table, strconv.Quote(c.Name), columnType(dialect, new, c)))
+			continue
+		}
+		if typ := columnType(dialect, new, c); typ != columnType(dialect, old, oc) {
+			if c.Type == schema.TypeEnum {
+				ups = append(ups, createEnumType(new, c))
+			}
+			if oc.Type == schema.TypeEnum {
+				// enum values can't be cast to another type directly, only their text can
+				ups = append(ups, fmt.Sprintf("ALTER TABLE IF EXISTS %[1]s ALTER COLUMN %[2]s TYPE %[3]s USING %[2]s::text::%[3]s;", table, strconv.Quote(c.Name), typ))
+			} else {
+				ups = append(ups, fmt.Sprintf("ALTER TABLE IF EXISTS %[1]s ALTER COLUMN %[2]s TYPE %[3]s USING %[2]s::%[3]s;", table, strconv.Quote(c.Name), typ))
+			}
+		}
+	}
+	for _, c := range dialect.Columns(old) {
+		if !newColumns[c.Name] {
+			ups = append(ups, fmt.Sprintf("ALTER TABLE IF EXISTS %s DROP COLUMN IF EXISTS %s;", table, strconv.Quote(c.Name)))
+		}
+	}
+
+	oldRelations := make(map[string]*schema.Table, len(old.Relations))
+	for _, rel := range old.Relations {
+		oldRelations[rel.Name] = rel
+	}
+	newRelations := make(map[string]bool, len(new.Relations))
+	for _, rel := range new.Relations {
+		newRelations[rel.Name] = true
+		oldRel, ok := oldRelations[rel.Name]
+		if !ok {
+			cr, err := CreateTableDefinitions(context.Background(), dialect, rel, new)
+			if err != nil {
+				return nil, err
+			}
+			ups = append(ups, cr...)
+			continue
+		}
+		dr, err := diffTable(dialect, oldRel, rel)
+		if err != nil {
+			return nil, err
+		}
+		ups = append(ups, dr...)
+	}
+	for _, rel := range old.Relations {
+		if newRelations[rel.Name] {
+			continue
+		}
+		var drops []string
+		walkTables(rel, func(t *schema.Table) {
+			drops = append(drops, fmt.Sprintf("DROP TABLE IF EXISTS %s;", strconv.Quote(t.Name)))
+		})
+		// relations of the dropped relation reference it, so they're dropped first
+		for i := len(drops) - 1; i >= 0; i-- {
+			ups = append(ups, drops[i])
+		}
+	}
+	return ups, nil
+}
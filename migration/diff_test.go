@@ -0,0 +1,80 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	old := &schema.Table{
+		Name: "test_diff",
+		Columns: []schema.Column{
+			{Name: "name", Type: schema.TypeString},
+			{Name: "count", Type: schema.TypeInt},
+			{Name: "removed", Type: schema.TypeBool},
+		},
+		Relations: []*schema.Table{
+			{
+				Name:    "test_diff_kept",
+				Columns: []schema.Column{{Name: "test_diff_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver}},
+			},
+			{
+				Name:    "test_diff_removed",
+				Columns: []schema.Column{{Name: "test_diff_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver}},
+				Relations: []*schema.Table{
+					{
+						Name:    "test_diff_removed_child",
+						Columns: []schema.Column{{Name: "test_diff_removed_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver}},
+					},
+				},
+			},
+		},
+	}
+	new := &schema.Table{
+		Name: "test_diff",
+		Columns: []schema.Column{
+			{Name: "name", Type: schema.TypeString},
+			{Name: "count", Type: schema.TypeBigInt},
+			{Name: "status", Type: schema.TypeEnum, EnumValues: []string{"on", "off"}, CreationOptions: schema.ColumnCreationOptions{NotNull: true}},
+		},
+		Relations: []*schema.Table{
+			{
+				Name: "test_diff_kept",
+				Columns: []schema.Column{
+					{Name: "test_diff_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver},
+					{Name: "tags", Type: schema.TypeStringArray},
+				},
+			},
+			{
+				Name:    "test_diff_added",
+				Columns: []schema.Column{{Name: "test_diff_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver}},
+			},
+		},
+	}
+	ups, err := Diff(schema.PostgresDialect{}, old, new)
+	require.NoError(t, err)
+	require.Len(t, ups, 8)
+	assert.Equal(t, []string{
+		`ALTER TABLE IF EXISTS "test_diff" ALTER COLUMN "count" TYPE bigint USING "count"::bigint;`,
+		createEnumType(new, new.Columns[2]),
+		`ALTER TABLE IF EXISTS "test_diff" ADD COLUMN IF NOT EXISTS "status" "test_diff_status_enum";`,
+		`ALTER TABLE IF EXISTS "test_diff" DROP COLUMN IF EXISTS "removed";`,
+		`ALTER TABLE IF EXISTS "test_diff_kept" ADD COLUMN IF NOT EXISTS "tags" text[];`,
+	}, ups[:5])
+	assert.Contains(t, ups[5], `CREATE TABLE IF NOT EXISTS "test_diff_added"`)
+	assert.Equal(t, []string{
+		`DROP TABLE IF EXISTS "test_diff_removed_child";`,
+		`DROP TABLE IF EXISTS "test_diff_removed";`,
+	}, ups[6:])
+
+	// diffing a table with itself changes nothing
+	ups, err = Diff(schema.PostgresDialect{}, new, new)
+	require.NoError(t, err)
+	assert.Empty(t, ups)
+
+	_, err = Diff(schema.PostgresDialect{}, old, &schema.Table{Name: "test_renamed"})
+	assert.EqualError(t, err, "can't diff table test_diff with table test_renamed, renaming tables isn't supported")
+}
@@ -44,6 +44,7 @@ const (
 
 	migrationsEmbeddedDirectoryPath = "migrations"
 	dropTableSQL                    = "DROP TABLE IF EXISTS %s CASCADE"
+	dropTypeSQL                     = "DROP TYPE IF EXISTS %s CASCADE"
 )
 
 // ReadMigrationFiles reads the given embed.FS for the migration files and returns a map of dialect directories vs. filenames vs. data
@@ -283,6 +284,14 @@ func dropTables(ctx context.Context, conn *pgx.Conn, table *schema.Table) error
 	if _, err := conn.Exec(ctx, fmt.Sprintf(dropTableSQL, strconv.Quote(table.Name))); err != nil {
 		return err
 	}
+	for _, c := range table.Columns {
+		if c.Type != schema.TypeEnum {
+			continue
+		}
+		if _, err := conn.Exec(ctx, fmt.Sprintf(dropTypeSQL, strconv.Quote(schema.EnumTypeName(table, c)))); err != nil {
+			return err
+		}
+	}
 	for _, rel := range table.Relations {
 		if err := dropTables(ctx, conn, rel); err != nil {
 			return err
@@ -0,0 +1,105 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+)
+
+// SortTables orders top level tables so every table comes after the tables its columns reference, including
+// references of its relations, see schema.ColumnReference. References to tables not given are assumed to exist
+// already. Tables without references between them are ordered by name, it returns an error on cyclic references.
+func SortTables(tables []*schema.Table) ([]*schema.Table, error) {
+	sorted := make([]*schema.Table, len(tables))
+	copy(sorted, tables)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	// owners maps every table, top level or relation, to the top level table it's created with
+	owners := make(map[string]*schema.Table)
+	for _, t := range sorted {
+		walkTables(t, func(rel *schema.Table) { owners[rel.Name] = t })
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*schema.Table]int, len(sorted))
+	ret := make([]*schema.Table, 0, len(sorted))
+	var visit func(t *schema.Table, path []string) error
+	visit = func(t *schema.Table, path []string) error {
+		path = append(path, t.Name)
+		switch state[t] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("cyclic table references: %s", strings.Join(path, " -> "))
+		}
+		state[t] = visiting
+		for _, dep := range tableDependencies(t, owners) {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[t] = visited
+		ret = append(ret, t)
+		return nil
+	}
+	for _, t := range sorted {
+		if err := visit(t, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// CreateTablesDefinitions builds the CREATE TABLE statements of all the given tables and their relations, ordered
+// so referenced tables are created first, see SortTables
+func CreateTablesDefinitions(ctx context.Context, dialect schema.Dialect, tables []*schema.Table) ([]string, error) {
+	sorted, err := SortTables(tables)
+	if err != nil {
+		return nil, err
+	}
+	var ups []string
+	for _, t := range sorted {
+		up, err := CreateTableDefinitions(ctx, dialect, t, nil)
+		if err != nil {
+			return nil, err
+		}
+		ups = append(ups, up...)
+	}
+	return ups, nil
+}
+
+// tableDependencies returns the other top level tables referenced by the columns of t and its relations, ordered by name
+func tableDependencies(t *schema.Table, owners map[string]*schema.Table) []*schema.Table {
+	deps := make(map[string]*schema.Table)
+	walkTables(t, func(rel *schema.Table) {
+		for _, c := range rel.Columns {
+			ref := c.CreationOptions.References
+			if ref == nil {
+				continue
+			}
+			if owner, ok := owners[ref.Table]; ok && owner != t {
+				deps[owner.Name] = owner
+			}
+		}
+	})
+	ret := make([]*schema.Table, 0, len(deps))
+	for _, dep := range deps {
+		ret = append(ret, dep)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// walkTables calls fn with t and each of its relations recursively
+func walkTables(t *schema.Table, fn func(*schema.Table)) {
+	fn(t)
+	for _, rel := range t.Relations {
+		walkTables(rel, fn)
+	}
+}
@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func referencingTable(name, references string, relations ...*schema.Table) *schema.Table {
+	t := &schema.Table{
+		Name:      name,
+		Columns:   []schema.Column{{Name: "id", Type: schema.TypeString, CreationOptions: schema.ColumnCreationOptions{Unique: true}}},
+		Relations: relations,
+	}
+	if references != "" {
+		t.Columns = append(t.Columns, schema.Column{
+			Name:            "ref_id",
+			Type:            schema.TypeString,
+			CreationOptions: schema.ColumnCreationOptions{References: &schema.ColumnReference{Table: references, Column: "id"}},
+		})
+	}
+	return t
+}
+
+func tableNames(tables []*schema.Table) []string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func TestSortTables(t *testing.T) {
+	regions := referencingTable("a_regions", "c_zones")
+	zones := referencingTable("c_zones", "")
+	// the relations of b_instances reference a relation of d_lookups and the top level a_regions
+	instances := referencingTable("b_instances", "", referencingTable("b_instance_disks", "d_lookup_types"), referencingTable("b_instance_regions", "a_regions"))
+	lookups := referencingTable("d_lookups", "", referencingTable("d_lookup_types", ""))
+
+	sorted, err := SortTables([]*schema.Table{regions, instances, zones, lookups})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c_zones", "a_regions", "d_lookups", "b_instances"}, tableNames(sorted))
+
+	ups, err := CreateTablesDefinitions(context.Background(), schema.PostgresDialect{}, []*schema.Table{instances, lookups})
+	require.NoError(t, err)
+	require.Len(t, ups, 5)
+	assert.True(t, strings.HasPrefix(ups[0], `CREATE TABLE IF NOT EXISTS "d_lookups"`))
+	assert.Contains(t, ups[3], `FOREIGN KEY ("ref_id") REFERENCES "d_lookup_types"("id")`)
+}
+
+func TestSortTables_Cycle(t *testing.T) {
+	_, err := SortTables([]*schema.Table{
+		referencingTable("a", "b"),
+		referencingTable("b", "", referencingTable("b_child", "a")),
+		referencingTable("self", "self"),
+	})
+	assert.EqualError(t, err, "cyclic table references: a -> b -> a")
+}
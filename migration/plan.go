@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+)
+
+// PrintPlan writes the statements creating tables and their relations to w without executing them, in the order
+// CreateTablesDefinitions returns them. The statements of each top level table, its ENUM types, CREATE TABLE statements
+// of it and its relations and any dialect specific extras such as indexes, are preceded by a comment naming the table.
+func PrintPlan(w io.Writer, dialect schema.Dialect, tables []*schema.Table) error {
+	sorted, err := SortTables(tables)
+	if err != nil {
+		return err
+	}
+	for i, t := range sorted {
+		ups, err := CreateTableDefinitions(context.Background(), dialect, t, nil)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		var relations []string
+		walkTables(t, func(rel *schema.Table) {
+			if rel != t {
+				relations = append(relations, rel.Name)
+			}
+		})
+		header := "-- Table: " + t.Name + "\n"
+		if len(relations) > 0 {
+			header += "-- Relations: " + strings.Join(relations, ", ") + "\n"
+		}
+		if _, err := io.WriteString(w, header+strings.Join(ups, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
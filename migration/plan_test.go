@@ -0,0 +1,35 @@
+package migration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintPlan(t *testing.T) {
+	children := referencingTable("b_children", "")
+	parent := referencingTable("b_parents", "a_zones", children)
+	zones := referencingTable("a_zones", "")
+
+	var b strings.Builder
+	require.NoError(t, PrintPlan(&b, schema.PostgresDialect{}, []*schema.Table{parent, zones}))
+	plan := b.String()
+
+	ups, err := CreateTablesDefinitions(context.Background(), schema.PostgresDialect{}, []*schema.Table{parent, zones})
+	require.NoError(t, err)
+	// the plan holds the same statements in the same order, delimited by comments per top level table
+	var statements []string
+	for _, line := range strings.Split(plan, "\n") {
+		if strings.HasPrefix(line, "-- ") || line == "" {
+			continue
+		}
+		statements = append(statements, line)
+	}
+	assert.Equal(t, strings.Split(strings.Join(ups, "\n"), "\n"), statements)
+	assert.True(t, strings.HasPrefix(plan, "-- Table: a_zones\nCREATE TABLE IF NOT EXISTS \"a_zones\" (\n"))
+	assert.Contains(t, plan, ");\n\n-- Table: b_parents\n-- Relations: b_children\nCREATE TABLE IF NOT EXISTS \"b_parents\" (\n")
+}
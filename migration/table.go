@@ -2,6 +2,7 @@ package migration
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -9,16 +10,36 @@ import (
 )
 
 // CreateTableDefinitions reads schema.Table and builds the CREATE TABLE statement for it, also processing and returning subrelation tables
+// The Description of columns is set as their comment.
 func CreateTableDefinitions(ctx context.Context, dialect schema.Dialect, t *schema.Table, parent *schema.Table) ([]string, error) {
+	// duplicate columns would otherwise fail the CREATE TABLE statement with a less obvious error
+	if err := schema.ValidateColumnNamesUnique(t.Name, dialect.Columns(t)); err != nil {
+		return nil, err
+	}
+	up := make([]string, 0, 1+len(t.Relations))
+	// ENUM types must exist before the table referencing them
+	for _, c := range t.Columns {
+		if c.Type == schema.TypeEnum {
+			up = append(up, createEnumType(t, c))
+		}
+	}
+
 	b := &strings.Builder{}
 
 	// Build a SQL to create a table
 	b.WriteString("CREATE TABLE IF NOT EXISTS " + strconv.Quote(t.Name) + " (\n")
 
+	var parentIdColumn string
+	if parent != nil && t.Options.ParentIdNotNull {
+		if pc := schema.FindParentIdColumn(t); pc != nil {
+			parentIdColumn = pc.Name
+		}
+	}
+
 	for _, c := range dialect.Columns(t) {
 		b.WriteByte('\t')
-		b.WriteString(strconv.Quote(c.Name) + " " + dialect.DBTypeFromType(c.Type))
-		if c.CreationOptions.NotNull {
+		b.WriteString(strconv.Quote(c.Name) + " " + columnType(dialect, t, c))
+		if c.CreationOptions.NotNull || c.Name == parentIdColumn {
 			b.WriteString(" NOT NULL")
 		}
 		// c.CreationOptions.Unique is handled in the Constraints() call below
@@ -39,9 +60,13 @@ func CreateTableDefinitions(ctx context.Context, dialect schema.Dialect, t *sche
 
 	b.WriteString(");")
 
-	up := make([]string, 0, 1+len(t.Relations))
 	up = append(up, b.String())
 	up = append(up, dialect.Extra(t, parent)...)
+	for _, c := range t.Columns {
+		if c.Description != "" {
+			up = append(up, commentOnColumn(t, c))
+		}
+	}
 
 	// Create relation tables
 	for _, r := range t.Relations {
@@ -54,3 +79,32 @@ func CreateTableDefinitions(ctx context.Context, dialect schema.Dialect, t *sche
 
 	return up, nil
 }
+
+// columnType returns the database type column c of table t is created as
+func columnType(dialect schema.Dialect, t *schema.Table, c schema.Column) string {
+	switch {
+	case c.Type == schema.TypeEnum:
+		return strconv.Quote(schema.EnumTypeName(t, c))
+	case c.Encrypt != nil:
+		// the ciphertext is stored rather than the value, MaxLength limits the plaintext
+		return dialect.DBTypeFromType(schema.TypeByteArray)
+	default:
+		return schema.ColumnDBType(dialect, c)
+	}
+}
+
+// createEnumType builds the CREATE TYPE statement of TypeEnum column c of table t. Like CREATE TABLE IF NOT EXISTS
+// it's idempotent, postgres has no CREATE TYPE IF NOT EXISTS so the type is created in a block ignoring its existence.
+func createEnumType(t *schema.Table, c schema.Column) string {
+	values := make([]string, len(c.EnumValues))
+	for i, v := range c.EnumValues {
+		values[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return fmt.Sprintf("DO $cq$ BEGIN\n\tCREATE TYPE %s AS ENUM (%s);\nEXCEPTION\n\tWHEN duplicate_object THEN NULL;\nEND $cq$;",
+		strconv.Quote(schema.EnumTypeName(t, c)), strings.Join(values, ", "))
+}
+
+// commentOnColumn returns the statement setting the Description of column c of table t as its comment
+func commentOnColumn(t *schema.Table, c schema.Column) string {
+	return fmt.Sprintf("COMMENT ON COLUMN %s.%s IS '%s';", strconv.Quote(t.Name), strconv.Quote(c.Name), strings.ReplaceAll(c.Description, "'", "''"))
+}
@@ -18,6 +18,9 @@ type Description struct {
 
 	Summary string
 	Detail  string
+	// Code optionally identifies the kind of failure, allowing failures to be mapped to known issues programmatically.
+	// Note it isn't sent over gRPC.
+	Code string
 }
 
 const (
@@ -2,6 +2,7 @@ package diag
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 	"testing"
@@ -283,3 +284,25 @@ func TestDiagnostics_BySeverity(t *testing.T) {
 		})
 	}
 }
+
+type codedError struct{ code string }
+
+func (e codedError) Error() string     { return "coded error" }
+func (e codedError) ErrorCode() string { return e.code }
+
+func TestBaseError_Code(t *testing.T) {
+	assert.Empty(t, NewBaseError(errors.New("error test"), RESOLVING).Description().Code)
+	assert.Equal(t, "AccessDenied", NewBaseError(fmt.Errorf("wrapped: %w", codedError{"AccessDenied"}), RESOLVING).Description().Code)
+	assert.Equal(t, "custom", NewBaseError(codedError{"AccessDenied"}, RESOLVING, WithCode("custom")).Description().Code)
+
+	// diagnostics differing only in their code aren't squashed together
+	diags := Diagnostics{
+		NewBaseError(errors.New("error test"), RESOLVING, WithResourceName("a"), WithCode("a")),
+		NewBaseError(errors.New("error test"), RESOLVING, WithResourceName("a"), WithCode("a")),
+		NewBaseError(errors.New("error test"), RESOLVING, WithResourceName("a"), WithCode("b")),
+	}.Squash()
+	assert.Len(t, diags, 2)
+	assert.Equal(t, "a", diags[0].Description().Code)
+	assert.Equal(t, uint64(2), CountDiag(diags[0]))
+	assert.Equal(t, "b", diags[1].Description().Code)
+}
@@ -1,6 +1,7 @@
 package diag
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"runtime"
@@ -32,12 +33,21 @@ type BaseError struct {
 	// Type indicates the classification family of this diagnostic
 	diagnosticType Type
 
+	// code identifies the kind of failure, if not set it's taken from the underlying error if it's an ErrorCoder
+	code string
+
 	// if noOverwrite is true, further Options won't overwrite previously set values. Valid for the duration of one "invocation"
 	noOverwrite bool
 }
 
 type BaseErrorOption func(*BaseError)
 
+// ErrorCoder is implemented by errors carrying a code of their kind, e.g. errors returned by cloud provider APIs.
+// A BaseError wrapping an ErrorCoder uses its code, unless set with WithCode.
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
 // WrapError wraps error with the following string: "error at function_name[filename:line_number]: %w"
 // if err is nil returns nil
 func WrapError(err error) error {
@@ -84,11 +94,18 @@ func (e BaseError) Description() Description {
 		}
 	}
 
+	code := e.code
+	var coder ErrorCoder
+	if code == "" && errors.As(e.err, &coder) {
+		code = coder.ErrorCode()
+	}
+
 	return Description{
-		e.resource,
-		e.resourceId,
-		summary,
-		e.detail,
+		Resource:   e.resource,
+		ResourceID: e.resourceId,
+		Summary:    summary,
+		Detail:     e.detail,
+		Code:       code,
 	}
 }
 
@@ -178,6 +195,15 @@ func WithDetails(detail string, args ...interface{}) BaseErrorOption {
 	}
 }
 
+// WithCode sets the code identifying the kind of failure, see Description Code
+func WithCode(code string) BaseErrorOption {
+	return func(e *BaseError) {
+		if !e.noOverwrite || e.code == "" {
+			e.code = code
+		}
+	}
+}
+
 func WithError(err error) BaseErrorOption {
 	return func(e *BaseError) {
 		if !e.noOverwrite || e.err == nil {
@@ -0,0 +1,78 @@
+package execution
+
+import (
+	"context"
+	"sync"
+)
+
+// Cache is a fetch scoped cache resolvers share via their context, e.g. so relation resolvers listing the same
+// upstream resources call the API once. Provider.FetchResources passes a new cache to every fetch, see CacheFromContext.
+// A nil *Cache is valid and caches nothing.
+type Cache struct {
+	lock   sync.Mutex
+	items  map[string]interface{}
+	hits   uint64
+	misses uint64
+}
+
+// CacheStats are the lookups of a Cache
+type CacheStats struct {
+	// Hits is the amount of Get calls that found their key
+	Hits uint64
+	// Misses is the amount of Get calls that didn't find their key
+	Misses uint64
+}
+
+// NewCache returns an empty cache
+func NewCache() *Cache {
+	return &Cache{items: make(map[string]interface{})}
+}
+
+// Get returns the value stored for key and whether it was found
+func (c *Cache) Get(key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	v, ok := c.items[key]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return v, ok
+}
+
+// Set stores value for key, replacing any value stored before
+func (c *Cache) Set(key string, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items[key] = value
+}
+
+// Stats returns the lookups of the cache so far
+func (c *Cache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses}
+}
+
+type cacheKey struct{}
+
+// WithCache returns a copy of ctx carrying cache, passed to resolvers via their context
+func WithCache(ctx context.Context, cache *Cache) context.Context {
+	return context.WithValue(ctx, cacheKey{}, cache)
+}
+
+// CacheFromContext returns the cache carried by ctx, or nil if it carries none
+func CacheFromContext(ctx context.Context) *Cache {
+	cache, _ := ctx.Value(cacheKey{}).(*Cache)
+	return cache
+}
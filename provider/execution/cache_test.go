@@ -0,0 +1,31 @@
+package execution
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	assert.Nil(t, CacheFromContext(context.Background()))
+
+	cache := NewCache()
+	ctx := WithCache(context.Background(), cache)
+	assert.Same(t, cache, CacheFromContext(ctx))
+
+	_, ok := CacheFromContext(ctx).Get("accounts")
+	assert.False(t, ok)
+	CacheFromContext(ctx).Set("accounts", []string{"a", "b"})
+	v, ok := CacheFromContext(ctx).Get("accounts")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, v)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, cache.Stats())
+
+	// a nil cache caches nothing
+	var nilCache *Cache
+	nilCache.Set("accounts", 1)
+	_, ok = nilCache.Get("accounts")
+	assert.False(t, ok)
+	assert.Equal(t, CacheStats{}, nilCache.Stats())
+}
@@ -0,0 +1,66 @@
+package execution
+
+import (
+	"context"
+	"sync"
+)
+
+// ConcurrencyTracker records the maximum number of resources resolved concurrently by a fetch, i.e. of TableExecutor
+// Resolve calls running at once, e.g. so tests can assert the fetch respects its ParallelFetchingLimit. Relations are
+// resolved within the Resolve call of their resource and aren't counted. A nil *ConcurrencyTracker records nothing.
+type ConcurrencyTracker struct {
+	lock    sync.Mutex
+	running int
+	max     int
+}
+
+// NewConcurrencyTracker returns a tracker which recorded no resolves yet
+func NewConcurrencyTracker() *ConcurrencyTracker {
+	return &ConcurrencyTracker{}
+}
+
+// start records a resolve started, it must be followed by a call to done once it finished
+func (c *ConcurrencyTracker) start() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+}
+
+// done records a resolve finished
+func (c *ConcurrencyTracker) done() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.running--
+}
+
+// Max returns the maximum number of resolves that ran concurrently so far
+func (c *ConcurrencyTracker) Max() int {
+	if c == nil {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.max
+}
+
+type concurrencyTrackerKey struct{}
+
+// WithConcurrencyTracker returns a copy of ctx carrying tracker, recording the resolves of table executors called with it
+func WithConcurrencyTracker(ctx context.Context, tracker *ConcurrencyTracker) context.Context {
+	return context.WithValue(ctx, concurrencyTrackerKey{}, tracker)
+}
+
+// ConcurrencyTrackerFromContext returns the tracker carried by ctx, or nil if it carries none
+func ConcurrencyTrackerFromContext(ctx context.Context) *ConcurrencyTracker {
+	tracker, _ := ctx.Value(concurrencyTrackerKey{}).(*ConcurrencyTracker)
+	return tracker
+}
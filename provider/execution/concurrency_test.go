@@ -0,0 +1,34 @@
+package execution
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyTracker(t *testing.T) {
+	assert.Nil(t, ConcurrencyTrackerFromContext(context.Background()))
+
+	tracker := NewConcurrencyTracker()
+	ctx := WithConcurrencyTracker(context.Background(), tracker)
+	assert.Same(t, tracker, ConcurrencyTrackerFromContext(ctx))
+
+	tracker.start()
+	tracker.start()
+	tracker.done()
+	tracker.start()
+	tracker.start()
+	assert.Equal(t, 3, tracker.Max())
+	tracker.done()
+	tracker.done()
+	tracker.done()
+	tracker.start()
+	assert.Equal(t, 3, tracker.Max())
+
+	// a nil tracker records nothing
+	var nilTracker *ConcurrencyTracker
+	nilTracker.start()
+	nilTracker.done()
+	assert.Zero(t, nilTracker.Max())
+}
@@ -0,0 +1,154 @@
+package execution
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/sync/semaphore"
+)
+
+// connLimitedStorage limits the operations of a Storage running concurrently, each holding a connection of its pool
+type connLimitedStorage struct {
+	Storage
+	sem *semaphore.Weighted
+}
+
+// LimitConnections returns a Storage running at most max operations of storage concurrently, or storage itself if max
+// isn't positive. Since every operation holds a connection of the pool until it's done, a query until its rows are
+// closed and a transaction until it's committed or rolled back, this caps the connections a single resource takes
+// from the pool shared by the fetch, so a resource with heavy queries can't starve the others. Closing the returned
+// Storage doesn't close storage.
+func LimitConnections(storage Storage, max int) Storage {
+	if max <= 0 {
+		return storage
+	}
+	return &connLimitedStorage{Storage: storage, sem: semaphore.NewWeighted(int64(max))}
+}
+
+func (s *connLimitedStorage) Exec(ctx context.Context, query string, args ...interface{}) error {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+	return s.Storage.Exec(ctx, query, args...)
+}
+
+func (s *connLimitedStorage) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	rows, err := s.Storage.Query(ctx, query, args...)
+	if err != nil {
+		s.sem.Release(1)
+		return nil, err
+	}
+	return &releasingRows{Rows: rows, release: s.release()}, nil
+}
+
+func (s *connLimitedStorage) Begin(ctx context.Context) (TXQueryExecer, error) {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	tx, err := s.Storage.Begin(ctx)
+	if err != nil {
+		s.sem.Release(1)
+		return nil, err
+	}
+	return &releasingTx{TXQueryExecer: tx, release: s.release()}, nil
+}
+
+func (s *connLimitedStorage) RawCopyTo(ctx context.Context, w io.Writer, sql string) error {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+	return s.Storage.RawCopyTo(ctx, w, sql)
+}
+
+func (s *connLimitedStorage) RawCopyFrom(ctx context.Context, r io.Reader, sql string) error {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+	return s.Storage.RawCopyFrom(ctx, r, sql)
+}
+
+func (s *connLimitedStorage) Insert(ctx context.Context, t *schema.Table, instance schema.Resources, shouldCascade bool, cascadeDeleteFilters map[string]interface{}) error {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+	return s.Storage.Insert(ctx, t, instance, shouldCascade, cascadeDeleteFilters)
+}
+
+func (s *connLimitedStorage) Delete(ctx context.Context, t *schema.Table, kvFilters []interface{}) error {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+	return s.Storage.Delete(ctx, t, kvFilters)
+}
+
+func (s *connLimitedStorage) RemoveStaleData(ctx context.Context, t *schema.Table, executionStart time.Time, kvFilters []interface{}) error {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+	return s.Storage.RemoveStaleData(ctx, t, executionStart, kvFilters)
+}
+
+func (s *connLimitedStorage) CopyFrom(ctx context.Context, resources schema.Resources, shouldCascade bool, cascadeDeleteFilters map[string]interface{}) error {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+	return s.Storage.CopyFrom(ctx, resources, shouldCascade, cascadeDeleteFilters)
+}
+
+// Close is a no-op, the wrapped storage is shared with other resources
+func (*connLimitedStorage) Close() {}
+
+// release returns a func releasing an acquired operation once, however many times it's called
+func (s *connLimitedStorage) release() func() {
+	var once sync.Once
+	return func() { once.Do(func() { s.sem.Release(1) }) }
+}
+
+// releasingRows releases its operation once closed, pgx closes rows once they're read to the end or fail as well
+type releasingRows struct {
+	pgx.Rows
+	release func()
+}
+
+func (r *releasingRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.release()
+	return false
+}
+
+func (r *releasingRows) Close() {
+	r.Rows.Close()
+	r.release()
+}
+
+// releasingTx releases its operation once committed or rolled back, its own queries run on its connection
+type releasingTx struct {
+	TXQueryExecer
+	release func()
+}
+
+func (tx *releasingTx) Commit(ctx context.Context) error {
+	defer tx.release()
+	return tx.TXQueryExecer.Commit(ctx)
+}
+
+func (tx *releasingTx) Rollback(ctx context.Context) error {
+	defer tx.release()
+	return tx.TXQueryExecer.Rollback(ctx)
+}
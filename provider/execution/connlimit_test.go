@@ -0,0 +1,135 @@
+package execution
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connCountingStorage counts the operations holding a connection at a time, and the most seen at once
+type connCountingStorage struct {
+	noopStorage
+	active, max int64
+}
+
+func (s *connCountingStorage) acquire() {
+	active := atomic.AddInt64(&s.active, 1)
+	for {
+		max := atomic.LoadInt64(&s.max)
+		if active <= max || atomic.CompareAndSwapInt64(&s.max, max, active) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+}
+
+func (s *connCountingStorage) release() {
+	atomic.AddInt64(&s.active, -1)
+}
+
+func (s *connCountingStorage) Insert(context.Context, *schema.Table, schema.Resources, bool, map[string]interface{}) error {
+	s.acquire()
+	defer s.release()
+	return nil
+}
+
+func (s *connCountingStorage) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	s.acquire()
+	return &countedRows{storage: s}, nil
+}
+
+func (s *connCountingStorage) Begin(context.Context) (TXQueryExecer, error) {
+	s.acquire()
+	return &countedTx{storage: s}, nil
+}
+
+type countedRows struct {
+	pgx.Rows
+	storage *connCountingStorage
+	closed  bool
+}
+
+func (r *countedRows) Next() bool {
+	r.Close()
+	return false
+}
+
+func (r *countedRows) Close() {
+	if !r.closed {
+		r.closed = true
+		r.storage.release()
+	}
+}
+
+type countedTx struct {
+	TXQueryExecer
+	storage *connCountingStorage
+}
+
+func (tx *countedTx) Commit(context.Context) error {
+	tx.storage.release()
+	return nil
+}
+
+func TestLimitConnections(t *testing.T) {
+	const (
+		maxConnections = 3
+		parallelism    = 50
+	)
+	storage := &connCountingStorage{}
+	assert.Same(t, Storage(storage), LimitConnections(storage, 0))
+
+	limited := LimitConnections(storage, maxConnections)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, limited.Insert(ctx, nil, nil, false, nil))
+		}()
+		go func() {
+			defer wg.Done()
+			rows, err := limited.Query(ctx, "SELECT 1")
+			// require can't stop the test from a goroutine other than the test's
+			if !assert.NoError(t, err) {
+				return
+			}
+			for rows.Next() {
+			}
+			// closing rows read to the end releases nothing more
+			rows.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			tx, err := limited.Begin(ctx)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NoError(t, tx.Commit(ctx))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(0), atomic.LoadInt64(&storage.active))
+	assert.LessOrEqual(t, atomic.LoadInt64(&storage.max), int64(maxConnections))
+
+	// operations waiting for a connection give up once their context is done
+	tx, err := limited.Begin(ctx)
+	require.NoError(t, err)
+	for i := 1; i < maxConnections; i++ {
+		_, err := limited.Begin(ctx)
+		require.NoError(t, err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, limited.Exec(canceled, "SELECT 1"), context.Canceled)
+	assert.NoError(t, tx.Commit(ctx))
+	assert.NoError(t, limited.Exec(ctx, "SELECT 1"))
+}
@@ -1,6 +1,7 @@
 package execution
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/cloudquery/cq-provider-sdk/provider/diag"
@@ -12,6 +13,25 @@ const (
 	fdLimitMessage = "try increasing number of available file descriptors via `ulimit -n 10240` or by increasing timeout via provider specific parameters"
 )
 
+// ErrConditionNotMet is the error of the IGNORE diagnostic returned when a table is skipped since its schema.Table Condition returned false
+var ErrConditionNotMet = errors.New("table condition not met")
+
+// ColumnResolveError is the error of the WARNING diagnostic returned when the resolver of a column with
+// schema.Column IgnoreError fails, and the column is set to NULL
+type ColumnResolveError struct {
+	Table  string
+	Column string
+	Err    error
+}
+
+func (e ColumnResolveError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ColumnResolveError) Unwrap() error {
+	return e.Err
+}
+
 type ErrorClassifier func(meta schema.ClientMeta, resourceName string, err error) diag.Diagnostics
 
 func defaultErrorClassifier(_ schema.ClientMeta, resourceName string, err error) diag.Diagnostics {
@@ -40,7 +60,7 @@ func WithResource(resource *schema.Resource) diag.BaseErrorOption {
 	if resource == nil {
 		return diag.WithResourceId(nil)
 	}
-	return diag.WithResourceId(resource.PrimaryKeyValues())
+	return diag.WithResourceId(resource.ResourceID())
 }
 
 func fromError(err error, opts ...diag.BaseErrorOption) diag.Diagnostics {
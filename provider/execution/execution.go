@@ -2,6 +2,7 @@ package execution
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime/debug"
 	"strconv"
@@ -50,10 +51,104 @@ type TableExecutor struct {
 	goroutinesSem *semaphore.Weighted
 	// timeout for each parent resource resolve call
 	timeout time.Duration
+	// config is the provider's decoded configuration passed to schema.Table Condition
+	config interface{}
+	// resolverMiddleware wraps the table resolvers called by the executor, the first one being the outermost
+	resolverMiddleware []schema.ResolverMiddleware
+	// maxItems limits the items resolved by the top level table if more than 0, itemCount counts them across clients
+	maxItems  uint64
+	itemCount *uint64
+	// totalRows limits the rows resolved by all tables of the fetch, shared by the executors of all resources
+	totalRows *TotalRowsLimit
+	// propagatePanics disables recovering resolver panics into diagnostics, see WithoutPanicRecovery
+	propagatePanics bool
+}
+
+// TotalRowsLimit limits the rows resolved across all tables and relations of a fetch, shared by the executors of all
+// its resources. It's a safety valve against runaway resolvers, as opposed to WithMaxItems limiting each resource.
+type TotalRowsLimit struct {
+	max      uint64
+	count    uint64
+	exceeded uint32
+}
+
+// NewTotalRowsLimit returns a limit of max rows, or nil if max isn't positive
+func NewTotalRowsLimit(max int) *TotalRowsLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &TotalRowsLimit{max: uint64(max)}
+}
+
+// Exceeded returns whether any table tried resolving more rows than the limit
+func (l *TotalRowsLimit) Exceeded() bool {
+	return atomic.LoadUint32(&l.exceeded) == 1
+}
+
+// reserve reserves up to n of the rows left until the limit, returning the amount reserved and whether this call was
+// the first to exceed the limit
+func (l *TotalRowsLimit) reserve(n int) (int, bool) {
+	reserved := reserve(&l.count, l.max, n)
+	if reserved == n {
+		return reserved, false
+	}
+	return reserved, atomic.CompareAndSwapUint32(&l.exceeded, 0, 1)
+}
+
+// TableExecutorOption allows setting optional parameters of a TableExecutor
+type TableExecutorOption func(*TableExecutor)
+
+// WithConfig sets the provider configuration passed to schema.Table Condition
+func WithConfig(config interface{}) TableExecutorOption {
+	return func(e *TableExecutor) {
+		e.config = config
+	}
+}
+
+// WithClock sets the clock the execution start time is taken from, defaults to the wall clock
+func WithClock(clock schema.Clock) TableExecutorOption {
+	return func(e *TableExecutor) {
+		e.executionStart = clock.Now().Add(executionJitter)
+	}
+}
+
+// WithMaxItems limits the items resolved by the top level table to max, across all its clients. Once reached the table
+// resolvers are canceled, discarding the rest of their items, and an IGNORE diagnostic notes the truncation.
+func WithMaxItems(max int) TableExecutorOption {
+	return func(e *TableExecutor) {
+		if max > 0 {
+			e.maxItems = uint64(max)
+			e.itemCount = new(uint64)
+		}
+	}
+}
+
+// WithTotalRowsLimit limits the rows resolved by the table and its relations, counted together with all other
+// executors sharing limit. Once exceeded the table resolvers are canceled, discarding the rest of their items, and an
+// ERROR diagnostic reports the limit was exceeded.
+func WithTotalRowsLimit(limit *TotalRowsLimit) TableExecutorOption {
+	return func(e *TableExecutor) {
+		e.totalRows = limit
+	}
+}
+
+// WithoutPanicRecovery lets panics of table and column resolvers propagate and crash the process with their stack,
+// rather than recovering them into PANIC diagnostics, e.g. for debugging a resolver locally.
+func WithoutPanicRecovery() TableExecutorOption {
+	return func(e *TableExecutor) {
+		e.propagatePanics = true
+	}
+}
+
+// WithResolverMiddleware sets the middleware wrapping every table resolver called by the executor
+func WithResolverMiddleware(middleware ...schema.ResolverMiddleware) TableExecutorOption {
+	return func(e *TableExecutor) {
+		e.resolverMiddleware = middleware
+	}
 }
 
 // NewTableExecutor creates a new TableExecutor for given schema.Table
-func NewTableExecutor(resourceName string, db Storage, logger hclog.Logger, table *schema.Table, extraFields, metadata map[string]interface{}, classifier ErrorClassifier, goroutinesSem *semaphore.Weighted, timeout time.Duration) TableExecutor {
+func NewTableExecutor(resourceName string, db Storage, logger hclog.Logger, table *schema.Table, extraFields, metadata map[string]interface{}, classifier ErrorClassifier, goroutinesSem *semaphore.Weighted, timeout time.Duration, opts ...TableExecutorOption) TableExecutor {
 	var classifiers = []ErrorClassifier{defaultErrorClassifier}
 	if classifier != nil {
 		classifiers = append([]ErrorClassifier{classifier}, classifiers...)
@@ -61,7 +156,7 @@ func NewTableExecutor(resourceName string, db Storage, logger hclog.Logger, tabl
 	var c [2]schema.ColumnList
 	c[0], c[1] = db.Dialect().Columns(table).Sift()
 
-	return TableExecutor{
+	e := TableExecutor{
 		ResourceName:   resourceName,
 		Table:          table,
 		Db:             db,
@@ -74,10 +169,23 @@ func NewTableExecutor(resourceName string, db Storage, logger hclog.Logger, tabl
 		goroutinesSem:  goroutinesSem,
 		timeout:        timeout,
 	}
+	for _, o := range opts {
+		o(&e)
+	}
+	return e
 }
 
 // Resolve is the root function of table executor which starts an execution of a Table resolving it, and it's relations.
+// It's recorded by the ConcurrencyTracker ctx carries, if any.
 func (e TableExecutor) Resolve(ctx context.Context, meta schema.ClientMeta) (uint64, diag.Diagnostics) {
+	tracker := ConcurrencyTrackerFromContext(ctx)
+	tracker.start()
+	defer tracker.done()
+
+	if diags := e.checkCondition(ctx); diags != nil {
+		return 0, diags
+	}
+
 	var clients []schema.ClientMeta
 
 	clients = append(clients, meta)
@@ -172,6 +280,16 @@ func (e TableExecutor) doMultiplexResolve(ctx context.Context, clients []schema.
 	return totalResources, allDiags
 }
 
+// checkCondition returns an IGNORE diagnostic if the table's Condition isn't met, and it should be skipped
+func (e TableExecutor) checkCondition(ctx context.Context) diag.Diagnostics {
+	if e.Table.Condition == nil || e.Table.Condition(ctx, e.config) {
+		return nil
+	}
+	e.Logger.Debug("skipping table, condition not met")
+	return diag.Diagnostics{diag.NewBaseError(ErrConditionNotMet, diag.RESOLVING, diag.WithResourceName(e.ResourceName), diag.WithSeverity(diag.IGNORE),
+		diag.WithSummary("table %q skipped", e.Table.Name))}
+}
+
 // truncateTable cleans up a table from all data based on it's DeleteFilter
 func (e TableExecutor) truncateTable(ctx context.Context, client schema.ClientMeta, parent *schema.Resource) error {
 	if e.Table.DeleteFilter == nil {
@@ -227,18 +345,46 @@ func (e TableExecutor) callTableResolve(ctx context.Context, client schema.Clien
 	res := make(chan interface{})
 	var resolverErr error
 
+	resolverCtx := ctx
+	if e.Table.FetchTimeout > 0 {
+		var cancel context.CancelFunc
+		resolverCtx, cancel = context.WithTimeout(ctx, e.Table.FetchTimeout)
+		defer cancel()
+	}
+	// cancelResolver stops the resolver once the items limit is reached
+	resolverCtx, cancelResolver := context.WithCancel(resolverCtx)
+	defer cancelResolver()
+	limited := parent == nil && e.maxItems > 0
+	truncated := false
+
 	// we are not using goroutinesSem semaphore here as it's just a +1 goroutine and it might get us deadlocked
 	go func() {
 		defer func() {
+			if e.propagatePanics {
+				close(res)
+				return
+			}
 			if r := recover(); r != nil {
 				stack := string(debug.Stack())
 				e.Logger.Error("table resolver recovered from panic", "stack", stack)
-				resolverErr = diag.NewBaseError(fmt.Errorf("table resolver panic: %s", r), diag.RESOLVING, diag.WithResourceName(e.ResourceName), diag.WithSeverity(diag.PANIC),
+				// the parent's key tells which parent item a relation's resolver panicked on
+				resolverErr = diag.NewBaseError(fmt.Errorf("table resolver panic: %s", r), diag.RESOLVING, diag.WithResourceName(e.ResourceName), WithResource(parent), diag.WithSeverity(diag.PANIC),
 					diag.WithSummary("panic on resource table %q fetch", e.Table.Name), diag.WithDetails("%s", stack))
 			}
 			close(res)
 		}()
-		if err := e.Table.Resolver(ctx, client, parent, res); err != nil {
+		err := e.tableResolver()(resolverCtx, client, parent, res)
+		// the table's own FetchTimeout was exceeded, as opposed to the whole fetch being cancelled
+		if e.Table.FetchTimeout > 0 && ctx.Err() == nil && errors.Is(resolverCtx.Err(), context.DeadlineExceeded) {
+			resolverErr = diag.NewBaseError(resolverCtx.Err(), diag.RESOLVING, diag.WithResourceName(e.ResourceName), WithResource(parent), diag.WithSeverity(diag.ERROR),
+				diag.WithSummary("table %q resolver exceeded fetch timeout of %s", e.Table.Name, e.Table.FetchTimeout))
+			return
+		}
+		// the resolver was canceled because the items limit was reached, its error is expected
+		if ctx.Err() == nil && errors.Is(resolverCtx.Err(), context.Canceled) {
+			return
+		}
+		if err != nil {
 			if e.IgnoreError(err) {
 				e.Logger.Debug("ignored an error", "err", err)
 				err = diag.NewBaseError(err, diag.RESOLVING, diag.WithSeverity(diag.IGNORE), diag.WithSummary("table %q resolver ignored error", e.Table.Name))
@@ -249,7 +395,38 @@ func (e TableExecutor) callTableResolve(ctx context.Context, client schema.Clien
 
 	nc := uint64(0)
 	for elem := range res {
+		// resolvers may report diagnostics of items they skipped without failing the whole fetch
+		switch d := elem.(type) {
+		case diag.Diagnostic:
+			diags = diags.Add(e.handleResolveError(client, parent, d))
+			continue
+		case diag.Diagnostics:
+			diags = diags.Add(e.handleResolveError(client, parent, d))
+			continue
+		}
 		objects := helpers.InterfaceSlice(elem)
+		if limited {
+			if reserved := e.reserveItems(len(objects)); reserved < len(objects) {
+				objects = objects[:reserved]
+				if !truncated {
+					truncated = true
+					cancelResolver()
+					diags = diags.Add(diag.NewBaseError(nil, diag.RESOLVING, diag.WithResourceName(e.ResourceName), diag.WithSeverity(diag.IGNORE),
+						diag.WithSummary("table %q fetch truncated to %d items", e.Table.Name, e.maxItems)))
+				}
+			}
+		}
+		if e.totalRows != nil && len(objects) > 0 {
+			reserved, first := e.totalRows.reserve(len(objects))
+			if reserved < len(objects) {
+				objects = objects[:reserved]
+				cancelResolver()
+			}
+			if first {
+				diags = diags.Add(diag.NewBaseError(nil, diag.RESOLVING, diag.WithResourceName(e.ResourceName), diag.WithSeverity(diag.ERROR),
+					diag.WithSummary("table %q exceeded the limit of %d total rows across all tables, aborting fetch", e.Table.Name, e.totalRows.max)))
+			}
+		}
 		if len(objects) == 0 {
 			continue
 		}
@@ -281,23 +458,91 @@ func (e TableExecutor) callTableResolve(ctx context.Context, client schema.Clien
 	return nc, diags
 }
 
+// reserveItems reserves up to n of the items left until maxItems, returning the amount reserved
+func (e TableExecutor) reserveItems(n int) int {
+	return reserve(e.itemCount, e.maxItems, n)
+}
+
+// reserve adds up to n to counter without exceeding max, returning the amount added
+func reserve(counter *uint64, max uint64, n int) int {
+	for {
+		count := atomic.LoadUint64(counter)
+		if count >= max {
+			return 0
+		}
+		reserved := uint64(n)
+		if left := max - count; reserved > left {
+			reserved = left
+		}
+		if atomic.CompareAndSwapUint64(counter, count, count+reserved) {
+			return int(reserved)
+		}
+	}
+}
+
+// tableResolver returns the table's resolver wrapped by the executor's resolver middleware
+func (e TableExecutor) tableResolver() schema.TableResolver {
+	resolver := e.Table.Resolver
+	for i := len(e.resolverMiddleware) - 1; i >= 0; i-- {
+		resolver = e.resolverMiddleware[i](e.Table, resolver)
+	}
+	return resolver
+}
+
 // resolveResources resolves a list of resource objects inserting them into the database and resolving their relations based on the table.
 func (e TableExecutor) resolveResources(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, objects []interface{}) (uint64, diag.Diagnostics) {
 	var (
-		resources = make(schema.Resources, 0, len(objects))
-		diags     diag.Diagnostics
+		resolved = make([]*schema.Resource, len(objects))
+		diags    diag.Diagnostics
+		lock     sync.Mutex
 	)
 
-	for i := range objects {
+	resolve := func(i int) {
 		resource := schema.NewResourceData(e.Db.Dialect(), e.Table, parent, objects[i], e.metadata, e.executionStart)
 		// Before inserting resolve all table column resolvers
 		resolveDiags := e.resolveResourceValues(ctx, meta, resource)
+		lock.Lock()
 		diags = diags.Add(resolveDiags)
+		lock.Unlock()
 		if resolveDiags.HasErrors() {
 			e.Logger.Warn("skipping failed resolved resource", "reason", resolveDiags.Error())
-			continue
+			return
+		}
+		resolved[i] = resource
+	}
+
+	if workers := e.Table.ItemConcurrency; workers > 1 && len(objects) > 1 {
+		if workers > len(objects) {
+			workers = len(objects)
+		}
+		indices := make(chan int)
+		wg := &sync.WaitGroup{}
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indices {
+					resolve(i)
+				}
+			}()
+		}
+		for i := range objects {
+			indices <- i
+		}
+		close(indices)
+		wg.Wait()
+	} else {
+		for i := range objects {
+			resolve(i)
+		}
+	}
+
+	// keep the order the items were sent in, skipping the failed ones
+	resources := make(schema.Resources, 0, len(objects))
+	for _, resource := range resolved {
+		if resource != nil {
+			resources = append(resources, resource)
 		}
-		resources = append(resources, resource)
 	}
 
 	// only top level tables should cascade
@@ -309,10 +554,18 @@ func (e TableExecutor) resolveResources(ctx context.Context, meta schema.ClientM
 
 	// Finally, resolve relations of each resource
 	for _, rel := range e.Table.Relations {
+		if len(resources) == 0 {
+			break
+		}
+		relExecutor := e.withTable(rel)
+		if condDiags := relExecutor.checkCondition(ctx); condDiags != nil {
+			diags = diags.Add(condDiags)
+			continue
+		}
 		e.Logger.Debug("resolving table relation", "relation", rel.Name)
 		for _, r := range resources {
 			// ignore relation resource count
-			if _, innerDiags := e.withTable(rel).callTableResolve(ctx, meta, r); innerDiags.HasDiags() {
+			if _, innerDiags := relExecutor.callTableResolve(ctx, meta, r); innerDiags.HasDiags() {
 				diags = diags.Add(innerDiags)
 			}
 		}
@@ -358,10 +611,13 @@ func (e TableExecutor) saveToStorage(ctx context.Context, resources schema.Resou
 // resolveResourceValues does the actual resolve of all the columns of table for said resource.
 func (e TableExecutor) resolveResourceValues(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource) (diags diag.Diagnostics) {
 	defer func() {
+		if e.propagatePanics {
+			return
+		}
 		if r := recover(); r != nil {
 			stack := string(debug.Stack())
 			e.Logger.Error("resolve table recovered from panic", "panic_msg", r, "stack", stack)
-			diags = fromError(fmt.Errorf("column resolve panic: %s", r), diag.WithResourceName(e.ResourceName), diag.WithSeverity(diag.PANIC),
+			diags = fromError(fmt.Errorf("column resolve panic: %s", r), diag.WithResourceName(e.ResourceName), WithResource(resource), diag.WithSeverity(diag.PANIC),
 				diag.WithSummary("resolve table %q recovered from panic", e.Table.Name), diag.WithDetails("%s", stack))
 		}
 	}()
@@ -371,6 +627,15 @@ func (e TableExecutor) resolveResourceValues(ctx context.Context, meta schema.Cl
 		return diags
 	}
 
+	for i, resolver := range e.Table.MultiColumnResolvers {
+		if err := resolver(ctx, meta, resource, resource.Item); err != nil {
+			diags = diags.Add(e.handleResolveError(meta, resource, err, diag.WithSummary("multi column resolver %d failed for table %q", i, e.Table.Name)))
+			if diags.HasErrors() {
+				return diags
+			}
+		}
+	}
+
 	// call PostRowResolver if defined after columns have been resolved
 	if e.Table.PostResourceResolver != nil {
 		if err := e.Table.PostResourceResolver(ctx, meta, resource); err != nil {
@@ -387,6 +652,22 @@ func (e TableExecutor) resolveResourceValues(ctx context.Context, meta schema.Cl
 			return diags.Add(fromError(err, diag.WithResourceName(e.ResourceName), WithResource(resource), diag.WithType(diag.INTERNAL), diag.WithSummary("default column %q resolver execution", c.Name)))
 		}
 	}
+	return diags.Add(e.validateResourceValues(resource))
+}
+
+// validateResourceValues checks the resolved value of each column matches the column's type before the resource is
+// inserted, so a mismatch is reported for the resource and column rather than as a failed insert of the whole batch.
+func (e TableExecutor) validateResourceValues(resource *schema.Resource) (diags diag.Diagnostics) {
+	for _, c := range e.Table.Columns {
+		// columns without a type have nothing to be checked against
+		if c.Type == schema.TypeInvalid {
+			continue
+		}
+		if err := c.ValidateType(resource.Get(c.Name)); err != nil {
+			diags = diags.Add(fromError(err, diag.WithResourceName(e.ResourceName), WithResource(resource), diag.WithType(diag.RESOLVING),
+				diag.WithSeverity(diag.ERROR), diag.WithSummary("invalid value for column %s@%s", e.Table.Name, c.Name)))
+		}
+	}
 	return diags
 }
 
@@ -395,10 +676,14 @@ func (e TableExecutor) resolveColumns(ctx context.Context, meta schema.ClientMet
 	var col string
 
 	defer func() {
+		if e.propagatePanics {
+			return
+		}
 		if r := recover(); r != nil {
 			stack := string(debug.Stack())
 			e.Logger.Error("resolve columns recovered from panic", "panic_msg", r, "stack", stack, "column_name", col)
-			diags = fromError(fmt.Errorf("column resolve panic: %s", r), diag.WithResourceName(e.ResourceName), diag.WithSeverity(diag.PANIC),
+			// the item's key is only known if its primary key columns were resolved before the panic
+			diags = fromError(fmt.Errorf("column resolve panic: %s", r), diag.WithResourceName(e.ResourceName), WithResource(resource), diag.WithSeverity(diag.PANIC),
 				diag.WithSummary("resolve column %q in table %q recovered from panic", col, e.Table.Name), diag.WithDetails("%s", stack))
 		}
 	}()
@@ -415,12 +700,26 @@ func (e TableExecutor) resolveColumns(ctx context.Context, meta schema.ClientMet
 			if funk.ContainsString(e.Db.Dialect().PrimaryKeys(e.Table), c.Name) {
 				return diags.Add(ClassifyError(err, diag.WithResourceName(e.ResourceName), WithResource(resource), diag.WithSummary("failed to resolve column %s@%s", e.Table.Name, c.Name)))
 			}
+			if c.IgnoreError {
+				e.Logger.Debug("column resolver failed, setting column to NULL", "column", c.Name, "error", err)
+				if err := resource.Set(c.Name, nil); err != nil {
+					return diags.Add(fromError(err, diag.WithResourceName(e.ResourceName), diag.WithType(diag.INTERNAL),
+						diag.WithSummary("failed to set resource value for column %s@%s", e.Table.Name, c.Name)))
+				}
+				diags = diags.Add(fromError(ColumnResolveError{Table: e.Table.Name, Column: c.Name, Err: err}, diag.WithResourceName(e.ResourceName), WithResource(resource),
+					diag.WithType(diag.RESOLVING), diag.WithSeverity(diag.WARNING), diag.WithSummary("column resolver %q failed for table %q, column set to NULL", c.Name, e.Table.Name)))
+				continue
+			}
 			diags = diags.Add(e.handleResolveError(meta, resource, err, diag.WithSummary("column resolver %q failed for table %q", c.Name, e.Table.Name)))
 			continue
 		}
-		e.Logger.Trace("resolving column value with path", "column", c.Name)
-		// base use case: try to get column with CamelCase name
-		v := funk.Get(resource.Item, strcase.ToCamel(c.Name), funk.WithAllowZero())
+		// base use case: try to get column with CamelCase name, unless the column names its source key
+		path := strcase.ToCamel(c.Name)
+		if c.SourceKey != "" {
+			path = c.SourceKey
+		}
+		e.Logger.Trace("resolving column value with path", "column", c.Name, "path", path)
+		v := funk.Get(resource.Item, path, funk.WithAllowZero())
 		e.Logger.Trace("setting column value", "column", c.Name, "value", v)
 		if err := resource.Set(c.Name, v); err != nil {
 			diags = diags.Add(fromError(err, diag.WithResourceName(e.ResourceName), diag.WithType(diag.INTERNAL),
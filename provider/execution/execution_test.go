@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -24,6 +26,7 @@ type ExecutionTestCase struct {
 	Name        string
 	Table       *schema.Table
 	ExtraFields map[string]interface{}
+	Options     []TableExecutorOption
 
 	SetupStorage          func(t *testing.T) Storage
 	ExpectedResourceCount uint64
@@ -110,6 +113,15 @@ var (
 			panic("timeoutResolver timed out unexpectedly")
 		}
 	}
+	slowResolver = func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+		res <- map[string]string{"name": "test"}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
 	testZeroTable = &schema.Table{
 		Name: "test_zero_table",
 		Columns: []schema.Column{
@@ -261,9 +273,13 @@ func TestTableExecutor_Resolve(t *testing.T) {
 		{
 			Name: "panic_relation_resolver",
 			Table: &schema.Table{
-				Name:     "panic_resolver",
-				Resolver: returnValueResolver,
-				Columns:  commonColumns,
+				Name: "panic_resolver",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- struct{ Name string }{Name: "parent"}
+					return nil
+				},
+				Options: schema.TableCreationOptions{PrimaryKeys: []string{"name"}},
+				Columns: commonColumns,
 				Relations: []*schema.Table{
 					{
 						Name:     "relation_panic_resolver",
@@ -276,11 +292,59 @@ func TestTableExecutor_Resolve(t *testing.T) {
 			ErrorExpected:         true,
 			ExpectedDiags: []diag.FlatDiag{
 				{
-					Err:      "table resolver panic: resolver panic",
-					Resource: "panic_relation_resolver",
-					Severity: diag.PANIC,
-					Summary:  `panic on resource table "relation_panic_resolver" fetch: table resolver panic: resolver panic`,
-					Type:     diag.RESOLVING,
+					Err:        "table resolver panic: resolver panic",
+					Resource:   "panic_relation_resolver",
+					ResourceID: []string{"parent"},
+					Severity:   diag.PANIC,
+					Summary:    `panic on resource table "relation_panic_resolver" fetch: table resolver panic: resolver panic`,
+					Type:       diag.RESOLVING,
+				},
+			},
+		},
+		{
+			Name: "resource_id",
+			Table: &schema.Table{
+				Name: "resource_id",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- struct{ Name string }{Name: "parent"}
+					return nil
+				},
+				Options: schema.TableCreationOptions{PrimaryKeys: []string{"name"}},
+				PostResourceResolver: func(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource) error {
+					resource.SetResourceID("arn:parent")
+					return nil
+				},
+				Columns: commonColumns,
+				Relations: []*schema.Table{
+					{
+						Name: "relation_resource_id",
+						Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+							res <- struct{ Name string }{Name: "child"}
+							return nil
+						},
+						Columns: schema.ColumnList{
+							{
+								Name: "name",
+								Type: schema.TypeString,
+								Resolver: func(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource, c schema.Column) error {
+									resource.SetResourceID("child-1")
+									return errors.New("some error")
+								},
+							},
+						},
+					},
+				},
+			},
+			ExpectedResourceCount: 1,
+			ErrorExpected:         true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:        "some error",
+					Resource:   "resource_id",
+					ResourceID: []string{"arn:parent", "child-1"},
+					Severity:   diag.ERROR,
+					Summary:    `column resolver "name" failed for table "relation_resource_id": some error`,
+					Type:       diag.RESOLVING,
 				},
 			},
 		},
@@ -537,6 +601,86 @@ func TestTableExecutor_Resolve(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "invalid_column_value",
+			SetupStorage: func(t *testing.T) Storage {
+				db := new(DatabaseMock)
+				db.On("RemoveStaleData", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				db.On("Dialect").Return(noopDialect{})
+				db.On("CopyFrom", mock.Anything, mock.MatchedBy(func(resources schema.Resources) bool {
+					return len(resources) == 0
+				}), true, map[string]interface{}(nil)).Return(nil)
+				return db
+			},
+			Table: &schema.Table{
+				Name: "column",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- struct{ Name string }{Name: "test"}
+					return nil
+				},
+				Columns: schema.ColumnList{
+					{
+						Name: "name",
+						Type: schema.TypeBigInt,
+					},
+				},
+			},
+			ErrorExpected: true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:      "column name expected TypeBigInt got string",
+					Resource: "invalid_column_value",
+					Severity: diag.ERROR,
+					Type:     diag.RESOLVING,
+					Summary:  "invalid value for column column@name: column name expected TypeBigInt got string",
+				},
+			},
+		},
+		{
+			Name: "ignore_error_column",
+			SetupStorage: func(t *testing.T) Storage {
+				db := new(DatabaseMock)
+				db.On("RemoveStaleData", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				db.On("Dialect").Return(noopDialect{})
+				db.On("CopyFrom", mock.Anything, mock.MatchedBy(func(resources schema.Resources) bool {
+					return len(resources) == 1 && resources[0].Get("name") == "test" && resources[0].Get("failing") == nil
+				}), true, map[string]interface{}(nil)).Return(nil)
+				return db
+			},
+			Table: &schema.Table{
+				Name: "column",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- struct{ Name string }{Name: "test"}
+					return nil
+				},
+				Columns: schema.ColumnList{
+					{
+						Name: "name",
+					},
+					{
+						Name: "failing",
+						Resolver: func(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource, c schema.Column) error {
+							if err := resource.Set(c.Name, "partial"); err != nil {
+								return err
+							}
+							return fmt.Errorf("failed column")
+						},
+						IgnoreError: true,
+					},
+				},
+			},
+			ExpectedResourceCount: 1,
+			ErrorExpected:         true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:      "failed column",
+					Resource: "ignore_error_column",
+					Severity: diag.WARNING,
+					Type:     diag.RESOLVING,
+					Summary:  `column resolver "failing" failed for table "column", column set to NULL: failed column`,
+				},
+			},
+		},
 		{
 			Name: "internal_column",
 			Table: &schema.Table{
@@ -582,6 +726,234 @@ func TestTableExecutor_Resolve(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "table_fetch_timeout",
+			Table: &schema.Table{
+				Name:         "slow_resolver",
+				Resolver:     slowResolver,
+				FetchTimeout: 100 * time.Millisecond,
+				Columns:      commonColumns,
+			},
+			ExpectedResourceCount: 0,
+			ErrorExpected:         true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:      "context deadline exceeded",
+					Resource: "table_fetch_timeout",
+					Severity: diag.ERROR,
+					Summary:  `table "slow_resolver" resolver exceeded fetch timeout of 100ms: context deadline exceeded`,
+					Type:     diag.RESOLVING,
+				},
+			},
+		},
+		{
+			Name: "resolver_middleware",
+			Table: &schema.Table{
+				Name:     "simple",
+				Resolver: returnErrorResolver,
+				Columns:  commonColumns,
+				Relations: []*schema.Table{
+					{
+						Name:     "simple_relation",
+						Resolver: returnErrorResolver,
+						Columns:  commonColumns,
+					},
+				},
+			},
+			Options: []TableExecutorOption{
+				WithResolverMiddleware(func(t *schema.Table, next schema.TableResolver) schema.TableResolver {
+					return func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+						if err := next(ctx, meta, parent, res); err != nil {
+							res <- map[string]string{"name": t.Name}
+						}
+						return nil
+					}
+				}),
+			},
+			ExpectedResourceCount: 1,
+		},
+		{
+			Name: "resolver_item_diagnostic",
+			Table: &schema.Table{
+				Name: "simple",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- map[string]string{"name": "test"}
+					res <- diag.NewBaseError(fmt.Errorf("malformed item"), diag.RESOLVING, diag.WithSeverity(diag.WARNING), diag.WithResourceId([]string{"item-2"}))
+					return nil
+				},
+				Columns: commonColumns,
+			},
+			ExpectedResourceCount: 1,
+			ErrorExpected:         true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:        "malformed item",
+					Resource:   "resolver_item_diagnostic",
+					ResourceID: []string{"item-2"},
+					Severity:   diag.WARNING,
+					Summary:    `failed to resolve table "simple": malformed item`,
+					Type:       diag.RESOLVING,
+				},
+			},
+		},
+		{
+			Name: "max_items",
+			Table: &schema.Table{
+				Name: "simple",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- []map[string]string{{"name": "test"}, {"name": "test"}}
+					for i := 0; i < 10; i++ {
+						select {
+						case res <- map[string]string{"name": "test"}:
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+					}
+					return nil
+				},
+				Columns: commonColumns,
+			},
+			Options:               []TableExecutorOption{WithMaxItems(3)},
+			ExpectedResourceCount: 3,
+			ErrorExpected:         true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:      `table "simple" fetch truncated to 3 items`,
+					Resource: "max_items",
+					Severity: diag.IGNORE,
+					Summary:  `table "simple" fetch truncated to 3 items`,
+					Type:     diag.RESOLVING,
+				},
+			},
+		},
+		{
+			Name: "max_total_rows",
+			Table: &schema.Table{
+				Name: "simple",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- []map[string]string{{"name": "a"}, {"name": "b"}}
+					return nil
+				},
+				Columns: commonColumns,
+				Relations: []*schema.Table{
+					{
+						Name: "simple_relation",
+						Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+							for i := 0; i < 3; i++ {
+								select {
+								case res <- map[string]string{"name": "test"}:
+								case <-ctx.Done():
+									return ctx.Err()
+								}
+							}
+							return nil
+						},
+						Columns: commonColumns,
+					},
+				},
+			},
+			// the two items of the top level table leave room for two of the six items of the relations
+			Options:               []TableExecutorOption{WithTotalRowsLimit(NewTotalRowsLimit(4))},
+			ExpectedResourceCount: 2,
+			ErrorExpected:         true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:      `table "simple_relation" exceeded the limit of 4 total rows across all tables, aborting fetch`,
+					Resource: "max_total_rows",
+					Severity: diag.ERROR,
+					Summary:  `table "simple_relation" exceeded the limit of 4 total rows across all tables, aborting fetch`,
+					Type:     diag.RESOLVING,
+				},
+			},
+		},
+		{
+			Name: "item_concurrency",
+			SetupStorage: func(t *testing.T) Storage {
+				db := new(DatabaseMock)
+				db.On("RemoveStaleData", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				db.On("Dialect").Return(noopDialect{})
+				db.On("CopyFrom", mock.Anything, mock.MatchedBy(func(resources schema.Resources) bool {
+					for i, r := range resources {
+						if r.Get("index") != i {
+							return false
+						}
+					}
+					return len(resources) == 50
+				}), true, map[string]interface{}(nil)).Return(nil)
+				return db
+			},
+			Table: &schema.Table{
+				Name: "concurrent",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					items := make([]map[string]int, 50)
+					for i := range items {
+						items[i] = map[string]int{"index": i}
+					}
+					res <- items
+					return nil
+				},
+				ItemConcurrency: 8,
+				Columns: schema.ColumnList{
+					{
+						Name: "index",
+						Type: schema.TypeBigInt,
+						Resolver: func(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource, c schema.Column) error {
+							// later items resolve faster, so they'd be inserted first if the order wasn't kept
+							index := resource.Item.(map[string]int)["index"]
+							time.Sleep(time.Duration(50-index) * 100 * time.Microsecond)
+							return resource.Set(c.Name, index)
+						},
+					},
+				},
+			},
+			ExpectedResourceCount: 50,
+		},
+		{
+			Name: "condition_not_met",
+			Table: &schema.Table{
+				Name:      "conditional",
+				Resolver:  returnValueResolver,
+				Condition: func(ctx context.Context, config interface{}) bool { return false },
+				Columns:   commonColumns,
+			},
+			ErrorExpected: true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:      "table condition not met",
+					Resource: "condition_not_met",
+					Severity: diag.IGNORE,
+					Summary:  `table "conditional" skipped: table condition not met`,
+					Type:     diag.RESOLVING,
+				},
+			},
+		},
+		{
+			Name: "relation_condition_not_met",
+			Table: &schema.Table{
+				Name:     "conditional",
+				Resolver: returnValueResolver,
+				Columns:  commonColumns,
+				Relations: []*schema.Table{
+					{
+						Name:      "conditional_relation",
+						Resolver:  panicResolver,
+						Condition: func(ctx context.Context, config interface{}) bool { return config != nil },
+						Columns:   commonColumns,
+					},
+				},
+			},
+			ExpectedResourceCount: 1,
+			ErrorExpected:         true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:      "table condition not met",
+					Resource: "relation_condition_not_met",
+					Severity: diag.IGNORE,
+					Summary:  `table "conditional_relation" skipped: table condition not met`,
+					Type:     diag.RESOLVING,
+				},
+			},
+		},
 		{
 			Name: "panic_column",
 			Table: &schema.Table{
@@ -613,6 +985,42 @@ func TestTableExecutor_Resolve(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "panic_column_with_key",
+			Table: &schema.Table{
+				Name: "panic_column_table",
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+					res <- struct{ Name string }{Name: "item-1"}
+					return nil
+				},
+				Options: schema.TableCreationOptions{PrimaryKeys: []string{"name"}},
+				Columns: schema.ColumnList{
+					{
+						Name: "name",
+						Type: schema.TypeString,
+					},
+					{
+						Name: "tags",
+						Resolver: func(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource, c schema.Column) error {
+							var tags map[string]string
+							tags["key"] = "value"
+							return nil
+						},
+					},
+				},
+			},
+			ErrorExpected: true,
+			ExpectedDiags: []diag.FlatDiag{
+				{
+					Err:        "column resolve panic: assignment to entry in nil map",
+					Resource:   "panic_column_with_key",
+					ResourceID: []string{"item-1"},
+					Severity:   diag.PANIC,
+					Type:       diag.RESOLVING,
+					Summary:    `resolve column "tags" in table "panic_column_table" recovered from panic: column resolve panic: assignment to entry in nil map`,
+				},
+			},
+		},
 		{
 			Name: "ignore_error_recursive",
 			Table: &schema.Table{
@@ -653,7 +1061,7 @@ func TestTableExecutor_Resolve(t *testing.T) {
 				fmt.Println("debug")
 			}
 			limiter := semaphore.NewWeighted(int64(limit.GetMaxGoRoutines()))
-			exec := NewTableExecutor(tc.Name, storage, testlog.New(t), tc.Table, tc.ExtraFields, nil, nil, limiter, 10*time.Second)
+			exec := NewTableExecutor(tc.Name, storage, testlog.New(t), tc.Table, tc.ExtraFields, nil, nil, limiter, 10*time.Second, tc.Options...)
 			count, diags := exec.Resolve(context.Background(), executionClient)
 			assert.Equal(t, tc.ExpectedResourceCount, count)
 			if tc.ErrorExpected {
@@ -706,6 +1114,45 @@ func TestTableExecutor_resolveResourceValues(t *testing.T) {
 			},
 			ExpectedDiags: nil,
 		},
+		{
+			Name: "multi column resolver",
+			Table: &schema.Table{
+				Name: "multi_column",
+				Columns: []schema.Column{
+					{Name: "name", Type: schema.TypeString},
+					{Name: "first_name", Type: schema.TypeString},
+					{Name: "last_name", Type: schema.TypeString},
+				},
+				MultiColumnResolvers: []schema.MultiColumnResolver{
+					func(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource, row interface{}) error {
+						parts := strings.SplitN(row.(struct{ Name string }).Name, " ", 2)
+						if err := resource.Set("first_name", parts[0]); err != nil {
+							return err
+						}
+						return resource.Set("last_name", parts[1])
+					},
+				},
+			},
+			ResourceData:   struct{ Name string }{Name: "john doe"},
+			ExpectedValues: []interface{}{"john doe", "john", "doe"},
+		},
+		{
+			Name: "source key",
+			Table: &schema.Table{
+				Name: "source_key",
+				Columns: []schema.Column{
+					{Name: "creation_date", Type: schema.TypeString, SourceKey: "creationDate"},
+					{Name: "owner_id", Type: schema.TypeString, SourceKey: "owner.accountId"},
+					{Name: "name", Type: schema.TypeString},
+				},
+			},
+			ResourceData: map[string]interface{}{
+				"creationDate": "2022-01-01",
+				"owner":        map[string]interface{}{"accountId": "123"},
+				"Name":         "test",
+			},
+			ExpectedValues: []interface{}{"2022-01-01", "123", "test"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -739,3 +1186,49 @@ func TestTableExecutor_resolveResourceValues(t *testing.T) {
 		})
 	}
 }
+
+func TestTableExecutor_resolveResourceValues_WithoutPanicRecovery(t *testing.T) {
+	table := &schema.Table{
+		Name: "panic_column",
+		Columns: []schema.Column{
+			{
+				Name: "name",
+				Type: schema.TypeString,
+				Resolver: func(ctx context.Context, meta schema.ClientMeta, resource *schema.Resource, c schema.Column) error {
+					panic("column panic")
+				},
+			},
+		},
+	}
+	limiter := semaphore.NewWeighted(int64(limit.GetMaxGoRoutines()))
+	cl := executionClient{testlog.New(t)}
+
+	exec := NewTableExecutor("panic_column", noopStorage{}, testlog.New(t), table, nil, nil, nil, limiter, 0)
+	diags := exec.resolveResourceValues(context.Background(), cl, schema.NewResourceData(noopStorage{}.Dialect(), table, nil, nil, nil, exec.executionStart))
+	assert.Equal(t, uint64(1), diags.CountBySeverity(diag.PANIC, false))
+
+	exec = NewTableExecutor("panic_column", noopStorage{}, testlog.New(t), table, nil, nil, nil, limiter, 0, WithoutPanicRecovery())
+	assert.PanicsWithValue(t, "column panic", func() {
+		exec.resolveResourceValues(context.Background(), cl, schema.NewResourceData(noopStorage{}.Dialect(), table, nil, nil, nil, exec.executionStart))
+	})
+}
+
+func TestTableExecutor_resolveResourceValues_TypeResolver(t *testing.T) {
+	durationType := reflect.TypeOf(time.Duration(0))
+	schema.RegisterTypeResolver(durationType, func(v interface{}) (interface{}, error) {
+		return v.(time.Duration).Seconds(), nil
+	})
+	defer schema.RegisterTypeResolver(durationType, nil)
+
+	table := &schema.Table{
+		Name:    "type_resolver",
+		Columns: []schema.Column{{Name: "timeout", Type: schema.TypeFloat}},
+	}
+	limiter := semaphore.NewWeighted(int64(limit.GetMaxGoRoutines()))
+	exec := NewTableExecutor("type_resolver", noopStorage{}, testlog.New(t), table, nil, nil, nil, limiter, 0)
+	item := struct{ Timeout time.Duration }{Timeout: 2 * time.Minute}
+	r := schema.NewResourceData(noopStorage{}.Dialect(), table, nil, item, nil, exec.executionStart)
+	diags := exec.resolveResourceValues(context.Background(), executionClient{testlog.New(t)}, r)
+	assert.Empty(t, diags)
+	assert.Equal(t, 120.0, r.Get("timeout"))
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"encoding/json"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+)
+
+// jsonSchemaDraft is the JSON Schema dialect of the schemas returned by JSONSchema
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema describing the rows of a table
+type jsonSchema struct {
+	Schema          string                 `json:"$schema,omitempty"`
+	Title           string                 `json:"title,omitempty"`
+	Description     string                 `json:"description,omitempty"`
+	Type            interface{}            `json:"type,omitempty"`
+	Format          string                 `json:"format,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	MaxLength       int                    `json:"maxLength,omitempty"`
+	Enum            []interface{}          `json:"enum,omitempty"`
+	Items           *jsonSchema            `json:"items,omitempty"`
+	Properties      map[string]*jsonSchema `json:"properties,omitempty"`
+	Required        []string               `json:"required,omitempty"`
+}
+
+// JSONSchema returns a JSON Schema describing the rows of the table of each resource in the ResourceMap, keyed by
+// resource name. Every column is a property of the row object, required and non-nullable if it's created NOT NULL. Each
+// relation is a property named after its table, holding an array of its rows. Column types map to:
+//   - TypeBool to boolean, TypeSmallInt, TypeInt and TypeBigInt to integer, TypeFloat and TypeNumeric to number
+//   - TypeString, TypeInet, TypeCIDR and TypeMacAddr to string, limited by MaxLength if set
+//   - TypeUUID to string with format uuid, TypeTimestamp to string with format date-time
+//   - TypeByteArray to string with base64 content encoding
+//   - TypeJSON to object
+//   - TypeEnum to string restricted to its EnumValues
+//   - array types to arrays of their element's type
+//
+// Internal columns, such as cq_id, depend on the database dialect and aren't included.
+func (p *Provider) JSONSchema() map[string][]byte {
+	schemas := make(map[string][]byte, len(p.ResourceMap))
+	for name, table := range p.ResourceMap {
+		s := tableJSONSchema(table)
+		s.Schema = jsonSchemaDraft
+		// marshaling can't fail, the schema only holds strings, ints and slices or maps of them
+		data, _ := json.MarshalIndent(s, "", "  ")
+		schemas[name] = data
+	}
+	return schemas
+}
+
+// tableJSONSchema returns the schema of a row of table, with its relations nested as arrays
+func tableJSONSchema(table *schema.Table) *jsonSchema {
+	s := &jsonSchema{
+		Title:       table.Name,
+		Description: table.Description,
+		Type:        "object",
+		Properties:  make(map[string]*jsonSchema, len(table.Columns)+len(table.Relations)),
+	}
+	for _, c := range table.Columns {
+		s.Properties[c.Name] = columnJSONSchema(c)
+		if c.CreationOptions.NotNull {
+			s.Required = append(s.Required, c.Name)
+		}
+	}
+	for _, rel := range table.Relations {
+		s.Properties[rel.Name] = &jsonSchema{Type: "array", Items: tableJSONSchema(rel)}
+	}
+	return s
+}
+
+// columnJSONSchema returns the schema of the values of column c, which includes null unless c is created NOT NULL
+func columnJSONSchema(c schema.Column) *jsonSchema {
+	s := valueJSONSchema(c.Type)
+	s.Description = c.Description
+	switch c.Type {
+	case schema.TypeString:
+		s.MaxLength = c.MaxLength
+	case schema.TypeEnum:
+		for _, v := range c.EnumValues {
+			s.Enum = append(s.Enum, v)
+		}
+	}
+	if !c.CreationOptions.NotNull {
+		s.Type = []interface{}{s.Type, "null"}
+		if s.Enum != nil {
+			s.Enum = append(s.Enum, nil)
+		}
+	}
+	return s
+}
+
+// valueJSONSchema returns the schema of the values of type t
+func valueJSONSchema(t schema.ValueType) *jsonSchema {
+	switch t {
+	case schema.TypeBool:
+		return &jsonSchema{Type: "boolean"}
+	case schema.TypeSmallInt, schema.TypeInt, schema.TypeBigInt:
+		return &jsonSchema{Type: "integer"}
+	case schema.TypeFloat, schema.TypeNumeric:
+		return &jsonSchema{Type: "number"}
+	case schema.TypeUUID:
+		return &jsonSchema{Type: "string", Format: "uuid"}
+	case schema.TypeTimestamp:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case schema.TypeByteArray:
+		return &jsonSchema{Type: "string", ContentEncoding: "base64"}
+	case schema.TypeJSON:
+		return &jsonSchema{Type: "object"}
+	case schema.TypeStringArray, schema.TypeInetArray, schema.TypeCIDRArray, schema.TypeMacAddrArray:
+		return &jsonSchema{Type: "array", Items: &jsonSchema{Type: "string"}}
+	case schema.TypeIntArray:
+		return &jsonSchema{Type: "array", Items: &jsonSchema{Type: "integer"}}
+	case schema.TypeUUIDArray:
+		return &jsonSchema{Type: "array", Items: &jsonSchema{Type: "string", Format: "uuid"}}
+	default:
+		// TypeString, TypeInet, TypeCIDR, TypeMacAddr and TypeEnum
+		return &jsonSchema{Type: "string"}
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -17,6 +20,7 @@ import (
 	"github.com/cloudquery/cq-provider-sdk/provider/module"
 	"github.com/cloudquery/cq-provider-sdk/provider/schema"
 	"github.com/creasty/defaults"
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/hcl/v2/hclsimple"
 	"github.com/hashicorp/hcl/v2/hclwrite"
@@ -25,12 +29,24 @@ import (
 	"golang.org/x/sync/semaphore"
 )
 
+// SnakeCaseColumnNamePattern is the column naming convention used by CloudQuery providers
+const SnakeCaseColumnNamePattern = `^[a-z][a-z0-9]*(_[a-z0-9]+)*$`
+
 // Config Every provider implements a resources field we only want to extract that in fetch execution
 type Config interface {
 	// Example returns a configuration example (with comments) so user clients can generate an example config
 	Example() string
 }
 
+// ResourceSelector is optionally implemented by a provider's Config, allowing users to select which of the provider's
+// resources are fetched in their configuration.
+type ResourceSelector interface {
+	// AllowedResources returns the resources to fetch, empty means all resources
+	AllowedResources() []string
+	// DeniedResources returns the resources not to fetch, even if they're allowed
+	DeniedResources() []string
+}
+
 // Provider is the base structure required to pass and serve an sdk provider.Provider
 type Provider struct {
 	// Name of plugin i.e aws,gcp, azure etc'
@@ -51,10 +67,28 @@ type Provider struct {
 	ErrorClassifier execution.ErrorClassifier
 	// ModuleInfoReader is called when the user executes a module, to get provider supported metadata about the given module
 	ModuleInfoReader module.InfoReader
+	// ConfigDefaults is an HCL configuration decoded before the received configuration, so attributes omitted from the
+	// received configuration keep their values from it. Blocks given in the received configuration are merged attribute
+	// by attribute, except repeated blocks which are replaced.
+	ConfigDefaults string
+	// ResolverMiddleware wraps every table resolver called when fetching, the first middleware being the outermost.
+	ResolverMiddleware []schema.ResolverMiddleware
+	// Clock is passed to resolvers via their context, see schema.Now, and sets the fetch time of resources.
+	// Defaults to the wall clock, tests may set a fixed clock for deterministic values.
+	Clock schema.Clock
+	// DisablePanicRecovery lets resolver panics crash the provider with their stack instead of reporting them as PANIC
+	// diagnostics, see execution.WithoutPanicRecovery. Meant for debugging, a single panic stops the whole fetch.
+	DisablePanicRecovery bool
+	// RunID identifies this run of the provider in the application_name of its database connections, "cq-provider-"
+	// followed by the provider name and run id, making them easy to tell apart in pg_stat_activity, e.g. a CI job id.
+	// Defaults to a random id generated when the provider is configured.
+	RunID string
 	// Database connection string
 	dbURL string
 	// meta is the provider's client created when configure is called
 	meta schema.ClientMeta
+	// config is the provider's configuration decoded when configure is called
+	config interface{}
 	// Add extra fields to all resources, these fields don't show up in documentation and are used for internal CQ testing.
 	extraFields map[string]interface{}
 	// storageCreator creates a database based on requested engine
@@ -64,11 +98,17 @@ type Provider struct {
 var _ cqproto.CQProviderServer = (*Provider)(nil)
 
 func (p *Provider) GetProviderSchema(_ context.Context, _ *cqproto.GetProviderSchemaRequest) (*cqproto.GetProviderSchemaResponse, error) {
+	return p.Schema(), nil
+}
+
+// Schema returns the provider's schema without requiring it to be configured. ResourceTables maps each resource name
+// to its top level table, each table holding its columns and its relations nested in Relations recursively.
+func (p *Provider) Schema() *cqproto.GetProviderSchemaResponse {
 	return &cqproto.GetProviderSchemaResponse{
 		Name:           p.Name,
 		Version:        p.Version,
 		ResourceTables: p.ResourceMap,
-	}, nil
+	}
 }
 
 func (p *Provider) GetProviderConfig(_ context.Context, _ *cqproto.GetProviderConfigRequest) (*cqproto.GetProviderConfigResponse, error) {
@@ -104,34 +144,24 @@ func (p *Provider) ConfigureProvider(_ context.Context, request *cqproto.Configu
 		p.storageCreator = nil
 	}
 
+	if p.RunID == "" {
+		p.RunID = uuid.New().String()[:8]
+	}
 	// set database creator
 	if p.storageCreator == nil {
 		p.storageCreator = func(ctx context.Context, logger hclog.Logger, dbURL string) (execution.Storage, error) {
-			return database.New(ctx, logger, dbURL)
+			return database.New(ctx, logger, dbURL, database.WithApplicationName(p.ApplicationName()))
 		}
 	}
 
 	p.extraFields = request.ExtraFields
 	p.dbURL = request.Connection.DSN
-	providerConfig := p.Config()
-	if err := defaults.Set(providerConfig); err != nil {
+	providerConfig, diags := p.DecodeConfig(request.Config)
+	if diags.HasErrors() {
 		return &cqproto.ConfigureProviderResponse{
-			Diagnostics: diag.FromError(err, diag.INTERNAL),
+			Diagnostics: diags,
 		}, nil
 	}
-	// if we received an empty config we notify in log and only use defaults.
-	if len(request.Config) == 0 {
-		p.Logger.Info("Received empty configuration, using only defaults")
-	} else if err := hclsimple.Decode("config.hcl", request.Config, nil, providerConfig); err != nil {
-		p.Logger.Warn("Failed to read config as hcl, will try as json", "error", err)
-		// this part will be deprecated.
-		if err := hclsimple.Decode("config.json", request.Config, nil, providerConfig); err != nil {
-			p.Logger.Error("Failed to load configuration.", "error", err)
-			return &cqproto.ConfigureProviderResponse{
-				Diagnostics: diag.FromError(err, diag.USER),
-			}, nil
-		}
-	}
 
 	client, diags := p.Configure(p.Logger, providerConfig)
 	if diags.HasErrors() {
@@ -147,9 +177,15 @@ func (p *Provider) ConfigureProvider(_ context.Context, request *cqproto.Configu
 				Diagnostics: diags.Add(diag.FromError(err, diag.INTERNAL)),
 			}, nil
 		}
+		if err := schema.ValidateTable(t); err != nil {
+			return &cqproto.ConfigureProviderResponse{
+				Diagnostics: diags.Add(diag.FromError(fmt.Errorf("resource %s: %w", r, err), diag.INTERNAL)),
+			}, nil
+		}
 	}
 
 	p.meta = client
+	p.config = providerConfig
 	return &cqproto.ConfigureProviderResponse{
 		Diagnostics: diags,
 	}, nil
@@ -192,41 +228,102 @@ func (p *Provider) FetchResources(ctx context.Context, request *cqproto.FetchRes
 		parallelResourceSem = semaphore.NewWeighted(helpers.Uint64ToInt64(maxParallelFetchingLimit))
 	}
 
-	g, gctx := errgroup.WithContext(ctx)
+	if p.Clock != nil {
+		ctx = schema.WithClock(ctx, p.Clock)
+	}
+	// resolvers share a cache cleared per fetch, unless the caller passes its own, see execution.CacheFromContext
+	if execution.CacheFromContext(ctx) == nil {
+		ctx = execution.WithCache(ctx, execution.NewCache())
+	}
+	// all resources stored by this fetch share its start time as their sync time, see schema.TableCreationOptions SyncTime
+	metadata := make(map[string]interface{}, len(request.Metadata)+1)
+	for k, v := range request.Metadata {
+		metadata[k] = v
+	}
+	if _, ok := metadata[schema.SyncTimeMetaKey]; !ok {
+		metadata[schema.SyncTimeMetaKey] = schema.Now(ctx).UTC()
+	}
+	// fetchCtx is cancelled to stop the fetch if StopOnError is requested
+	fetchCtx, stopFetch := context.WithCancel(ctx)
+	defer stopFetch()
+
+	g, gctx := errgroup.WithContext(fetchCtx)
 	finishedResources := make(map[string]bool, len(resources))
 	l := &sync.Mutex{}
+	// stopped is set once the fetch was stopped because of an error, guarded by l
+	stopped := false
 	var totalResourceCount uint64
+	totalRows := execution.NewTotalRowsLimit(request.MaxTotalRows)
 	for _, resource := range resources {
 		table, ok := p.ResourceMap[resource]
 		if !ok {
 			return fmt.Errorf("plugin %s does not provide resource %s", p.Name, resource)
 		}
-		tableExec := execution.NewTableExecutor(resource, conn, p.Logger.With("table", table.Name), table, p.extraFields, request.Metadata, p.ErrorClassifier, goroutinesSem, request.Timeout)
+		opts := []execution.TableExecutorOption{execution.WithConfig(p.config), execution.WithResolverMiddleware(p.ResolverMiddleware...)}
+		if p.Clock != nil {
+			opts = append(opts, execution.WithClock(p.Clock))
+		}
+		if request.MaxItemsPerResource > 0 {
+			opts = append(opts, execution.WithMaxItems(request.MaxItemsPerResource))
+		}
+		if totalRows != nil {
+			opts = append(opts, execution.WithTotalRowsLimit(totalRows))
+		}
+		if p.DisablePanicRecovery {
+			opts = append(opts, execution.WithoutPanicRecovery())
+		}
+		// each resource gets its own limit of connections, see execution.LimitConnections
+		tableExec := execution.NewTableExecutor(resource, execution.LimitConnections(conn, request.MaxConnectionsPerResource), p.Logger.With("table", table.Name), table, p.extraFields, metadata, p.ErrorClassifier, goroutinesSem, request.Timeout, opts...)
 		p.Logger.Debug("fetching table...", "provider", p.Name, "table", table.Name)
 		// Save resource aside
 		r := resource
 		l.Lock()
 		finishedResources[r] = false
 		l.Unlock()
+		acquired := false
 		if parallelResourceSem != nil {
-			if err := parallelResourceSem.Acquire(ctx, 1); err != nil {
-				return err
+			if err := parallelResourceSem.Acquire(fetchCtx, 1); err != nil {
+				if isCancelled(ctx) {
+					return err
+				}
+				// fetch was stopped on error, the resource is still reported below as canceled
+			} else {
+				acquired = true
 			}
 		}
 		g.Go(func() error {
-			if parallelResourceSem != nil {
+			if acquired {
 				defer parallelResourceSem.Release(1)
 			}
-			resourceCount, diags := tableExec.Resolve(gctx, p.meta)
+			l.Lock()
+			skip := stopped
+			l.Unlock()
+			var (
+				resourceCount uint64
+				diags         diag.Diagnostics
+			)
+			if !skip {
+				resourceCount, diags = tableExec.Resolve(gctx, p.meta)
+			}
 			l.Lock()
 			defer l.Unlock()
 			finishedResources[r] = true
 			atomic.AddUint64(&totalResourceCount, resourceCount)
 			status := cqproto.ResourceFetchComplete
-			if isCancelled(ctx) {
+			if isCancelled(ctx) || stopped {
 				status = cqproto.ResourceFetchCanceled
 			} else if diags.HasErrors() {
 				status = cqproto.ResourceFetchPartial
+				if request.StopOnError {
+					p.Logger.Warn("stopping fetch, resource returned an error", "resource", r)
+					stopped = true
+					stopFetch()
+				}
+			}
+			if totalRows != nil && totalRows.Exceeded() && !stopped {
+				p.Logger.Warn("stopping fetch, the max total rows limit was exceeded", "resource", r, "max_total_rows", request.MaxTotalRows)
+				stopped = true
+				stopFetch()
 			}
 			if err := sender.Send(&cqproto.FetchResourcesResponse{
 				ResourceName:      r,
@@ -247,6 +344,12 @@ func (p *Provider) FetchResources(ctx context.Context, request *cqproto.FetchRes
 	return g.Wait()
 }
 
+// SetStorageCreator overrides how the provider creates its database storage when fetching resources.
+// It's mainly used by the testing package to wrap the storage, and should be called after ConfigureProvider.
+func (p *Provider) SetStorageCreator(creator func(ctx context.Context, logger hclog.Logger, dbURL string) (execution.Storage, error)) {
+	p.storageCreator = creator
+}
+
 func (p *Provider) GetModuleInfo(_ context.Context, request *cqproto.GetModuleRequest) (*cqproto.GetModuleResponse, error) {
 	if p.ModuleInfoReader == nil {
 		return nil, nil
@@ -281,6 +384,153 @@ func (p *Provider) interpolateAllResources(requestedResources []string) ([]strin
 	return allResources, nil
 }
 
+// DecodeConfig decodes config, HCL or JSON, into a new Config of the provider as ConfigureProvider does, applying the
+// defaults of its struct tags and ConfigDefaults first. It doesn't configure the provider.
+func (p *Provider) DecodeConfig(config []byte) (interface{}, diag.Diagnostics) {
+	providerConfig := p.Config()
+	if err := defaults.Set(providerConfig); err != nil {
+		return nil, diag.FromError(err, diag.INTERNAL)
+	}
+	if p.ConfigDefaults != "" {
+		if err := hclsimple.Decode("defaults.hcl", []byte(p.ConfigDefaults), nil, providerConfig); err != nil {
+			return nil, diag.FromError(fmt.Errorf("failed to decode provider config defaults: %w", err), diag.INTERNAL)
+		}
+	}
+	logger := p.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	// if we received an empty config we notify in log and only use defaults.
+	if len(config) == 0 {
+		logger.Info("Received empty configuration, using only defaults")
+	} else if err := hclsimple.Decode("config.hcl", config, nil, providerConfig); err != nil {
+		logger.Warn("Failed to read config as hcl, will try as json", "error", err)
+		// this part will be deprecated.
+		if err := hclsimple.Decode("config.json", config, nil, providerConfig); err != nil {
+			logger.Error("Failed to load configuration.", "error", err)
+			return nil, diag.FromError(err, diag.USER)
+		}
+	}
+	return providerConfig, nil
+}
+
+// SelectedResources returns the sorted names of the resources in ResourceMap selected by the configuration decoded by
+// ConfigureProvider, if it implements ResourceSelector, or all resources otherwise.
+func (p *Provider) SelectedResources() []string {
+	return p.SelectResources(p.config)
+}
+
+// SelectResources returns the sorted names of the resources in ResourceMap selected by config, e.g. as returned by
+// DecodeConfig, if it implements ResourceSelector, or all resources otherwise.
+func (p *Provider) SelectResources(config interface{}) []string {
+	selector, _ := config.(ResourceSelector)
+	var allowed, denied []string
+	if selector != nil {
+		allowed, denied = selector.AllowedResources(), selector.DeniedResources()
+	}
+	resources := make([]string, 0, len(p.ResourceMap))
+	for name := range p.ResourceMap {
+		if (len(allowed) > 0 && !funk.ContainsString(allowed, name)) || funk.ContainsString(denied, name) {
+			continue
+		}
+		resources = append(resources, name)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// TableInfo describes a table of the provider, see FlattenTables
+type TableInfo struct {
+	// Resource is the key in the ResourceMap of the top level table the table belongs to
+	Resource string
+	// Name is the table name, unique across the provider
+	Name string
+	// Parent is the name of the parent table of a relation, empty for top level tables
+	Parent string
+	// Columns are the columns declared by the table, excluding the internal ones such as cq_id added by the dialect
+	Columns []ColumnInfo
+}
+
+// ColumnInfo describes a column of a table, see TableInfo
+type ColumnInfo struct {
+	Name        string
+	Type        schema.ValueType
+	Description string
+}
+
+// FlattenTables returns every table in the ResourceMap along with all of its relations, ordered by resource name with
+// each table followed by its relations in declaration order
+func (p *Provider) FlattenTables() []TableInfo {
+	resources := funk.Keys(p.ResourceMap).([]string)
+	sort.Strings(resources)
+
+	var tables []TableInfo
+	var flatten func(resource string, table *schema.Table, parent string)
+	flatten = func(resource string, table *schema.Table, parent string) {
+		columns := make([]ColumnInfo, len(table.Columns))
+		for i, c := range table.Columns {
+			columns[i] = ColumnInfo{Name: c.Name, Type: c.Type, Description: c.Description}
+		}
+		tables = append(tables, TableInfo{Resource: resource, Name: table.Name, Parent: parent, Columns: columns})
+		for _, rel := range table.Relations {
+			flatten(resource, rel, table.Name)
+		}
+	}
+	for _, r := range resources {
+		flatten(r, p.ResourceMap[r], "")
+	}
+	return tables
+}
+
+// LintColumnNames checks every column of every table (and its relations) in the ResourceMap against allowedPattern regexp,
+// returning a diagnostic for each column name that doesn't match.
+func (p *Provider) LintColumnNames(allowedPattern string) diag.Diagnostics {
+	re, err := regexp.Compile(allowedPattern)
+	if err != nil {
+		return diag.FromError(fmt.Errorf("invalid column name pattern %q: %w", allowedPattern, err), diag.INTERNAL)
+	}
+	resources := funk.Keys(p.ResourceMap).([]string)
+	sort.Strings(resources)
+
+	var diags diag.Diagnostics
+	for _, r := range resources {
+		diags = diags.Add(lintTableColumnNames(r, p.ResourceMap[r], re))
+	}
+	return diags
+}
+
+// LintReservedNames checks the names of every table (and its relations) in the ResourceMap and of their columns against
+// the reserved words of postgres, see schema.IsReservedWord, returning a warning for each reserved name. The generated
+// DDL quotes table and column names, but queries written by hand may not, so renaming is recommended.
+func (p *Provider) LintReservedNames() diag.Diagnostics {
+	resources := funk.Keys(p.ResourceMap).([]string)
+	sort.Strings(resources)
+
+	var diags diag.Diagnostics
+	for _, r := range resources {
+		diags = diags.Add(lintTableReservedNames(r, p.ResourceMap[r]))
+	}
+	return diags
+}
+
+// LintIgnoredColumns checks every column of every table (and its relations) in the ResourceMap marked IgnoreInTests has
+// a Description, which should explain why it's ignored, returning a diagnostic for each undocumented column.
+func (p *Provider) LintIgnoredColumns() diag.Diagnostics {
+	resources := funk.Keys(p.ResourceMap).([]string)
+	sort.Strings(resources)
+
+	var diags diag.Diagnostics
+	for _, r := range resources {
+		diags = diags.Add(lintTableIgnoredColumns(r, p.ResourceMap[r]))
+	}
+	return diags
+}
+
+// ApplicationName returns the application_name of the provider's database connections, see RunID
+func (p *Provider) ApplicationName() string {
+	return fmt.Sprintf("cq-provider-%s-%s", p.Name, p.RunID)
+}
+
 // IsDebug checks if CQ_PROVIDER_DEBUG is turned on. In case it's true the plugin is executed in debug mode.
 func IsDebug() bool {
 	b, _ := strconv.ParseBool(os.Getenv("CQ_PROVIDER_DEBUG"))
@@ -308,3 +558,52 @@ func getTableDuplicates(resource string, table *schema.Table, tableNames map[str
 	tableNames[table.Name] = resource
 	return nil
 }
+
+func lintTableColumnNames(resource string, table *schema.Table, re *regexp.Regexp) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, c := range table.Columns {
+		if re.MatchString(c.Name) {
+			continue
+		}
+		diags = diags.Add(diag.NewBaseError(nil, diag.SCHEMA, diag.WithResourceName(resource), diag.WithSeverity(diag.ERROR),
+			diag.WithSummary("column %q in table %q doesn't match naming pattern %q", c.Name, table.Name, re.String())))
+	}
+	for _, rel := range table.Relations {
+		diags = diags.Add(lintTableColumnNames(resource, rel, re))
+	}
+	return diags
+}
+
+func lintTableReservedNames(resource string, table *schema.Table) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if schema.IsReservedWord(table.Name) {
+		diags = diags.Add(diag.NewBaseError(nil, diag.SCHEMA, diag.WithResourceName(resource), diag.WithSeverity(diag.WARNING),
+			diag.WithSummary("table name %q is a reserved SQL word, rename it or quote it in every query", table.Name)))
+	}
+	for _, c := range table.Columns {
+		if !schema.IsReservedWord(c.Name) {
+			continue
+		}
+		diags = diags.Add(diag.NewBaseError(nil, diag.SCHEMA, diag.WithResourceName(resource), diag.WithSeverity(diag.WARNING),
+			diag.WithSummary("column %q in table %q is a reserved SQL word, rename it or quote it in every query", c.Name, table.Name)))
+	}
+	for _, rel := range table.Relations {
+		diags = diags.Add(lintTableReservedNames(resource, rel))
+	}
+	return diags
+}
+
+func lintTableIgnoredColumns(resource string, table *schema.Table) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, c := range table.Columns {
+		if !c.IgnoreInTests || strings.TrimSpace(c.Description) != "" {
+			continue
+		}
+		diags = diags.Add(diag.NewBaseError(nil, diag.SCHEMA, diag.WithResourceName(resource), diag.WithSeverity(diag.ERROR),
+			diag.WithSummary("column %q in table %q is ignored in tests without a description of why", c.Name, table.Name)))
+	}
+	for _, rel := range table.Relations {
+		diags = diags.Add(lintTableIgnoredColumns(resource, rel))
+	}
+	return diags
+}
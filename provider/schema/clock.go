@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the current time. Resolvers stamping values with the current time should use Now(ctx) instead of
+// time.Now, so tests can inject a fixed clock and get deterministic values.
+type Clock interface {
+	Now() time.Time
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+type clockKey struct{}
+
+// WithClock returns a copy of ctx carrying clock, passed to resolvers via their context
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// ClockFromContext returns the clock carried by ctx, defaulting to the wall clock
+func ClockFromContext(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok && clock != nil {
+		return clock
+	}
+	return wallClock{}
+}
+
+// Now returns the current time of the clock carried by ctx, see ClockFromContext
+func Now(ctx context.Context) time.Time {
+	return ClockFromContext(ctx).Now()
+}
@@ -0,0 +1,28 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestClockFromContext(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.IsType(t, wallClock{}, ClockFromContext(context.Background()))
+	assert.Equal(t, now, Now(WithClock(context.Background(), fixedClock(now))))
+
+	r := NewResourceData(PostgresDialect{}, &Table{Name: "test_table"}, nil, nil, nil, now)
+	assert.NoError(t, cqMeta.Resolver(WithClock(context.Background(), fixedClock(now)), nil, r, cqMeta))
+	var meta Meta
+	assert.NoError(t, json.Unmarshal(r.Get(cqMeta.Name).([]byte), &meta))
+	assert.Equal(t, now, meta.LastUpdate)
+}
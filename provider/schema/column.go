@@ -3,12 +3,15 @@ package schema
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"net"
 	"reflect"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	gofrs "github.com/gofrs/uuid"
 	"github.com/google/uuid"
@@ -39,6 +42,15 @@ type ColumnResolver func(ctx context.Context, meta ClientMeta, resource *Resourc
 type ColumnCreationOptions struct {
 	Unique  bool
 	NotNull bool
+	// References creates a foreign key constraint of the column to a column of another table, which must be unique.
+	// Tables are created after the tables they reference, see migration.SortTables. Ignored by the TSDB dialect.
+	References *ColumnReference
+}
+
+// ColumnReference is a column of another table referenced by a foreign key
+type ColumnReference struct {
+	Table  string
+	Column string
 }
 
 // Column definition for Table
@@ -59,6 +71,35 @@ type Column struct {
 	// If IgnoreInTests is true, verification is skipped for this column.
 	// Used when it is hard to create a reproducible environment with this column being non-nil (e.g. various error columns).
 	IgnoreInTests bool
+	// IgnoreError isolates failures of the column's Resolver: on error the column is set to NULL and a warning is
+	// reported, instead of failing the whole row. Not allowed for primary key columns.
+	IgnoreError bool
+	// EnumValues are the allowed values of a TypeEnum column, created as a postgres ENUM type named by EnumTypeName.
+	// Values outside the set fail validation when the resource is stored.
+	EnumValues []string
+	// SourceKey is the path of the value in the resource item read by the default resolver of a column without a
+	// Resolver, e.g. "creationDate" or "Owner.ID". Defaults to the column name in CamelCase.
+	SourceKey string
+	// Sensitive marks columns holding secrets such as passwords or tokens, their values are rendered as MaskedValue in
+	// validation errors and test output.
+	Sensitive bool
+	// MaxLength, if positive, limits the characters of a TypeString column's values. The column is created as
+	// varchar(MaxLength), see ColumnDBType, and longer values fail validation when the resource is stored instead of
+	// being truncated.
+	MaxLength int
+	// Precision, if positive, is the total number of significant digits of a TypeNumeric column's values and Scale the
+	// number of those after the decimal point. The column is created as numeric(Precision, Scale), see ColumnDBType,
+	// and values with more digits fail validation when the resource is stored, rather than being rounded or
+	// overflowing. Without a Precision the column is created as numeric, storing values of any precision exactly.
+	Precision int
+	Scale     int
+	// Encrypt, if set, encrypts the values of a TypeString or TypeByteArray column when the resource is stored, the
+	// column is created as bytea holding the ciphertext. Resolvers of the resource and its relations still see the
+	// plaintext. Key management is the caller's responsibility, the SDK never stores the keys nor the plaintext.
+	Encrypt func(plaintext []byte) ([]byte, error)
+	// Decrypt reverses Encrypt, it's used by the test harness to verify the stored values are ciphertext and by
+	// verifiers to read the plaintext, see DecryptValue.
+	Decrypt func(ciphertext []byte) ([]byte, error)
 	// internal is true if this column is managed by the SDK
 	internal bool
 	// meta holds serializable information about the column's resolvers and functions
@@ -86,6 +127,10 @@ const (
 	TypeCIDRArray
 	TypeMacAddr
 	TypeMacAddrArray
+	TypeEnum
+	// TypeNumeric stores exact decimal values, e.g. amounts of money, see Column Precision. Values may be decimal
+	// strings, which are stored exactly, integers or floats.
+	TypeNumeric
 )
 
 func (v ValueType) String() string {
@@ -124,6 +169,10 @@ func (v ValueType) String() string {
 		return "TypeCIDRArray"
 	case TypeCIDR:
 		return "TypeCIDR"
+	case TypeEnum:
+		return "TypeEnum"
+	case TypeNumeric:
+		return "TypeNumeric"
 	case TypeInvalid:
 		fallthrough
 	default:
@@ -168,6 +217,10 @@ func ValueTypeFromString(s string) ValueType {
 		return TypeCIDR
 	case "cidrarray", "TypeCIDRArray":
 		return TypeCIDRArray
+	case "enum", "TypeEnum":
+		return TypeEnum
+	case "numeric", "TypeNumeric":
+		return TypeNumeric
 	case "invalid", "TypeInvalid":
 		return TypeInvalid
 	default:
@@ -179,10 +232,127 @@ func (c Column) Internal() bool {
 	return c.internal
 }
 
+// MaskedValue is rendered instead of the values of Sensitive columns
+const MaskedValue = "***"
+
+// Mask returns v, or MaskedValue if the column is Sensitive, for rendering the column's values in messages
+func (c Column) Mask(v interface{}) interface{} {
+	if c.Sensitive {
+		return MaskedValue
+	}
+	return v
+}
+
 func (c Column) ValidateType(v interface{}) error {
 	if !c.checkType(v) {
 		return fmt.Errorf("column %s expected %s got %T", c.Name, c.Type.String(), v)
 	}
+	if c.Type == TypeEnum {
+		return c.validateEnumValue(v)
+	}
+	if c.Type == TypeString && c.MaxLength > 0 {
+		return c.validateLength(v)
+	}
+	if c.Type == TypeNumeric {
+		return c.validateNumeric(v)
+	}
+	return nil
+}
+
+// encryptValue returns the ciphertext of v, a value of the column, if the column has Encrypt set
+func (c Column) encryptValue(v interface{}) (interface{}, error) {
+	if c.Encrypt == nil {
+		return v, nil
+	}
+	if c.Type != TypeString && c.Type != TypeByteArray {
+		return nil, fmt.Errorf("column %s of type %s can't be encrypted, only %s and %s columns can", c.Name, c.Type, TypeString, TypeByteArray)
+	}
+	if reflect2.IsNil(v) {
+		return nil, nil
+	}
+	plaintext, ok := v.([]byte)
+	if !ok {
+		plaintext = []byte(reflect.Indirect(reflect.ValueOf(v)).String())
+	}
+	ciphertext, err := c.Encrypt(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt column %s: %w", c.Name, err)
+	}
+	return ciphertext, nil
+}
+
+// DecryptValue returns the plaintext of ciphertext, a value of the column as stored, using Decrypt. The plaintext of
+// a TypeString column is returned as a string, otherwise as []byte.
+func (c Column) DecryptValue(ciphertext []byte) (interface{}, error) {
+	if c.Decrypt == nil {
+		return nil, fmt.Errorf("column %s has no Decrypt function", c.Name)
+	}
+	plaintext, err := c.Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt column %s: %w", c.Name, err)
+	}
+	if c.Type == TypeString {
+		return string(plaintext), nil
+	}
+	return plaintext, nil
+}
+
+func (c Column) validateLength(v interface{}) error {
+	if reflect2.IsNil(v) {
+		return nil
+	}
+	value := reflect.Indirect(reflect.ValueOf(v)).String()
+	if n := utf8.RuneCountInString(value); n > c.MaxLength {
+		return fmt.Errorf("column %s value %q has %d characters, exceeding its max length %d", c.Name, c.Mask(value), n, c.MaxLength)
+	}
+	return nil
+}
+
+// validateNumeric checks v is a decimal number fitting the column's Precision and Scale, so it's stored exactly
+func (c Column) validateNumeric(v interface{}) error {
+	if reflect2.IsNil(v) {
+		return nil
+	}
+	var value string
+	switch val := reflect.Indirect(reflect.ValueOf(v)).Interface().(type) {
+	case string:
+		value = val
+	case float32:
+		value = strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case float64:
+		value = strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		value = fmt.Sprint(val)
+	}
+	if strings.EqualFold(value, "NaN") {
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return fmt.Errorf("column %s value %q isn't a decimal number", c.Name, c.Mask(value))
+	}
+	if c.Precision <= 0 {
+		return nil
+	}
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Scale)), nil)))
+	if !scaled.IsInt() {
+		return fmt.Errorf("column %s value %q has more than %d digits after the decimal point", c.Name, c.Mask(value), c.Scale)
+	}
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Precision)), nil)
+	if new(big.Int).Abs(scaled.Num()).Cmp(limit) >= 0 {
+		return fmt.Errorf("column %s value %q exceeds its precision of %d digits with %d after the decimal point", c.Name, c.Mask(value), c.Precision, c.Scale)
+	}
+	return nil
+}
+
+func (c Column) validateEnumValue(v interface{}) error {
+	if reflect2.IsNil(v) {
+		return nil
+	}
+	value := reflect.Indirect(reflect.ValueOf(v)).String()
+	if !funk.ContainsString(c.EnumValues, value) {
+		return fmt.Errorf("column %s value %q isn't one of its enum values [%s]", c.Name, c.Mask(value), strings.Join(c.EnumValues, ", "))
+	}
 	return nil
 }
 
@@ -203,7 +373,7 @@ func (c Column) checkType(v interface{}) bool {
 	switch val := v.(type) {
 	case int8, *int8, uint8, *uint8, int16, *int16, uint16, *uint16, int32, *int32, int, *int, uint32, *uint32, int64, *int64:
 		// TODO: Deprecate all Int Types in favour of BigInt
-		return c.Type == TypeBigInt || c.Type == TypeSmallInt || c.Type == TypeInt
+		return c.Type == TypeBigInt || c.Type == TypeSmallInt || c.Type == TypeInt || c.Type == TypeNumeric
 	case []byte:
 		if c.Type == TypeUUID {
 			if _, err := uuid.FromBytes(val); err != nil {
@@ -219,17 +389,17 @@ func (c Column) checkType(v interface{}) bool {
 				return true
 			}
 		}
-		if c.Type == TypeJSON {
+		if c.Type == TypeJSON || c.Type == TypeNumeric {
 			return true
 		}
-		return c.Type == TypeString
+		return c.Type == TypeString || c.Type == TypeEnum
 	case *string:
 		if c.Type == TypeJSON {
 			return true
 		}
-		return c.Type == TypeString
+		return c.Type == TypeString || c.Type == TypeEnum
 	case *float32, float32, *float64, float64:
-		return c.Type == TypeFloat
+		return c.Type == TypeFloat || c.Type == TypeNumeric
 	case []string, []*string, *[]string:
 		return c.Type == TypeStringArray || c.Type == TypeJSON
 	case []int, []*int, *[]int:
@@ -258,7 +428,7 @@ func (c Column) checkType(v interface{}) bool {
 		return c.Type == TypeCIDRArray
 	case interface{}:
 		kindName := reflect2.TypeOf(v).Kind()
-		if kindName == reflect.String && c.Type == TypeString {
+		if kindName == reflect.String && (c.Type == TypeString || c.Type == TypeEnum) {
 			return true
 		}
 		if kindName == reflect.Slice {
@@ -272,8 +442,8 @@ func (c Column) checkType(v interface{}) bool {
 			if c.Type == TypeJSON && (reflect.Struct == itemKind || reflect.Ptr == itemKind) {
 				return true
 			}
-			if c.Type == TypeUUIDArray && reflect2.TypeOf(v).String() == "uuid.UUID" || reflect2.TypeOf(v).String() == "*uuid.UUID" {
-				return c.Type == TypeUUIDArray
+			if itemType := reflect2.TypeOf(v).Type1().Elem().String(); c.Type == TypeUUIDArray && (itemType == "uuid.UUID" || itemType == "*uuid.UUID") {
+				return true
 			}
 		}
 		if kindName == reflect.Struct {
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
@@ -26,6 +27,26 @@ type SomeInt int
 type SomeInt16 int16
 
 var validateFixtures = []validateFixture{
+	{
+		Column:     Column{Name: "name", Type: TypeString, MaxLength: 3},
+		TestValues: []interface{}{"abc", "äöü", funk.PtrOf("ab"), SomeString(""), nil},
+		BadValues:  []interface{}{"abcd", funk.PtrOf("äöüß"), SomeString("abcd")},
+	},
+	{
+		Column:     Column{Name: "status", Type: TypeEnum, EnumValues: []string{"active", "inactive"}},
+		TestValues: []interface{}{"active", funk.PtrOf("inactive"), SomeString("active"), nil},
+		BadValues:  []interface{}{"deleted", funk.PtrOf("Active"), SomeString(""), 5},
+	},
+	{
+		Column:     Column{Name: "price", Type: TypeNumeric, Precision: 5, Scale: 2},
+		TestValues: []interface{}{"123.45", "-999.99", "0.1", funk.PtrOf("12"), 999, 1.25, "NaN", nil},
+		BadValues:  []interface{}{"1000", "1.234", 1000.5, "abc", true},
+	},
+	{
+		Column:     Column{Name: "amount", Type: TypeNumeric},
+		TestValues: []interface{}{"12345678901234567890.123456789", int64(5), 0.5},
+		BadValues:  []interface{}{"1,5", "", []string{"1"}},
+	},
 	{
 		Column:     Column{Type: TypeBigInt},
 		TestValues: []interface{}{5, 300, funk.PtrOf(555), SomeInt(555)},
@@ -45,6 +66,11 @@ var validateFixtures = []validateFixture{
 		Column:     Column{Type: TypeTimestamp},
 		TestValues: []interface{}{time.Now()},
 	},
+	{
+		Column:     Column{Type: TypeUUIDArray},
+		TestValues: []interface{}{[]uuid.UUID{uuid.New()}, []*uuid.UUID{}},
+		BadValues:  []interface{}{[]string{"a"}, []int{1}},
+	},
 	{
 		Column:     Column{Type: TypeUUID},
 		TestValues: []interface{}{uuid.New(), uuid.New().String()},
@@ -142,9 +168,70 @@ func TestValueTypeFromString(t *testing.T) {
 	assert.Equal(t, ValueTypeFromString("Json"), TypeJSON)
 	assert.Equal(t, ValueTypeFromString("JSON"), TypeJSON)
 	assert.Equal(t, ValueTypeFromString("bigint"), TypeBigInt)
+	assert.Equal(t, ValueTypeFromString("enum"), TypeEnum)
+	assert.Equal(t, ValueTypeFromString("numeric"), TypeNumeric)
 	assert.Equal(t, ValueTypeFromString("Blabla"), TypeInvalid)
 }
 
+func TestColumn_Mask(t *testing.T) {
+	c := Column{Name: "token", Type: TypeString}
+	assert.Equal(t, "secret", c.Mask("secret"))
+	c.Sensitive = true
+	assert.Equal(t, MaskedValue, c.Mask("secret"))
+
+	enum := Column{Name: "password_kind", Type: TypeEnum, EnumValues: []string{"plain"}, Sensitive: true}
+	err := enum.ValidateType("hunter2")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "hunter2")
+	assert.Contains(t, err.Error(), MaskedValue)
+}
+
+// reverseBytes is a stand-in cipher for tests
+func reverseBytes(b []byte) ([]byte, error) {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r, nil
+}
+
+func TestColumn_Encrypt(t *testing.T) {
+	table := &Table{
+		Name: "test_encrypted",
+		Columns: []Column{
+			{Name: "token", Type: TypeString, Encrypt: reverseBytes, Decrypt: reverseBytes},
+			{Name: "key", Type: TypeByteArray, Encrypt: reverseBytes, Decrypt: reverseBytes},
+			{Name: "missing", Type: TypeString, Encrypt: reverseBytes, Decrypt: reverseBytes},
+		},
+	}
+	r := NewResourceData(PostgresDialect{}, table, nil, nil, nil, time.Now())
+	assert.NoError(t, r.Set("token", "abc"))
+	assert.NoError(t, r.Set("key", []byte{1, 2}))
+
+	values, err := PostgresDialect{}.GetResourceValues(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]byte("cba"), []byte{2, 1}, nil}, values[2:])
+	values, err = r.Values()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]byte("cba"), []byte{2, 1}, nil}, values[2:])
+	// resolvers still see the plaintext
+	assert.Equal(t, "abc", r.Get("token"))
+
+	v, err := table.Columns[0].DecryptValue([]byte("cba"))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", v)
+	v, err = table.Columns[1].DecryptValue([]byte{2, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, v)
+
+	_, err = Column{Name: "enabled", Type: TypeBool, Encrypt: reverseBytes}.encryptValue(true)
+	assert.EqualError(t, err, "column enabled of type TypeBool can't be encrypted, only TypeString and TypeByteArray columns can")
+	_, err = Column{Name: "token", Type: TypeString, Encrypt: func([]byte) ([]byte, error) { return nil, errors.New("no key") }}.encryptValue("abc")
+	assert.EqualError(t, err, "failed to encrypt column token: no key")
+	_, err = Column{Name: "token", Type: TypeString}.DecryptValue([]byte("cba"))
+	assert.EqualError(t, err, "column token has no Decrypt function")
+}
+
 func BenchmarkColumn_ValidateTypeInt(b *testing.B) {
 	col := Column{Type: TypeInt}
 	for n := 0; n < b.N; n++ {
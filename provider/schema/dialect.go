@@ -1,27 +1,38 @@
 package schema
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/modern-go/reflect2"
 )
 
+// DialectType names a Dialect, either a builtin one or one added with RegisterDialect
 type DialectType string
 
+// Dialect describes how tables are created in and resources are stored to a database engine. Besides the builtin
+// PostgresDialect and TSDBDialect, custom dialects may be added with RegisterDialect and looked up with GetDialect.
+// A custom dialect usually embeds PostgresDialect, overriding only what its database does differently.
 type Dialect interface {
 	// PrimaryKeys returns the primary keys of table according to dialect
 	PrimaryKeys(t *Table) []string
 
-	// Columns returns the columns of table according to dialect
+	// Columns returns the columns of table according to dialect, including the columns internal to the SDK such as
+	// cq_id and cq_meta. Columns returned must be in the order of the values returned by GetResourceValues.
 	Columns(t *Table) ColumnList
 
-	// Constraints returns constraint definitions for table, according to dialect
+	// Constraints returns constraint definitions for table, according to dialect. parent is nil for top level tables.
+	// Constraints are placed inside the CREATE TABLE statement, after the column definitions.
 	Constraints(t, parent *Table) []string
 
-	// Extra returns additional definitions for table outside the CREATE TABLE statement, according to dialect
+	// Extra returns additional definitions for table outside the CREATE TABLE statement, according to dialect.
+	// They're executed right after the table is created, e.g. to create indices.
 	Extra(t, parent *Table) []string
 
 	// DBTypeFromType returns the database type from the given ValueType. Always lowercase.
@@ -51,16 +62,40 @@ func (t DialectType) MigrationDirectory() string {
 	return string(t)
 }
 
-// GetDialect creates and returns a dialect specified by the DialectType
+var (
+	dialectsLock sync.RWMutex
+	dialects     = make(map[DialectType]Dialect)
+)
+
+// RegisterDialect makes a custom dialect available by name to GetDialect. It panics if d is nil, or if the name is
+// already taken by a builtin or registered dialect.
+func RegisterDialect(name string, d Dialect) {
+	if d == nil {
+		panic("schema: RegisterDialect dialect is nil")
+	}
+	t := DialectType(name)
+	dialectsLock.Lock()
+	defer dialectsLock.Unlock()
+	if _, ok := dialects[t]; ok || t == Postgres || t == TSDB {
+		panic("schema: dialect " + name + " is already registered")
+	}
+	dialects[t] = d
+}
+
+// GetDialect creates and returns a dialect specified by the DialectType, either builtin or registered with RegisterDialect
 func GetDialect(t DialectType) (Dialect, error) {
 	switch t {
 	case Postgres:
 		return PostgresDialect{}, nil
 	case TSDB:
 		return TSDBDialect{}, nil
-	default:
-		return nil, fmt.Errorf("unknown dialect %q", t)
 	}
+	dialectsLock.RLock()
+	defer dialectsLock.RUnlock()
+	if d, ok := dialects[t]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("unknown dialect %q", t)
 }
 
 func (PostgresDialect) PrimaryKeys(t *Table) []string {
@@ -71,26 +106,40 @@ func (PostgresDialect) PrimaryKeys(t *Table) []string {
 }
 
 func (PostgresDialect) Columns(t *Table) ColumnList {
-	return append([]Column{cqIdColumn, cqMeta}, t.Columns...)
+	return append(internalColumns(t, cqIdColumn, cqMeta), t.Columns...)
+}
+
+// internalColumns returns the given internal columns of every table, followed by the optional ones enabled by t's options
+func internalColumns(t *Table, columns ...Column) []Column {
+	if t.Options.SyncTime {
+		columns = append(columns, cqSyncTimeColumn)
+	}
+	return columns
 }
 
 func (d PostgresDialect) Constraints(t, parent *Table) []string {
 	ret := make([]string, 0, len(t.Columns))
 
-	ret = append(ret, fmt.Sprintf("CONSTRAINT %s_pk PRIMARY KEY(%s)", truncatePKConstraint(t.Name), strings.Join(d.PrimaryKeys(t), ",")))
+	ret = append(ret, fmt.Sprintf("CONSTRAINT %s_pk PRIMARY KEY(%s)", truncatePKConstraint(t.Name), quoteIdentifiers(d.PrimaryKeys(t)...)))
 
 	for _, c := range d.Columns(t) {
 		if !c.CreationOptions.Unique {
 			continue
 		}
 
-		ret = append(ret, fmt.Sprintf("UNIQUE(%s)", c.Name))
+		ret = append(ret, fmt.Sprintf("UNIQUE(%s)", quoteIdentifiers(c.Name)))
+	}
+
+	for _, c := range t.Columns {
+		if ref := c.CreationOptions.References; ref != nil {
+			ret = append(ret, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)", quoteIdentifiers(c.Name), quoteIdentifiers(ref.Table), quoteIdentifiers(ref.Column)))
+		}
 	}
 
 	if parent != nil {
-		pc := findParentIdColumn(t)
+		pc := FindParentIdColumn(t)
 		if pc != nil {
-			ret = append(ret, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE CASCADE", pc.Name, parent.Name, cqIdColumn.Name))
+			ret = append(ret, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE CASCADE", quoteIdentifiers(pc.Name), quoteIdentifiers(parent.Name), quoteIdentifiers(cqIdColumn.Name)))
 		}
 	}
 
@@ -141,6 +190,11 @@ func (PostgresDialect) DBTypeFromType(v ValueType) string {
 		return "cidr"
 	case TypeCIDRArray:
 		return "cidr[]"
+	case TypeEnum:
+		// the column's ENUM type is created by the table definitions, see EnumTypeName
+		return "text"
+	case TypeNumeric:
+		return "numeric"
 	default:
 		panic("invalid type")
 	}
@@ -155,27 +209,27 @@ func (d TSDBDialect) PrimaryKeys(t *Table) []string {
 }
 
 func (TSDBDialect) Columns(t *Table) ColumnList {
-	return append([]Column{cqIdColumn, cqMeta, cqFetchDateColumn}, t.Columns...)
+	return append(internalColumns(t, cqIdColumn, cqMeta, cqFetchDateColumn), t.Columns...)
 }
 
 func (d TSDBDialect) Constraints(t, _ *Table) []string {
 	ret := make([]string, 0, len(t.Columns))
 
-	ret = append(ret, fmt.Sprintf("CONSTRAINT %s_pk PRIMARY KEY(%s)", truncatePKConstraint(t.Name), strings.Join(d.PrimaryKeys(t), ",")))
+	ret = append(ret, fmt.Sprintf("CONSTRAINT %s_pk PRIMARY KEY(%s)", truncatePKConstraint(t.Name), quoteIdentifiers(d.PrimaryKeys(t)...)))
 
 	for _, c := range d.Columns(t) {
 		if !c.CreationOptions.Unique {
 			continue
 		}
 
-		ret = append(ret, fmt.Sprintf("UNIQUE(%s,%s)", cqFetchDateColumn.Name, c.Name))
+		ret = append(ret, fmt.Sprintf("UNIQUE(%s)", quoteIdentifiers(cqFetchDateColumn.Name, c.Name)))
 	}
 
 	return ret
 }
 
 func (TSDBDialect) Extra(t, parent *Table) []string {
-	pc := findParentIdColumn(t)
+	pc := FindParentIdColumn(t)
 
 	if parent == nil || pc == nil {
 		return []string{
@@ -184,7 +238,7 @@ func (TSDBDialect) Extra(t, parent *Table) []string {
 	}
 
 	return []string{
-		fmt.Sprintf("CREATE INDEX ON %s (%s, %s);", t.Name, cqFetchDateColumn.Name, pc.Name),
+		fmt.Sprintf("CREATE INDEX ON %s (%s);", quoteIdentifiers(t.Name), quoteIdentifiers(cqFetchDateColumn.Name, pc.Name)),
 		fmt.Sprintf("SELECT setup_tsdb_child('%s', '%s', '%s', '%s');", t.Name, pc.Name, parent.Name, cqIdColumn.Name),
 	}
 }
@@ -204,6 +258,14 @@ func doResourceValues(dialect Dialect, r *Resource) ([]interface{}, error) {
 		if err := c.ValidateType(v); err != nil {
 			return nil, err
 		}
+		if c.Encrypt != nil {
+			ciphertext, err := c.encryptValue(v)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, ciphertext)
+			continue
+		}
 		switch c.Type {
 		case TypeJSON:
 			if v == nil {
@@ -264,7 +326,8 @@ func doResourceValues(dialect Dialect, r *Resource) ([]interface{}, error) {
 	return values, nil
 }
 
-func findParentIdColumn(t *Table) (ret *Column) {
+// FindParentIdColumn returns the column of t resolved by ParentIdResolver, referencing its parent's cq_id, or nil if t has none
+func FindParentIdColumn(t *Table) (ret *Column) {
 	for _, c := range t.Columns {
 		if c.Meta().Resolver != nil && c.Meta().Resolver.Name == "schema.ParentIdResolver" {
 			return &c
@@ -274,6 +337,46 @@ func findParentIdColumn(t *Table) (ret *Column) {
 	return nil
 }
 
+// ColumnDBType returns the database type column c is created as by dialect: its DBTypeFromType, limited by the column's
+// MaxLength or Precision and Scale if set. The limits are applied as modifiers of the dialect's type, text becoming
+// varchar(MaxLength), so types overridden by a dialect are kept. Types which take no modifier, e.g. citext, are
+// returned as is, the limits are then only enforced by validating the values, see Column ValidateType.
+func ColumnDBType(d Dialect, c Column) string {
+	typ := d.DBTypeFromType(c.Type)
+	switch {
+	case c.Type == TypeString && c.MaxLength > 0:
+		switch typ {
+		case "text":
+			return fmt.Sprintf("varchar(%d)", c.MaxLength)
+		case "varchar", "character varying", "char", "character":
+			return fmt.Sprintf("%s(%d)", typ, c.MaxLength)
+		}
+	case c.Type == TypeNumeric && c.Precision > 0:
+		switch typ {
+		case "numeric", "decimal":
+			return fmt.Sprintf("%s(%d,%d)", typ, c.Precision, c.Scale)
+		}
+	}
+	return typ
+}
+
+// EnumTypeName returns the name of the postgres ENUM type created for TypeEnum column c of table t. The name is suffixed
+// with _enum, so it doesn't collide with the row type postgres creates for a table of the same name. Names longer than
+// postgres allows are truncated, keeping a hash of the full name so two long names don't truncate to the same one.
+func EnumTypeName(t *Table, c Column) string {
+	const (
+		maxTypeNameLength = 63
+		suffix            = "_enum"
+		hashLength        = 8
+	)
+	name := t.Name + "_" + c.Name
+	if len(name)+len(suffix) <= maxTypeNameLength {
+		return name + suffix
+	}
+	hash := sha1.Sum([]byte(name))
+	return name[:maxTypeNameLength-len(suffix)-hashLength-1] + "_" + hex.EncodeToString(hash[:])[:hashLength] + suffix
+}
+
 func truncatePKConstraint(name string) string {
 	const (
 		// MaxTableLength in postgres is 63 when building _fk or _pk we want to truncate the name to 60 chars max
@@ -285,3 +388,13 @@ func truncatePKConstraint(name string) string {
 	}
 	return name
 }
+
+// quoteIdentifiers returns names quoted and joined by commas, so table and column names which are reserved words, see
+// IsReservedWord, can be used in constraints
+func quoteIdentifiers(names ...string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = strconv.Quote(n)
+	}
+	return strings.Join(quoted, ",")
+}
@@ -1,7 +1,11 @@
 package schema
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -187,3 +191,131 @@ func TestIntColumn(t *testing.T) {
 		assert.Nil(t, err)
 	}
 }
+
+// columnarDialect is an example custom dialect, creating its tables with the columnar table access method
+type columnarDialect struct {
+	PostgresDialect
+}
+
+func (columnarDialect) Extra(t, _ *Table) []string {
+	return []string{fmt.Sprintf("ALTER TABLE %s SET ACCESS METHOD columnar;", t.Name)}
+}
+
+func (d columnarDialect) DBTypeFromType(v ValueType) string {
+	if v == TypeJSON {
+		return "json"
+	}
+	return d.PostgresDialect.DBTypeFromType(v)
+}
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect("columnar", columnarDialect{})
+	t.Cleanup(func() {
+		dialectsLock.Lock()
+		defer dialectsLock.Unlock()
+		delete(dialects, "columnar")
+	})
+
+	d, err := GetDialect("columnar")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE test_table_validator SET ACCESS METHOD columnar;"}, d.Extra(&jsonTestTable, nil))
+	assert.Equal(t, "json", d.DBTypeFromType(TypeJSON))
+	assert.Equal(t, "text", d.DBTypeFromType(TypeString))
+
+	_, err = GetDialect("unknown")
+	assert.EqualError(t, err, `unknown dialect "unknown"`)
+	assert.Panics(t, func() { RegisterDialect("columnar", columnarDialect{}) })
+	assert.Panics(t, func() { RegisterDialect(string(Postgres), columnarDialect{}) })
+	assert.Panics(t, func() { RegisterDialect("nil", nil) })
+}
+
+func TestConstraints_ReservedNames(t *testing.T) {
+	parent := &Table{Name: "user", Options: TableCreationOptions{PrimaryKeys: []string{"order", "limit"}}}
+	child := &Table{
+		Name: "order",
+		Columns: []Column{
+			{Name: "user_cq_id", Type: TypeUUID, Resolver: ParentIdResolver},
+			{Name: "group", Type: TypeString, CreationOptions: ColumnCreationOptions{Unique: true, References: &ColumnReference{Table: "table", Column: "column"}}},
+		},
+	}
+	assert.Equal(t, []string{`CONSTRAINT user_pk PRIMARY KEY("order","limit")`, `UNIQUE("cq_id")`}, PostgresDialect{}.Constraints(parent, nil))
+	assert.Equal(t, []string{
+		`CONSTRAINT order_pk PRIMARY KEY("cq_id")`,
+		`UNIQUE("cq_id")`,
+		`UNIQUE("group")`,
+		`FOREIGN KEY ("group") REFERENCES "table"("column")`,
+		`FOREIGN KEY ("user_cq_id") REFERENCES "user"("cq_id") ON DELETE CASCADE`,
+	}, PostgresDialect{}.Constraints(child, parent))
+
+	assert.Equal(t, []string{`CONSTRAINT order_pk PRIMARY KEY("cq_fetch_date","cq_id")`, `UNIQUE("cq_fetch_date","cq_id")`, `UNIQUE("cq_fetch_date","group")`}, TSDBDialect{}.Constraints(child, parent))
+	assert.Equal(t, []string{
+		`CREATE INDEX ON "order" ("cq_fetch_date","user_cq_id");`,
+		"SELECT setup_tsdb_child('order', 'user_cq_id', 'user', 'cq_id');",
+	}, TSDBDialect{}.Extra(child, parent))
+}
+
+func TestColumnDBType(t *testing.T) {
+	name := Column{Name: "name", Type: TypeString, MaxLength: 255}
+	price := Column{Name: "price", Type: TypeNumeric, Precision: 10, Scale: 4}
+	assert.Equal(t, "varchar(255)", ColumnDBType(PostgresDialect{}, name))
+	assert.Equal(t, "numeric(10,4)", ColumnDBType(TSDBDialect{}, price))
+	assert.Equal(t, "text", ColumnDBType(PostgresDialect{}, Column{Name: "name", Type: TypeString}))
+	assert.Equal(t, "numeric", ColumnDBType(PostgresDialect{}, Column{Name: "price", Type: TypeNumeric}))
+	assert.Equal(t, "json", ColumnDBType(columnarDialect{}, Column{Name: "data", Type: TypeJSON}))
+
+	// the limits modify the type of the dialect, or are left out if it takes no modifier
+	assert.Equal(t, "character varying(255)", ColumnDBType(typeDialect{TypeString: "character varying"}, name))
+	assert.Equal(t, "citext", ColumnDBType(typeDialect{TypeString: "citext"}, name))
+	assert.Equal(t, "decimal(10,4)", ColumnDBType(typeDialect{TypeNumeric: "decimal"}, price))
+	assert.Equal(t, "float", ColumnDBType(typeDialect{TypeNumeric: "float"}, price))
+}
+
+// typeDialect is a dialect creating the value types it maps as their types
+type typeDialect map[ValueType]string
+
+func (d typeDialect) PrimaryKeys(t *Table) []string { return PostgresDialect{}.PrimaryKeys(t) }
+
+func (typeDialect) Columns(t *Table) ColumnList { return PostgresDialect{}.Columns(t) }
+
+func (typeDialect) Constraints(_, _ *Table) []string { return nil }
+
+func (typeDialect) Extra(_, _ *Table) []string { return nil }
+
+func (d typeDialect) DBTypeFromType(v ValueType) string { return d[v] }
+
+func (typeDialect) GetResourceValues(r *Resource) ([]interface{}, error) {
+	return PostgresDialect{}.GetResourceValues(r)
+}
+
+func TestSyncTimeColumn(t *testing.T) {
+	table := &Table{Name: "test_sync_time", Columns: []Column{{Name: "name", Type: TypeString}}}
+	assert.Equal(t, []string{"cq_id", "cq_meta", "name"}, PostgresDialect{}.Columns(table).Names())
+
+	table.Options.SyncTime = true
+	assert.Equal(t, []string{"cq_id", "cq_meta", "_cq_sync_time", "name"}, PostgresDialect{}.Columns(table).Names())
+	assert.Equal(t, []string{"cq_id", "cq_meta", "cq_fetch_date", "_cq_sync_time", "name"}, TSDBDialect{}.Columns(table).Names())
+
+	syncTime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	start := syncTime.Add(time.Minute)
+	r := NewResourceData(PostgresDialect{}, table, nil, nil, map[string]interface{}{SyncTimeMetaKey: syncTime}, start)
+	assert.NoError(t, cqSyncTimeColumn.Resolver(context.Background(), nil, r, cqSyncTimeColumn))
+	assert.Equal(t, syncTime, r.Get("_cq_sync_time"))
+
+	// without the provider's sync time the execution start is used
+	r = NewResourceData(PostgresDialect{}, table, nil, nil, nil, start)
+	assert.NoError(t, cqSyncTimeColumn.Resolver(context.Background(), nil, r, cqSyncTimeColumn))
+	assert.Equal(t, start, r.Get("_cq_sync_time"))
+}
+
+func TestEnumTypeName(t *testing.T) {
+	table := &Table{Name: "test_instances"}
+	assert.Equal(t, "test_instances_state_enum", EnumTypeName(table, Column{Name: "state"}))
+
+	// long names keep a hash of the full name, so names sharing the first 63 bytes don't collide
+	table.Name = "test_" + strings.Repeat("a", 60)
+	first, second := EnumTypeName(table, Column{Name: "first_state"}), EnumTypeName(table, Column{Name: "second_state"})
+	assert.Len(t, first, 63)
+	assert.Len(t, second, 63)
+	assert.NotEqual(t, first, second)
+	assert.True(t, strings.HasSuffix(first, "_enum"))
+}
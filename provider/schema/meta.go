@@ -24,6 +24,9 @@ type Meta struct {
 
 const FetchIdMetaKey = "cq_fetch_id"
 
+// SyncTimeMetaKey is the metadata key of the time the fetch started, set by the provider for the _cq_sync_time column
+const SyncTimeMetaKey = "cq_sync_time"
+
 var (
 	cqMeta = Column{
 		Name:        "cq_meta",
@@ -31,7 +34,7 @@ var (
 		Description: "Meta column holds fetch information",
 		Resolver: func(ctx context.Context, meta ClientMeta, resource *Resource, c Column) error {
 			mi := Meta{
-				LastUpdate: time.Now().UTC(),
+				LastUpdate: Now(ctx).UTC(),
 			}
 			if val, ok := resource.GetMeta(FetchIdMetaKey); ok {
 				if s, ok := val.(string); ok {
@@ -49,7 +52,8 @@ var (
 		Description: "Unique CloudQuery Id added to every resource",
 		Resolver: func(ctx context.Context, meta ClientMeta, resource *Resource, c Column) error {
 			if err := resource.GenerateCQId(); err != nil {
-				if resource.Parent == nil {
+				// a failing CQIDResolver fails relations too, rather than falling back to a random id
+				if resource.Parent == nil || resource.table.CQIDResolver != nil {
 					return err
 				}
 
@@ -82,4 +86,23 @@ var (
 		},
 		internal: true,
 	}
+	cqSyncTimeColumn = Column{
+		Name:        "_cq_sync_time",
+		Type:        TypeTimestamp,
+		Description: "Time the sync which last stored this resource started",
+		Resolver: func(ctx context.Context, meta ClientMeta, resource *Resource, c Column) error {
+			val, ok := resource.GetMeta(SyncTimeMetaKey)
+			if !ok && !resource.executionStart.IsZero() {
+				val = resource.executionStart.UTC()
+			}
+			if val == nil {
+				return fmt.Errorf("zero _cq_sync_time")
+			}
+			return resource.Set(c.Name, val)
+		},
+		CreationOptions: ColumnCreationOptions{
+			NotNull: true,
+		},
+		internal: true,
+	}
 )
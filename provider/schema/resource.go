@@ -28,6 +28,8 @@ type Resource struct {
 	columns        []string
 	dialect        Dialect
 	executionStart time.Time
+	// resourceID is the natural id set by SetResourceID
+	resourceID []string
 }
 
 func NewResourceData(dialect Dialect, t *Table, parent *Resource, item interface{}, metadata map[string]interface{}, startTime time.Time) *Resource {
@@ -70,15 +72,44 @@ func (r *Resource) PrimaryKeyValues() []string {
 	return results
 }
 
+// SetResourceID sets the natural identifier of the resource, e.g. its ARN or name, which diagnostics of the resource
+// and of its relations are tied to from then on, rather than its primary key values. See ResourceID.
+func (r *Resource) SetResourceID(id ...string) {
+	r.resourceID = id
+}
+
+// ResourceID returns the identifier diagnostics of the resource are tied to: the id set with SetResourceID, or its
+// primary key values otherwise. Ids set on ancestors compose, prefixing the id of their relations outermost first, e.g.
+// a disk of an instance with id "arn:instance" is identified as ["arn:instance", "disk-1"].
+func (r *Resource) ResourceID() []string {
+	id := r.resourceID
+	if id == nil {
+		id = r.PrimaryKeyValues()
+	}
+	var prefix []string
+	for p := r.Parent; p != nil; p = p.Parent {
+		prefix = append(append([]string{}, p.resourceID...), prefix...)
+	}
+	if len(prefix) == 0 {
+		return id
+	}
+	return append(prefix, id...)
+}
+
 func (r *Resource) Get(key string) interface{} {
 	return r.data[key]
 }
 
+// Set sets the value of column key, converted by the TypeResolver registered for its type if any
 func (r *Resource) Set(key string, value interface{}) error {
 	columnExists := funk.ContainsString(r.columns, key)
 	if !columnExists {
 		return fmt.Errorf("column %s does not exist", key)
 	}
+	value, err := resolveType(value)
+	if err != nil {
+		return fmt.Errorf("failed to convert value of column %s: %w", key, err)
+	}
 	r.data[key] = value
 	return nil
 }
@@ -94,12 +125,24 @@ func (r *Resource) Values() ([]interface{}, error) {
 		if err := c.ValidateType(v); err != nil {
 			return nil, err
 		}
+		v, err := c.encryptValue(v)
+		if err != nil {
+			return nil, err
+		}
 		values = append(values, v)
 	}
 	return values, nil
 }
 
 func (r *Resource) GenerateCQId() error {
+	if r.table.CQIDResolver != nil {
+		id, err := r.table.CQIDResolver(r)
+		if err != nil {
+			return fmt.Errorf("failed to resolve cq_id for %s: %w", r.table.Name, err)
+		}
+		r.cqId = id
+		return nil
+	}
 	if len(r.table.Options.PrimaryKeys) == 0 {
 		return nil
 	}
@@ -171,6 +214,22 @@ func (rr Resources) ColumnNames() []string {
 	return rr[0].columns
 }
 
+// CQIDFromColumns returns a CQIDResolver hashing the values of the given columns into the cq_id, like the cq_id of tables
+// with primary keys. It fails if any of the values is nil.
+func CQIDFromColumns(columns ...string) CQIDResolver {
+	return func(resource *Resource) (uuid.UUID, error) {
+		objs := make([]interface{}, 0, len(columns))
+		for _, c := range columns {
+			v := resource.Get(c)
+			if v == nil {
+				return uuid.Nil, fmt.Errorf("column %s is nil", c)
+			}
+			objs = append(objs, v)
+		}
+		return hashUUID(objs)
+	}
+}
+
 func hashUUID(objs interface{}) (uuid.UUID, error) {
 	// Use SHA1 because it's fast and is reasonably enough protected against accidental collisions.
 	// There is no scenario here where intentional created collisions could do harm.
@@ -59,6 +59,23 @@ func TestResourcePrimaryKey(t *testing.T) {
 	assert.Equal(t, r.PrimaryKeyValues(), []string{uuidPK.String()})
 }
 
+func TestResourceID(t *testing.T) {
+	parent := NewResourceData(PostgresDialect{}, testPrimaryKeyTable, nil, nil, nil, time.Now())
+	assert.Nil(t, parent.Set("primary_key_str", "parent-key"))
+	assert.Equal(t, []string{"parent-key"}, parent.ResourceID())
+	parent.SetResourceID("arn:parent")
+	assert.Equal(t, []string{"arn:parent"}, parent.ResourceID())
+
+	child := NewResourceData(PostgresDialect{}, testPrimaryKeyTable, parent, nil, nil, time.Now())
+	assert.Nil(t, child.Set("primary_key_str", "child-key"))
+	assert.Equal(t, []string{"arn:parent", "child-key"}, child.ResourceID())
+	grandchild := NewResourceData(PostgresDialect{}, testPrimaryKeyTable, child, nil, nil, time.Now())
+	grandchild.SetResourceID("grandchild")
+	assert.Equal(t, []string{"arn:parent", "grandchild"}, grandchild.ResourceID())
+	child.SetResourceID("child")
+	assert.Equal(t, []string{"arn:parent", "child", "grandchild"}, grandchild.ResourceID())
+}
+
 // TestResourcePrimaryKey checks resource id generation when primary key is set on table
 func TestResourceAddColumns(t *testing.T) {
 	r := NewResourceData(PostgresDialect{}, testPrimaryKeyTable, nil, nil, nil, time.Now())
@@ -111,3 +128,33 @@ func TestResources(t *testing.T) {
 	_ = r2.GenerateCQId()
 	assert.Equal(t, []uuid.UUID{r1.Id(), r2.Id()}, rr.GetIds())
 }
+
+func TestResourceCQIDResolver(t *testing.T) {
+	table := &Table{
+		Name:         "test_cq_id_resolver",
+		CQIDResolver: CQIDFromColumns("arn"),
+		Columns:      []Column{{Name: "arn", Type: TypeString}, {Name: "name", Type: TypeString}},
+	}
+	newResource := func(arn, name interface{}) *Resource {
+		r := NewResourceData(PostgresDialect{}, table, nil, nil, nil, time.Now())
+		assert.NoError(t, r.Set("arn", arn))
+		assert.NoError(t, r.Set("name", name))
+		return r
+	}
+
+	r1, r2 := newResource("arn:1", "first"), newResource("arn:1", "second")
+	assert.NoError(t, r1.GenerateCQId())
+	assert.NoError(t, r2.GenerateCQId())
+	// the id only depends on the resolver's columns, and is stable between fetches
+	assert.Equal(t, r1.Id(), r2.Id())
+	assert.Equal(t, "46665193-eca1-592c-b60a-3809d270c9d9", r1.Id().String())
+
+	r3 := newResource("arn:2", "first")
+	assert.NoError(t, r3.GenerateCQId())
+	assert.NotEqual(t, r1.Id(), r3.Id())
+
+	missing := newResource(nil, "first")
+	randomId := missing.Id()
+	assert.EqualError(t, missing.GenerateCQId(), "failed to resolve cq_id for test_cq_id_resolver: column arn is nil")
+	assert.Equal(t, randomId, missing.Id())
+}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // TableResolver is the main entry point when a table fetch is called.
@@ -15,6 +18,10 @@ import (
 // - parent(Resource): resource is the parent resource in case this table is called via parent table (i.e. relation)
 // - res(chan interface{}): is a channel to pass results fetched by the TableResolver
 //
+// A diag.Diagnostic (or diag.Diagnostics) sent on res is reported without stopping the fetch, allowing the resolver to
+// skip items it fails to process. Use diag.WithResourceId to tie the diagnostic to the identifier of the skipped item.
+// Failures of a relation's resolver are tied to the id of parent, see Resource SetResourceID.
+//
 type TableResolver func(ctx context.Context, meta ClientMeta, parent *Resource, res chan<- interface{}) error
 
 // IgnoreErrorFunc checks if returned error from table resolver should be ignored.
@@ -22,11 +29,26 @@ type IgnoreErrorFunc func(err error) bool
 
 type RowResolver func(ctx context.Context, meta ClientMeta, resource *Resource) error
 
+// MultiColumnResolver sets several columns of resource in one pass, e.g. when parsing a single field of row, the item
+// returned by the TableResolver, into multiple columns.
+type MultiColumnResolver func(ctx context.Context, meta ClientMeta, resource *Resource, row interface{}) error
+
+// CQIDResolver computes the cq_id of resource, called after all its other columns were resolved. It must be deterministic,
+// returning the same id for the same resource in every fetch.
+type CQIDResolver func(resource *Resource) (uuid.UUID, error)
+
+// ResolverMiddleware wraps the TableResolver of table t, allowing cross-cutting behavior such as logging, metrics or
+// panic recovery to be added around every table resolver.
+type ResolverMiddleware func(t *Table, next TableResolver) TableResolver
+
 type Table struct {
 	// Name of table
 	Name string
 	// table description
 	Description string
+	// Tags categorize the table, e.g. compute, storage or iam, so tests can select resources by category, see
+	// the IncludeTags and ExcludeTags of the provider testing ResourceTestCase. Tags of relations aren't used.
+	Tags []string
 	// Columns are the set of fields that are part of this table
 	Columns ColumnList
 	// Relations are a set of related tables defines
@@ -39,8 +61,25 @@ type Table struct {
 	Multiplex func(meta ClientMeta) []ClientMeta
 	// DeleteFilter returns a list of key/value pairs to add when truncating this table's data from the database.
 	DeleteFilter func(meta ClientMeta, parent *Resource) []interface{}
+	// MultiColumnResolvers are called in order after all columns have been resolved, so values they set override the
+	// values set by column resolvers, and before PostResourceResolver.
+	MultiColumnResolvers []MultiColumnResolver
 	// Post resource resolver is called after all columns have been resolved, and before resource is inserted to database.
 	PostResourceResolver RowResolver
+	// Condition is consulted before fetching the table with the provider's decoded configuration, if it returns false the table
+	// and its relations are skipped with an IGNORE diagnostic.
+	Condition func(ctx context.Context, config interface{}) bool
+	// FetchTimeout is the time budget for each call of the table's Resolver. When exceeded the resolver's context is cancelled
+	// and a timeout diagnostic is returned for the resource. Zero means no per-table timeout.
+	FetchTimeout time.Duration
+	// ItemConcurrency is the number of items sent by Resolver that are resolved concurrently, each running its column,
+	// multi column and post resource resolvers. Zero or one resolves items one at a time. Resources are still inserted
+	// in the order their items were sent, but resolvers of different items run concurrently so they must be safe for
+	// concurrent use, and the order of their diagnostics isn't deterministic. Relations are resolved after the insert as usual.
+	ItemConcurrency int
+	// CQIDResolver computes the cq_id of the table's resources instead of hashing their primary keys, or generating a
+	// random one for tables without primary keys, e.g. from a natural unique id of the API with CQIDFromColumns.
+	CQIDResolver CQIDResolver
 	// Options allow modification of how the table is defined when created
 	Options TableCreationOptions
 	// AlwaysDelete will always delete table data on fetch regardless if delete is disabled on run,
@@ -65,6 +104,15 @@ type Table struct {
 type TableCreationOptions struct {
 	// List of columns to set as primary keys. If this is empty, a random unique ID is generated.
 	PrimaryKeys []string
+	// ParentIdNotNull declares the parent id column of a relation (resolved by ParentIdResolver) NOT NULL. Relations always
+	// reference their parent's cq_id with ON DELETE CASCADE, so child rows can't exist without their parent.
+	ParentIdNotNull bool
+	// SyncTime adds the internal _cq_sync_time column, set to the time the fetch started for all rows stored by the
+	// fetch, so downstream consumers can tell which rows were present in the latest sync. Rows stored by previous
+	// fetches and not fetched again keep their older sync time, unless cascade deleted with their parent or removed as
+	// stale data. Global tables upserting their rows update it like any other column. Relations wanting the column
+	// must set the option too.
+	SyncTime bool
 }
 
 func (t Table) Column(name string) *Column {
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// TypeResolver converts a value of a Go type to the value stored in its column, e.g. a time.Duration to its seconds
+type TypeResolver func(v interface{}) (interface{}, error)
+
+var (
+	// typeResolversLock serializes registrations, which replace the map of typeResolvers rather than modifying it
+	typeResolversLock sync.Mutex
+	// typeResolvers holds the map[reflect.Type]TypeResolver of the registered resolvers, loaded without locking so
+	// resolvers may register resolvers themselves
+	typeResolvers atomic.Value
+)
+
+// RegisterTypeResolver registers resolver converting every value of type t set on a resource, whether by the default
+// column resolver, a ColumnResolver or the test harness, before it's stored. Pointers to t are converted as well,
+// unless a resolver is registered for the pointer type itself, nil pointers being stored as NULL. Registering a nil
+// resolver removes the one registered for t. Resolvers are global, so they're usually registered by the provider's init.
+func RegisterTypeResolver(t reflect.Type, resolver func(v interface{}) (interface{}, error)) {
+	typeResolversLock.Lock()
+	defer typeResolversLock.Unlock()
+	current := loadTypeResolvers()
+	resolvers := make(map[reflect.Type]TypeResolver, len(current)+1)
+	for k, v := range current {
+		resolvers[k] = v
+	}
+	if resolver == nil {
+		delete(resolvers, t)
+	} else {
+		resolvers[t] = resolver
+	}
+	typeResolvers.Store(resolvers)
+}
+
+// loadTypeResolvers returns the registered resolvers, the map must not be modified
+func loadTypeResolvers() map[reflect.Type]TypeResolver {
+	resolvers, _ := typeResolvers.Load().(map[reflect.Type]TypeResolver)
+	return resolvers
+}
+
+// resolveType returns v converted by the TypeResolver registered for its type, or v as is if there's none
+func resolveType(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	resolvers := loadTypeResolvers()
+	if len(resolvers) == 0 {
+		return v, nil
+	}
+	t := reflect.TypeOf(v)
+	if resolver, ok := resolvers[t]; ok {
+		return resolver(v)
+	}
+	if t.Kind() != reflect.Ptr {
+		return v, nil
+	}
+	resolver, ok := resolvers[t.Elem()]
+	if !ok {
+		return v, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return nil, nil
+	}
+	return resolver(rv.Elem().Interface())
+}
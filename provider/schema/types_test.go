@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTypeResolver(t *testing.T) {
+	durationType := reflect.TypeOf(time.Duration(0))
+	RegisterTypeResolver(durationType, func(v interface{}) (interface{}, error) {
+		return v.(time.Duration).Seconds(), nil
+	})
+	defer RegisterTypeResolver(durationType, nil)
+
+	table := &Table{
+		Name:    "test_durations",
+		Columns: []Column{{Name: "timeout", Type: TypeFloat}, {Name: "name", Type: TypeString}},
+	}
+	r := NewResourceData(PostgresDialect{}, table, nil, nil, nil, time.Now())
+	require.NoError(t, r.Set("timeout", 90*time.Second))
+	assert.Equal(t, 90.0, r.Get("timeout"))
+	d := 1500 * time.Millisecond
+	require.NoError(t, r.Set("timeout", &d))
+	assert.Equal(t, 1.5, r.Get("timeout"))
+	require.NoError(t, r.Set("timeout", (*time.Duration)(nil)))
+	assert.Nil(t, r.Get("timeout"))
+	// values of other types are set as is
+	require.NoError(t, r.Set("name", "web"))
+	assert.Equal(t, "web", r.Get("name"))
+
+	RegisterTypeResolver(durationType, func(v interface{}) (interface{}, error) {
+		return nil, errors.New("negative duration")
+	})
+	assert.EqualError(t, r.Set("timeout", -time.Second), "failed to convert value of column timeout: negative duration")
+
+	RegisterTypeResolver(durationType, nil)
+	require.NoError(t, r.Set("timeout", time.Second))
+	assert.Equal(t, time.Second, r.Get("timeout"))
+}
+
+func TestRegisterTypeResolver_FromResolver(t *testing.T) {
+	durationType := reflect.TypeOf(time.Duration(0))
+	type seconds float64
+	secondsType := reflect.TypeOf(seconds(0))
+	// a resolver registering another resolver doesn't deadlock
+	RegisterTypeResolver(durationType, func(v interface{}) (interface{}, error) {
+		RegisterTypeResolver(secondsType, func(v interface{}) (interface{}, error) { return float64(v.(seconds)), nil })
+		return v.(time.Duration).Seconds(), nil
+	})
+	defer RegisterTypeResolver(durationType, nil)
+	defer RegisterTypeResolver(secondsType, nil)
+
+	v, err := resolveType(2 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, v)
+	v, err = resolveType(seconds(3))
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, v)
+}
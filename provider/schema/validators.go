@@ -16,13 +16,20 @@ const (
 	maxColumnName = 63
 )
 
+// DuplicateColumnTableValidator checks no table, or relation, declares two columns of the same name, see
+// ValidateColumnNamesUnique
+type DuplicateColumnTableValidator struct{}
+
 var defaultValidators = []TableValidator{
 	LengthTableValidator{},
+	DuplicateColumnTableValidator{},
 }
 
 func ValidateTable(t *Table) error {
 	for _, validator := range defaultValidators {
-		return validator.Validate(t)
+		if err := validator.Validate(t); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -53,3 +60,27 @@ func validateTableAttributesNameLength(t *Table) error {
 func (LengthTableValidator) Validate(t *Table) error {
 	return validateTableAttributesNameLength(t)
 }
+
+func (DuplicateColumnTableValidator) Validate(t *Table) error {
+	if err := ValidateColumnNamesUnique(t.Name, t.Columns); err != nil {
+		return err
+	}
+	for _, rel := range t.Relations {
+		if err := (DuplicateColumnTableValidator{}).Validate(rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateColumnNamesUnique returns an error naming the table and column if two of its columns share a name
+func ValidateColumnNamesUnique(table string, columns ColumnList) error {
+	names := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		if names[c.Name] {
+			return fmt.Errorf("table %s declares column %s more than once", table, c.Name)
+		}
+		names[c.Name] = true
+	}
+	return nil
+}
@@ -41,3 +41,23 @@ func TestTableValidators(t *testing.T) {
 	err = ValidateTable(&tableWithLongColumnName)
 	assert.Error(t, err)
 }
+
+func TestDuplicateColumnTableValidator(t *testing.T) {
+	table := &Table{
+		Name:    "test_duplicates",
+		Columns: []Column{{Name: "name", Type: TypeString}, {Name: "id", Type: TypeString}},
+		Relations: []*Table{
+			{Name: "test_duplicates_child", Columns: []Column{{Name: "name", Type: TypeString}}},
+		},
+	}
+	assert.NoError(t, DuplicateColumnTableValidator{}.Validate(table))
+	assert.NoError(t, ValidateTable(table))
+
+	table.Relations[0].Columns = append(table.Relations[0].Columns, Column{Name: "name", Type: TypeBigInt})
+	assert.EqualError(t, DuplicateColumnTableValidator{}.Validate(table), "table test_duplicates_child declares column name more than once")
+
+	// names are quoted in the generated DDL, so names differing by case are distinct columns
+	table.Relations[0].Columns = table.Relations[0].Columns[:1]
+	table.Columns = append(table.Columns, Column{Name: "ID", Type: TypeString})
+	assert.NoError(t, ValidateTable(table))
+}
@@ -0,0 +1,124 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/cqproto"
+	"github.com/cloudquery/cq-provider-sdk/testlog"
+	"github.com/hashicorp/go-hclog"
+)
+
+// benchmarkTopAllocators is the amount of resources reported by BenchmarkResource as the top allocators
+const benchmarkTopAllocators = 10
+
+// resourceAllocations are the allocations made while fetching a resource, accumulated over all benchmark iterations
+type resourceAllocations struct {
+	Resource string
+	Mallocs  uint64
+	Bytes    uint64
+}
+
+// BenchmarkResource fetches every resource of the provider b.N times, one resource at a time, so the runtime memory
+// statistics captured around each fetch can be attributed to it. It reports the allocations and allocated bytes per
+// fetch of each resource as metrics, and logs the top allocators. Resources are fetched into the test database like
+// TestResource, but aren't verified.
+func BenchmarkResource(b *testing.B, resource ResourceTestCase) {
+	b.Helper()
+
+	dbURL, err := resource.databaseURL()
+	if err != nil {
+		b.Fatal(err)
+	}
+	db, err := setupDatabase(dbURL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if resource.DBSchema != "" {
+		if err := db.Exec(context.Background(), fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", strconv.Quote(resource.DBSchema))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	l := testlog.New(b)
+	l.SetLevel(hclog.Warn)
+	resource.Provider.Logger = l
+
+	if err := dropAndCreateTables(context.Background(), db, resource.DBSchema, providerTables(resource.Provider), resource.TypeOverrides); err != nil {
+		b.Fatal(err)
+	}
+	config, err := resource.providerConfig()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if resp, err := resource.Provider.ConfigureProvider(context.Background(), &cqproto.ConfigureProviderRequest{
+		Connection: cqproto.ConnectionDetails{DSN: dbURL},
+		Config:     config,
+	}); err != nil {
+		b.Fatal(err)
+	} else if resp != nil && resp.Diagnostics.HasErrors() {
+		b.Fatal(resp.Diagnostics)
+	}
+
+	names := make([]string, 0, len(resource.Provider.ResourceMap))
+	for name, table := range resource.Provider.ResourceMap {
+		if !resource.SkipIgnoreInTest && table.IgnoreInTests {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	allocations := make([]resourceAllocations, len(names))
+	var before, after runtime.MemStats
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, name := range names {
+			sender := newTestResourceSender(resource.MaxErrors)
+			runtime.ReadMemStats(&before)
+			err := resource.Provider.FetchResources(context.Background(), &cqproto.FetchResourcesRequest{
+				Resources:                 []string{name},
+				MaxItemsPerResource:       resource.MaxItemsPerResource,
+				MaxTotalRows:              resource.MaxTotalRows,
+				MaxConnectionsPerResource: resource.MaxConnectionsPerResource,
+			}, sender)
+			runtime.ReadMemStats(&after)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(sender.Errors) > 0 {
+				b.Fatalf("error/s occur during benchmark, %s", formatErrors(sender.Errors, resource.ErrorFormat))
+			}
+			allocations[j].Resource = name
+			allocations[j].Mallocs += after.Mallocs - before.Mallocs
+			allocations[j].Bytes += after.TotalAlloc - before.TotalAlloc
+		}
+	}
+	b.StopTimer()
+
+	for _, a := range allocations {
+		b.ReportMetric(float64(a.Mallocs)/float64(b.N), a.Resource+"-allocs/op")
+		b.ReportMetric(float64(a.Bytes)/float64(b.N), a.Resource+"-B/op")
+	}
+	b.Logf("top allocating resources:\n%s", formatTopAllocators(allocations, b.N))
+}
+
+// formatTopAllocators renders the resources allocating most bytes per fetch, one per line
+func formatTopAllocators(allocations []resourceAllocations, n int) string {
+	sorted := make([]resourceAllocations, len(allocations))
+	copy(sorted, allocations)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Bytes > sorted[j].Bytes })
+	if len(sorted) > benchmarkTopAllocators {
+		sorted = sorted[:benchmarkTopAllocators]
+	}
+	var sb strings.Builder
+	for i, a := range sorted {
+		fmt.Fprintf(&sb, "%d. %s: %d B/op, %d allocs/op\n", i+1, a.Resource, a.Bytes/uint64(n), a.Mallocs/uint64(n))
+	}
+	return sb.String()
+}
@@ -0,0 +1,18 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTopAllocators(t *testing.T) {
+	allocations := []resourceAllocations{
+		{Resource: "small", Mallocs: 20, Bytes: 200},
+		{Resource: "large", Mallocs: 10, Bytes: 4000},
+		{Resource: "medium", Mallocs: 40, Bytes: 1000},
+	}
+	assert.Equal(t, "1. large: 2000 B/op, 5 allocs/op\n2. medium: 500 B/op, 20 allocs/op\n3. small: 100 B/op, 10 allocs/op\n", formatTopAllocators(allocations, 2))
+	// input order is kept
+	assert.Equal(t, "small", allocations[0].Resource)
+}
@@ -0,0 +1,11 @@
+package testing
+
+import "time"
+
+// FixedClock is a schema.Clock always telling the same time, install it via ResourceTestCase.Clock
+type FixedClock time.Time
+
+// Now returns the fixed time
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
@@ -0,0 +1,33 @@
+package testing
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// providerConfig returns the provider configuration of the test case, either Config or ConfigStruct encoded to HCL
+func (r ResourceTestCase) providerConfig() ([]byte, error) {
+	if r.ConfigStruct == nil {
+		return []byte(r.Config), nil
+	}
+	if r.Config != "" {
+		return nil, errors.New("only one of Config and ConfigStruct may be set")
+	}
+	return encodeConfig(r.ConfigStruct)
+}
+
+// encodeConfig encodes v, a struct or pointer to struct with gohcl `hcl` tags, to HCL
+func encodeConfig(v interface{}) (data []byte, err error) {
+	// gohcl panics on values it can't encode rather than returning an error
+	defer func() {
+		if r := recover(); r != nil {
+			data, err = nil, fmt.Errorf("failed to encode ConfigStruct: %v", r)
+		}
+	}()
+	f := hclwrite.NewEmptyFile()
+	gohcl.EncodeIntoBody(v, f.Body())
+	return f.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/cqproto"
+	"github.com/cloudquery/cq-provider-sdk/provider"
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAccount struct {
+	ID      string `hcl:"id,label"`
+	RoleARN string `hcl:"role_arn,optional"`
+}
+
+type testConfig struct {
+	Regions    []string      `hcl:"regions,optional"`
+	MaxRetries int           `hcl:"max_retries,optional"`
+	Accounts   []testAccount `hcl:"accounts,block"`
+}
+
+func (testConfig) Example() string { return "" }
+
+func TestResourceTestCase_providerConfig(t *testing.T) {
+	expected := testConfig{
+		Regions:    []string{"us-east-1", "eu-west-1"},
+		MaxRetries: 3,
+		Accounts:   []testAccount{{ID: "dev", RoleARN: "arn:aws:iam::123:role/dev"}},
+	}
+	data, err := ResourceTestCase{ConfigStruct: &expected}.providerConfig()
+	require.NoError(t, err)
+
+	var actual testConfig
+	require.NoError(t, hclsimple.Decode("config.hcl", data, nil, &actual), string(data))
+	assert.Equal(t, expected, actual)
+
+	data, err = ResourceTestCase{Config: "max_retries = 1"}.providerConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "max_retries = 1", string(data))
+
+	_, err = ResourceTestCase{Config: "max_retries = 1", ConfigStruct: expected}.providerConfig()
+	assert.Error(t, err)
+
+	_, err = ResourceTestCase{ConfigStruct: "max_retries = 1"}.providerConfig()
+	assert.Error(t, err)
+}
+
+type testClient struct{}
+
+func (testClient) Logger() hclog.Logger { return hclog.NewNullLogger() }
+
+func TestConfigure_ClientFactory(t *testing.T) {
+	var configured bool
+	p := &provider.Provider{
+		Name:   "test",
+		Logger: hclog.NewNullLogger(),
+		Config: func() provider.Config { return &testConfig{} },
+		Configure: func(hclog.Logger, interface{}) (schema.ClientMeta, diag.Diagnostics) {
+			configured = true
+			return nil, diag.FromError(errors.New("live OAuth exchange"), diag.ACCESS)
+		},
+	}
+	var received interface{}
+	resource := ResourceTestCase{
+		Provider: p,
+		Config:   "max_retries = 2",
+		ClientFactory: func(_ context.Context, config interface{}) (schema.ClientMeta, diag.Diagnostics) {
+			received = config
+			return testClient{}, nil
+		},
+	}
+	require.NoError(t, configure(context.Background(), &resource, ""))
+	assert.Equal(t, &testConfig{MaxRetries: 2}, received)
+	assert.False(t, configured)
+	// the shared provider isn't configured, nor has its Configure replaced
+	assert.NotSame(t, p, resource.Provider)
+	resp, err := p.ConfigureProvider(context.Background(), &cqproto.ConfigureProviderRequest{})
+	require.NoError(t, err)
+	assert.True(t, resp.Diagnostics.HasErrors())
+	assert.True(t, configured)
+}
+
+func TestResourceTestCase_selectedResources(t *testing.T) {
+	p := &provider.Provider{
+		Name: "test",
+		ResourceMap: map[string]*schema.Table{
+			"compute.instances": {Name: "test_instances", Tags: []string{"compute"}},
+			"storage.buckets":   {Name: "test_buckets", Tags: []string{"storage"}},
+			"storage.volumes":   {Name: "test_volumes", Tags: []string{"storage", "compute"}},
+			"iam.users":         {Name: "test_users", Tags: []string{"iam"}},
+			"untagged":          {Name: "test_untagged"},
+		},
+	}
+	all := []string{"compute.instances", "iam.users", "storage.buckets", "storage.volumes", "untagged"}
+	assert.Equal(t, all, ResourceTestCase{Provider: p}.selectedResources())
+	assert.Equal(t, []string{"storage.buckets", "storage.volumes"}, ResourceTestCase{Provider: p, IncludeTags: []string{"storage"}}.selectedResources())
+	assert.Equal(t, []string{"compute.instances", "iam.users", "storage.volumes"}, ResourceTestCase{Provider: p, IncludeTags: []string{"compute", "iam"}}.selectedResources())
+	assert.Equal(t, []string{"iam.users", "storage.buckets", "untagged"}, ResourceTestCase{Provider: p, ExcludeTags: []string{"compute"}}.selectedResources())
+	assert.Equal(t, []string{"storage.buckets"}, ResourceTestCase{Provider: p, IncludeTags: []string{"storage"}, ExcludeTags: []string{"compute"}}.selectedResources())
+}
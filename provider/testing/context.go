@@ -0,0 +1,62 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/jackc/pgx/v4"
+)
+
+// contextQueryExecer wraps an execution.QueryExecer, running the calls passed a context that's never canceled, such as
+// the context.Background of verifiers, with the context of the test instead, so canceling the test aborts them
+type contextQueryExecer struct {
+	execution.QueryExecer
+	ctx context.Context
+}
+
+// withTestContext returns conn running its calls with ctx, see contextQueryExecer. If ctx is never canceled conn is
+// returned as is.
+func withTestContext(ctx context.Context, conn execution.QueryExecer) execution.QueryExecer {
+	if ctx.Done() == nil {
+		return conn
+	}
+	return contextQueryExecer{QueryExecer: conn, ctx: ctx}
+}
+
+func (c contextQueryExecer) Exec(ctx context.Context, query string, args ...interface{}) error {
+	ctx, err := c.context(ctx)
+	if err != nil {
+		return err
+	}
+	return c.QueryExecer.Exec(ctx, query, args...)
+}
+
+func (c contextQueryExecer) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	ctx, err := c.context(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.QueryExecer.Query(ctx, query, args...)
+}
+
+// context returns the context a call passed ctx runs with, or an error if the test is canceled already
+func (c contextQueryExecer) context(ctx context.Context) (context.Context, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, fmt.Errorf("test canceled: %w", err)
+	}
+	if ctx.Done() == nil {
+		return c.ctx, nil
+	}
+	return ctx, nil
+}
+
+// checkCanceled stops the test if ctx is done, e.g. canceled by the caller of TestResourceCtx, stating what was
+// interrupted rather than reporting the errors the cancellation caused
+func checkCanceled(ctx context.Context, t *testing.T, stage string) {
+	t.Helper()
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("test canceled while %s: %s", stage, err)
+	}
+}
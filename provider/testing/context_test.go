@@ -0,0 +1,48 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ctxRecorder records the context of its last call
+type ctxRecorder struct {
+	ctx *context.Context
+}
+
+func (r ctxRecorder) Exec(ctx context.Context, _ string, _ ...interface{}) error {
+	*r.ctx = ctx
+	return nil
+}
+
+func (r ctxRecorder) Query(ctx context.Context, _ string, _ ...interface{}) (pgx.Rows, error) {
+	*r.ctx = ctx
+	return nil, nil
+}
+
+func TestWithTestContext(t *testing.T) {
+	var called context.Context
+	recorder := ctxRecorder{ctx: &called}
+	assert.Equal(t, recorder, withTestContext(context.Background(), recorder))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := withTestContext(ctx, recorder)
+	// uncancelable contexts are replaced by the test's, others are kept
+	assert.NoError(t, conn.Exec(context.Background(), "SELECT 1"))
+	assert.Equal(t, ctx, called)
+	own, cancelOwn := context.WithCancel(context.Background())
+	defer cancelOwn()
+	_, err := conn.Query(own, "SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, own, called)
+
+	cancel()
+	called = nil
+	assert.EqualError(t, conn.Exec(context.Background(), "SELECT 1"), "test canceled: context canceled")
+	_, err = conn.Query(own, "SELECT 1")
+	assert.EqualError(t, err, "test canceled: context canceled")
+	assert.Nil(t, called)
+}
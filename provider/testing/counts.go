@@ -0,0 +1,59 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// RowCounts maps table names to their row counts
+type RowCounts map[string]RowCount
+
+// RowCount is the number of rows in a table along with the row counts of its relations
+type RowCount struct {
+	Count     int64
+	Relations RowCounts
+}
+
+// CollectRowCounts counts the rows in table and, recursively, in all of its relations
+func CollectRowCounts(conn pgxscan.Querier, table *schema.Table) (RowCounts, error) {
+	var count int64
+	if err := pgxscan.Get(context.Background(), conn, &count, fmt.Sprintf("SELECT count(*) FROM %s", strconv.Quote(table.Name))); err != nil {
+		return nil, fmt.Errorf("failed to count rows of table %s: %w", table.Name, err)
+	}
+	rc := RowCount{Count: count, Relations: make(RowCounts, len(table.Relations))}
+	for _, rel := range table.Relations {
+		relCounts, err := CollectRowCounts(conn, rel)
+		if err != nil {
+			return nil, err
+		}
+		for name, c := range relCounts {
+			rc.Relations[name] = c
+		}
+	}
+	return RowCounts{table.Name: rc}, nil
+}
+
+// String returns the row counts as an indented tree, sorted by table name
+func (rc RowCounts) String() string {
+	b := &strings.Builder{}
+	rc.write(b, 0)
+	return b.String()
+}
+
+func (rc RowCounts) write(b *strings.Builder, depth int) {
+	names := make([]string, 0, len(rc))
+	for name := range rc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s%s: %d\n", strings.Repeat("  ", depth), name, rc[name].Count)
+		rc[name].Relations.write(b, depth+1)
+	}
+}
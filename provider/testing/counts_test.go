@@ -0,0 +1,58 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countQuerier answers the count queries of CollectRowCounts with the row count of each table, failing for tables it
+// has no count of
+type countQuerier map[string]int64
+
+func (q countQuerier) Query(_ context.Context, query string, _ ...interface{}) (pgx.Rows, error) {
+	for name, count := range q {
+		if query == fmt.Sprintf("SELECT count(*) FROM %s", strconv.Quote(name)) {
+			return &bufferedRows{
+				connInfo: pgtype.NewConnInfo(),
+				fields:   []pgproto3.FieldDescription{{Name: []byte("count"), DataTypeOID: pgtype.Int8OID, Format: pgx.TextFormatCode}},
+				values:   [][][]byte{{[]byte(strconv.FormatInt(count, 10))}},
+				current:  -1,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected query %s", query)
+}
+
+func TestCollectRowCounts(t *testing.T) {
+	table := &schema.Table{
+		Name: "test_instances",
+		Relations: []*schema.Table{
+			{Name: "test_instance_disks", Relations: []*schema.Table{{Name: "test_instance_disk_snapshots"}}},
+			{Name: "test_instance_tags"},
+		},
+	}
+	counts, err := CollectRowCounts(countQuerier{
+		"test_instances":               2,
+		"test_instance_disks":          3,
+		"test_instance_disk_snapshots": 0,
+		"test_instance_tags":           5,
+	}, table)
+	require.NoError(t, err)
+	assert.Equal(t, RowCounts{"test_instances": {Count: 2, Relations: RowCounts{
+		"test_instance_disks": {Count: 3, Relations: RowCounts{"test_instance_disk_snapshots": {Count: 0, Relations: RowCounts{}}}},
+		"test_instance_tags":  {Count: 5, Relations: RowCounts{}},
+	}}}, counts)
+	assert.Equal(t, "test_instances: 2\n  test_instance_disks: 3\n    test_instance_disk_snapshots: 0\n  test_instance_tags: 5\n", counts.String())
+
+	_, err = CollectRowCounts(countQuerier{"test_instances": 2, "test_instance_disks": 3}, table)
+	assert.EqualError(t, err, `failed to count rows of table test_instance_disk_snapshots: scany: query one result row: unexpected query SELECT count(*) FROM "test_instance_disk_snapshots"`)
+}
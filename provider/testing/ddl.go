@@ -0,0 +1,105 @@
+package testing
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/migration"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const createTablePrefix = "CREATE TABLE IF NOT EXISTS "
+
+// PrintDDLEnv if set to true, TestResource logs the statements creating the tables, see migration.PrintPlan, before
+// applying them
+const PrintDDLEnv = "CQ_PRINT_DDL"
+
+// logDDLPlan logs the statements creating tables in the dialect named by TestDialectEnv, with the types of overrides
+// replacing its type mapping
+func logDDLPlan(t *testing.T, tables []*schema.Table, overrides map[schema.ValueType]string) {
+	t.Helper()
+	dialect, err := testDialect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := migration.PrintPlan(&b, withTypeOverrides(dialect, overrides), tables); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("DDL plan:\n%s", b.String())
+}
+
+// typeOverrideDialect is a dialect whose DDL types of the value types in overrides are replaced by theirs
+type typeOverrideDialect struct {
+	schema.Dialect
+	overrides map[schema.ValueType]string
+}
+
+func (d typeOverrideDialect) DBTypeFromType(v schema.ValueType) string {
+	if typ, ok := d.overrides[v]; ok {
+		return typ
+	}
+	return d.Dialect.DBTypeFromType(v)
+}
+
+// withTypeOverrides returns dialect with the DDL types of the value types in overrides replaced by theirs
+func withTypeOverrides(dialect schema.Dialect, overrides map[schema.ValueType]string) schema.Dialect {
+	if len(overrides) == 0 {
+		return dialect
+	}
+	return typeOverrideDialect{Dialect: dialect, overrides: overrides}
+}
+
+// AssertDDLColumnOrder fails unless the CREATE TABLE statements of table and its relations, built by
+// migration.CreateTableDefinitions, declare their columns in the exact order of dialect.Columns: the columns internal
+// to the SDK such as cq_id and cq_meta first, then the table's columns as declared. Consumers binding by column
+// position rely on this order.
+func AssertDDLColumnOrder(t *testing.T, dialect schema.Dialect, table *schema.Table) {
+	t.Helper()
+	ups, err := migration.CreateTableDefinitions(context.Background(), dialect, table, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := ddlColumnOrder(ups)
+	expected := make(map[string][]string)
+	var collect func(t *schema.Table)
+	collect = func(t *schema.Table) {
+		expected[t.Name] = dialect.Columns(t).Names()
+		for _, rel := range t.Relations {
+			collect(rel)
+		}
+	}
+	collect(table)
+	assert.Equal(t, expected, actual, "columns of the CREATE TABLE statements aren't in the declared order")
+}
+
+// ddlColumnOrder returns the names of the columns declared by each CREATE TABLE statement in ups, keyed by table name
+func ddlColumnOrder(ups []string) map[string][]string {
+	tables := make(map[string][]string)
+	for _, up := range ups {
+		if !strings.HasPrefix(up, createTablePrefix) {
+			continue
+		}
+		lines := strings.Split(up, "\n")
+		name, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(lines[0], createTablePrefix), " ("))
+		if err != nil {
+			continue
+		}
+		columns := []string{}
+		for _, line := range lines[1:] {
+			// column definitions start with the quoted column name, constraints with a keyword
+			line = strings.TrimPrefix(line, "\t")
+			if !strings.HasPrefix(line, `"`) {
+				continue
+			}
+			if end := strings.Index(line[1:], `"`); end >= 0 {
+				columns = append(columns, line[1:end+1])
+			}
+		}
+		tables[name] = columns
+	}
+	return tables
+}
@@ -0,0 +1,66 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/migration"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertDDLColumnOrder(t *testing.T) {
+	AssertDDLColumnOrder(t, schema.PostgresDialect{}, &schema.Table{
+		Name: "test_order",
+		Columns: []schema.Column{
+			{Name: "zeta", Type: schema.TypeString},
+			{Name: "alpha", Type: schema.TypeBigInt},
+		},
+		Relations: []*schema.Table{
+			{Name: "test_order_child", Columns: []schema.Column{{Name: "b", Type: schema.TypeJSON}, {Name: "a", Type: schema.TypeInt}}},
+		},
+	})
+	AssertDDLColumnOrder(t, schema.TSDBDialect{}, &schema.Table{
+		Name:    "test_order",
+		Columns: []schema.Column{{Name: "zeta", Type: schema.TypeString}},
+	})
+}
+
+func TestDDLColumnOrder(t *testing.T) {
+	assert.Equal(t, map[string][]string{"b_table": {"b", "a"}}, ddlColumnOrder([]string{
+		"DO $cq$ BEGIN\n\tCREATE TYPE \"b_table_a_enum\" AS ENUM ('a');\nEXCEPTION\n\tWHEN duplicate_object THEN NULL;\nEND $cq$;",
+		"CREATE TABLE IF NOT EXISTS \"b_table\" (\n\t\"b\" text,\n\t\"a\" \"b_table_a_enum\",\n\tCONSTRAINT b_table_pk PRIMARY KEY(\"a\")\n);",
+		"CREATE INDEX ON \"b_table\" (\"a\");",
+	}))
+}
+
+func TestWithTypeOverrides(t *testing.T) {
+	assert.Equal(t, schema.PostgresDialect{}, withTypeOverrides(schema.PostgresDialect{}, nil))
+
+	dialect := withTypeOverrides(schema.PostgresDialect{}, map[schema.ValueType]string{schema.TypeJSON: "jsonb", schema.TypeString: "citext"})
+	ups, err := migration.CreateTablesDefinitions(context.Background(), dialect, []*schema.Table{{
+		Name: "test_overrides",
+		Columns: []schema.Column{
+			{Name: "data", Type: schema.TypeJSON},
+			{Name: "name", Type: schema.TypeString},
+			{Name: "count", Type: schema.TypeBigInt},
+			{Name: "title", Type: schema.TypeString, MaxLength: 100},
+		},
+	}})
+	require.NoError(t, err)
+	require.Len(t, ups, 1)
+	assert.Contains(t, ups[0], "\t\"data\" jsonb,\n")
+	assert.Contains(t, ups[0], "\t\"name\" citext,\n")
+	assert.Contains(t, ups[0], "\t\"count\" bigint,\n")
+	// the override applies to columns with a MaxLength too, citext taking no length
+	assert.Contains(t, ups[0], "\t\"title\" citext,\n")
+
+	dialect = withTypeOverrides(schema.PostgresDialect{}, map[schema.ValueType]string{schema.TypeString: "varchar"})
+	ups, err = migration.CreateTablesDefinitions(context.Background(), dialect, []*schema.Table{{
+		Name:    "test_overrides",
+		Columns: []schema.Column{{Name: "title", Type: schema.TypeString, MaxLength: 100}},
+	}})
+	require.NoError(t, err)
+	assert.Contains(t, ups[0], "\t\"title\" varchar(100),\n")
+}
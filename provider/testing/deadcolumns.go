@@ -0,0 +1,113 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// DeadColumnReport aggregates which columns were never populated, i.e. NULL in every row, over one or more fetches.
+// TestResource logs the report of its fetch, and merges it into ResourceTestCase.DeadColumnReport if set, so a report
+// shared by the tests of several configs shows the columns none of them populated. Columns ignored in tests, or of
+// tables ignored in tests, are reported separately, since they aren't expected to be populated.
+type DeadColumnReport struct {
+	lock sync.Mutex
+	// populated maps each "table.column" collected to whether any fetch populated it
+	populated map[string]bool
+	// ignored are the collected columns ignored in tests
+	ignored map[string]bool
+}
+
+// NewDeadColumnReport creates an empty DeadColumnReport
+func NewDeadColumnReport() *DeadColumnReport {
+	return &DeadColumnReport{populated: make(map[string]bool), ignored: make(map[string]bool)}
+}
+
+// collect counts the non-null values of every column of table and its relations
+func (r *DeadColumnReport) collect(conn pgxscan.Querier, table *schema.Table, ignored bool) error {
+	ignored = ignored || table.IgnoreInTests
+	if len(table.Columns) > 0 {
+		counts := make([]string, len(table.Columns))
+		for i, c := range table.Columns {
+			counts[i] = fmt.Sprintf("count(%s)", strconv.Quote(c.Name))
+		}
+		var populated []int64
+		query := fmt.Sprintf("SELECT ARRAY[%s] FROM %s", strings.Join(counts, ", "), strconv.Quote(table.Name))
+		if err := pgxscan.Get(context.Background(), conn, &populated, query); err != nil {
+			return err
+		}
+		r.lock.Lock()
+		for i, c := range table.Columns {
+			key := table.Name + "." + c.Name
+			r.populated[key] = r.populated[key] || populated[i] > 0
+			if ignored || c.IgnoreInTests {
+				r.ignored[key] = true
+			}
+		}
+		r.lock.Unlock()
+	}
+	for _, rel := range table.Relations {
+		if err := r.collect(conn, rel, ignored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge adds the columns collected by other into the report
+func (r *DeadColumnReport) merge(other *DeadColumnReport) {
+	other.lock.Lock()
+	defer other.lock.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for key, populated := range other.populated {
+		r.populated[key] = r.populated[key] || populated
+	}
+	for key := range other.ignored {
+		r.ignored[key] = true
+	}
+}
+
+// DeadColumns returns the sorted "table.column" names never populated, excluding the ones ignored in tests
+func (r *DeadColumnReport) DeadColumns() []string {
+	return r.dead(false)
+}
+
+// IgnoredColumns returns the sorted "table.column" names never populated which are ignored in tests
+func (r *DeadColumnReport) IgnoredColumns() []string {
+	return r.dead(true)
+}
+
+func (r *DeadColumnReport) dead(ignored bool) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var ret []string
+	for key, populated := range r.populated {
+		if !populated && r.ignored[key] == ignored {
+			ret = append(ret, key)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (r *DeadColumnReport) String() string {
+	dead, ignored := r.DeadColumns(), r.IgnoredColumns()
+	if len(dead) == 0 && len(ignored) == 0 {
+		return "all columns were populated"
+	}
+	var sb strings.Builder
+	if len(dead) > 0 {
+		fmt.Fprintf(&sb, "never populated columns:\n\t%s\n", strings.Join(dead, "\n\t"))
+	}
+	if len(ignored) > 0 {
+		fmt.Fprintf(&sb, "never populated columns ignored in tests:\n\t%s\n", strings.Join(ignored, "\n\t"))
+	}
+	return sb.String()
+}
@@ -0,0 +1,23 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadColumnReport_merge(t *testing.T) {
+	first := NewDeadColumnReport()
+	first.populated = map[string]bool{"t.a": true, "t.b": false, "t.c": false, "t.ignored": false}
+	first.ignored = map[string]bool{"t.ignored": true}
+	second := NewDeadColumnReport()
+	second.populated = map[string]bool{"t.a": false, "t.b": true, "t.c": false, "t.d": false}
+
+	report := NewDeadColumnReport()
+	assert.Equal(t, "all columns were populated", report.String())
+	report.merge(first)
+	report.merge(second)
+	assert.Equal(t, []string{"t.c", "t.d"}, report.DeadColumns())
+	assert.Equal(t, []string{"t.ignored"}, report.IgnoredColumns())
+	assert.Equal(t, "never populated columns:\n\tt.c\n\tt.d\nnever populated columns ignored in tests:\n\tt.ignored\n", report.String())
+}
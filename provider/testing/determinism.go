@@ -0,0 +1,198 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/thoas/go-funk"
+)
+
+// deterministicSchemaSuffix is appended to the schema of the test case to name the schema of the second fetch of
+// AssertDeterministic
+const deterministicSchemaSuffix = "_deterministic"
+
+// maxDeterminismDiffs is the amount of differing rows reported per table, the rest are only counted
+const maxDeterminismDiffs = 10
+
+// verifyDeterministic fetches the resources of the test case again with second, a copy of the test case with an
+// unconfigured copy of its provider, into a schema of its own, failing on any difference between the rows of tables
+// in the schema of the first fetch and the second
+func verifyDeterministic(ctx context.Context, t *testing.T, resource, second *ResourceTestCase, conn pgxscan.Querier, tables []*schema.Table) {
+	t.Helper()
+	dbURL, err := second.databaseURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := setupDatabase(dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondConn := withTestContext(ctx, db)
+	if err := secondConn.Exec(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", strconv.Quote(second.DBSchema))); err != nil {
+		t.Fatal(err)
+	}
+	if err := dropAndCreateTables(ctx, secondConn, second.DBSchema, tables, second.TypeOverrides); err != nil {
+		t.Fatalf("failed to create tables in schema %s: %s", second.DBSchema, err)
+	}
+	if err := configure(ctx, second, dbURL); err != nil {
+		checkCanceled(ctx, t, "configuring the provider again")
+		t.Fatalf("failed to configure the provider for the second fetch: %s", err)
+	}
+	t.Logf("fetching again into schema %s to verify the fetch is deterministic", second.DBSchema)
+	if _, err := fetch(ctx, t, second, dbURL); err != nil {
+		checkCanceled(ctx, t, "fetching resources again")
+		t.Fatalf("second fetch failed: %s", err)
+	}
+	for _, table := range tables {
+		if err := diffTables(conn, dbSchemaName(resource.DBSchema), second.DBSchema, table, resource.DeterministicIgnoreColumns, func(diff string) {
+			t.Errorf("fetch isn't deterministic: %s", diff)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resource.PreserveOnFailure && t.Failed() {
+		t.Logf("test failed, preserving tables of the second fetch for inspection in schema %q", second.DBSchema)
+		return
+	}
+	for _, table := range tables {
+		if err := dropTables(ctx, secondConn, second.DBSchema, table); err != nil {
+			t.Errorf("failed to drop table %s: %s", table.Name, err)
+		}
+	}
+}
+
+// diffTables reports every difference of the rows of table and its relations between the schemas first and second
+func diffTables(conn pgxscan.Querier, first, second string, table *schema.Table, ignore []string, report func(string)) error {
+	columns := deterministicColumns(table, ignore)
+	if len(columns) > 0 {
+		firstRows, err := selectTableRows(conn, first, table.Name, columns)
+		if err != nil {
+			return err
+		}
+		secondRows, err := selectTableRows(conn, second, table.Name, columns)
+		if err != nil {
+			return err
+		}
+		for _, diff := range diffRows(table, columns, firstRows, secondRows) {
+			report(diff)
+		}
+	}
+	for _, rel := range table.Relations {
+		if err := diffTables(conn, first, second, rel, ignore, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deterministicColumns returns the declared columns of table compared between the fetches, leaving out the ignored
+// ones, named "column" for every table or "table.column", encrypted ones, whose ciphertext differs between fetches, and
+// the parent cq_id column, which differs unless the parent has primary keys
+func deterministicColumns(table *schema.Table, ignore []string) []string {
+	var parentID string
+	if pc := schema.FindParentIdColumn(table); pc != nil {
+		parentID = pc.Name
+	}
+	columns := make([]string, 0, len(table.Columns))
+	for _, c := range table.Columns {
+		if c.Encrypt != nil || c.Name == parentID || funk.ContainsString(ignore, c.Name) || funk.ContainsString(ignore, table.Name+"."+c.Name) {
+			continue
+		}
+		columns = append(columns, c.Name)
+	}
+	return columns
+}
+
+// selectTableRows selects columns of the table name in dbSchema as text
+func selectTableRows(conn pgxscan.Querier, dbSchema, name string, columns []string) ([]Row, error) {
+	selected := make([]string, len(columns))
+	for i, c := range columns {
+		selected[i] = fmt.Sprintf("%[1]s::text AS %[1]s", strconv.Quote(c))
+	}
+	query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).Select(selected...).From(qualifiedName(dbSchema, name)).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to select rows of table %s in schema %s: %w", name, dbSchema, err)
+	}
+	return rows, nil
+}
+
+// diffRows returns the differences between the rows of table of the first fetch and the second, all holding columns.
+// Rows are matched by the table's primary keys if they're all compared, reporting the columns that differ, otherwise
+// rows differing in any column are reported as missing from either fetch.
+func diffRows(table *schema.Table, columns []string, first, second []Row) []string {
+	pks := table.Options.PrimaryKeys
+	if len(pks) == 0 || len(funk.IntersectString(pks, columns)) != len(pks) {
+		pks = nil
+	}
+	key := func(row Row) string {
+		if pks != nil {
+			return formatPrimaryKey(table, row, pks)
+		}
+		return encodeDeterminismRow(table, row)
+	}
+	firstRows, secondRows := groupRows(first, key), groupRows(second, key)
+	keys := make([]string, 0, len(firstRows)+len(secondRows))
+	for k := range firstRows {
+		keys = append(keys, k)
+	}
+	for k := range secondRows {
+		if _, ok := firstRows[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var diffs []string
+	for _, k := range keys {
+		a, b := firstRows[k], secondRows[k]
+		switch {
+		case len(b) < len(a):
+			diffs = append(diffs, fmt.Sprintf("table %s row %s is missing from the second fetch", table.Name, k))
+		case len(a) < len(b):
+			diffs = append(diffs, fmt.Sprintf("table %s row %s is missing from the first fetch", table.Name, k))
+		case pks != nil:
+			for _, c := range columns {
+				// values are selected as text, so they're strings or nil
+				if v1, v2 := a[0][c], b[0][c]; v1 != v2 {
+					diffs = append(diffs, fmt.Sprintf("table %s row %s column %s differs: %v != %v", table.Name, k, c, maskValue(table, c, v1), maskValue(table, c, v2)))
+				}
+			}
+		}
+	}
+	if len(diffs) > maxDeterminismDiffs {
+		diffs = append(diffs[:maxDeterminismDiffs], fmt.Sprintf("table %s has %d more differences", table.Name, len(diffs)-maxDeterminismDiffs))
+	}
+	return diffs
+}
+
+// groupRows groups rows by their key
+func groupRows(rows []Row, key func(Row) string) map[string][]Row {
+	grouped := make(map[string][]Row, len(rows))
+	for _, row := range rows {
+		k := key(row)
+		grouped[k] = append(grouped[k], row)
+	}
+	return grouped
+}
+
+// encodeDeterminismRow encodes row as JSON, keys sorted and values of sensitive columns masked
+func encodeDeterminismRow(table *schema.Table, row Row) string {
+	masked := make(map[string]interface{}, len(row))
+	for c, v := range row {
+		masked[c] = maskValue(table, c, v)
+	}
+	// marshaling can't fail, the values are strings selected as text or nil
+	data, _ := json.Marshal(masked)
+	return string(data)
+}
@@ -0,0 +1,63 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeterministicColumns(t *testing.T) {
+	table := &schema.Table{
+		Name: "test_instances",
+		Columns: []schema.Column{
+			{Name: "id", Type: schema.TypeString},
+			{Name: "name", Type: schema.TypeString},
+			{Name: "fetched_at", Type: schema.TypeTimestamp},
+			{Name: "token", Type: schema.TypeString, Encrypt: func(b []byte) ([]byte, error) { return b, nil }},
+			{Name: "instance_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver},
+		},
+	}
+	assert.Equal(t, []string{"id", "name"}, deterministicColumns(table, []string{"fetched_at"}))
+	assert.Equal(t, []string{"id", "fetched_at"}, deterministicColumns(table, []string{"test_instances.name", "other.id"}))
+}
+
+func TestDiffRows(t *testing.T) {
+	table := &schema.Table{
+		Name: "test_instances",
+		Columns: []schema.Column{
+			{Name: "id", Type: schema.TypeString},
+			{Name: "name", Type: schema.TypeString},
+			{Name: "secret", Type: schema.TypeString, Sensitive: true},
+		},
+		Options: schema.TableCreationOptions{PrimaryKeys: []string{"id"}},
+	}
+	columns := []string{"id", "name", "secret"}
+	first := []Row{
+		{"id": "a", "name": "web", "secret": "x"},
+		{"id": "b", "name": "db", "secret": "y"},
+		{"id": "c", "name": nil, "secret": "z"},
+	}
+	assert.Empty(t, diffRows(table, columns, first, []Row{first[2], first[0], first[1]}))
+
+	second := []Row{
+		{"id": "a", "name": "web", "secret": "x"},
+		{"id": "c", "name": "cache", "secret": "w"},
+		{"id": "d", "name": "queue", "secret": "v"},
+	}
+	assert.Equal(t, []string{
+		"table test_instances row id=b is missing from the second fetch",
+		"table test_instances row id=c column name differs: <nil> != cache",
+		"table test_instances row id=c column secret differs: " + schema.MaskedValue + " != " + schema.MaskedValue,
+		"table test_instances row id=d is missing from the first fetch",
+	}, diffRows(table, columns, first, second))
+
+	// without primary keys rows are compared whole
+	table.Options.PrimaryKeys = nil
+	assert.Equal(t, []string{
+		`table test_instances row {"id":"b","name":"db","secret":"` + schema.MaskedValue + `"} is missing from the second fetch`,
+		`table test_instances row {"id":"c","name":"cache","secret":"` + schema.MaskedValue + `"} is missing from the first fetch`,
+		`table test_instances row {"id":"c","name":null,"secret":"` + schema.MaskedValue + `"} is missing from the second fetch`,
+		`table test_instances row {"id":"d","name":"queue","secret":"` + schema.MaskedValue + `"} is missing from the first fetch`,
+	}, diffRows(table, columns, first, second))
+}
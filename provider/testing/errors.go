@@ -0,0 +1,87 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+)
+
+// ErrorFormat controls how TestResource renders the errors collected during the fetch
+type ErrorFormat int
+
+const (
+	// ErrorFormatPlain renders errors comma separated in a single line
+	ErrorFormatPlain ErrorFormat = iota
+	// ErrorFormatTable renders errors one per line, aligning their resource, severity, code and summary columns
+	ErrorFormatTable
+	// ErrorFormatJSON renders errors as an indented JSON array
+	ErrorFormatJSON
+)
+
+// fetchError is an error collected by testResourceSender during the fetch
+type fetchError struct {
+	Resource   string   `json:"resource"`
+	ResourceID []string `json:"resource_id,omitempty"`
+	Severity   string   `json:"severity"`
+	Summary    string   `json:"summary"`
+	Detail     string   `json:"detail,omitempty"`
+	Code       string   `json:"code,omitempty"`
+}
+
+func newFetchError(d diag.Diagnostic) fetchError {
+	desc := d.Description()
+	return fetchError{
+		Resource:   desc.Resource,
+		ResourceID: desc.ResourceID,
+		Severity:   d.Severity().String(),
+		Summary:    desc.Summary,
+		Detail:     desc.Detail,
+		Code:       desc.Code,
+	}
+}
+
+// resource returns the resource name including the key of the resource item the error is tied to, if any
+func (e fetchError) resource() string {
+	if len(e.ResourceID) == 0 {
+		return e.Resource
+	}
+	return fmt.Sprintf("%s (id: %s)", e.Resource, strings.Join(e.ResourceID, ","))
+}
+
+func (e fetchError) String() string {
+	if e.Code != "" {
+		return fmt.Sprintf("resource: %s. code: %s. summary: %s, details %s", e.resource(), e.Code, e.Summary, e.Detail)
+	}
+	return fmt.Sprintf("resource: %s. summary: %s, details %s", e.resource(), e.Summary, e.Detail)
+}
+
+// formatErrors renders errs in the given format
+func formatErrors(errs []fetchError, format ErrorFormat) string {
+	switch format {
+	case ErrorFormatTable:
+		var b bytes.Buffer
+		w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tSEVERITY\tCODE\tSUMMARY\tDETAILS")
+		for _, e := range errs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.resource(), e.Severity, e.Code, e.Summary, e.Detail)
+		}
+		_ = w.Flush()
+		return "\n" + b.String()
+	case ErrorFormatJSON:
+		b, err := json.MarshalIndent(errs, "", "  ")
+		if err != nil {
+			return err.Error()
+		}
+		return "\n" + string(b)
+	default:
+		s := make([]string, len(errs))
+		for i, e := range errs {
+			s[i] = e.String()
+		}
+		return strings.Join(s, ", ")
+	}
+}
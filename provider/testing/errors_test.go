@@ -0,0 +1,54 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFetchError(t *testing.T) {
+	d := diag.NewBaseError(nil, diag.ACCESS, diag.WithSeverity(diag.ERROR), diag.WithResourceName("instances"), diag.WithResourceId([]string{"us-east-1", "i-1"}),
+		diag.WithSummary("access denied"), diag.WithDetails("missing permission"), diag.WithCode("AccessDenied"))
+	assert.Equal(t, fetchError{
+		Resource:   "instances",
+		ResourceID: []string{"us-east-1", "i-1"},
+		Severity:   "Error",
+		Summary:    "access denied",
+		Detail:     "missing permission",
+		Code:       "AccessDenied",
+	}, newFetchError(d))
+}
+
+func TestFormatErrors(t *testing.T) {
+	errs := []fetchError{
+		{Resource: "instances", ResourceID: []string{"us-east-1", "i-1"}, Severity: "Error", Summary: "access denied", Detail: "missing permission", Code: "AccessDenied"},
+		{Resource: "volumes", Severity: "Error", Summary: "failed to resolve"},
+	}
+	assert.Equal(t, "resource: instances (id: us-east-1,i-1). code: AccessDenied. summary: access denied, details missing permission, "+
+		"resource: volumes. summary: failed to resolve, details ", formatErrors(errs, ErrorFormatPlain))
+	assert.Equal(t, `
+RESOURCE                       SEVERITY  CODE          SUMMARY            DETAILS
+instances (id: us-east-1,i-1)  Error     AccessDenied  access denied      missing permission
+volumes                        Error                   failed to resolve  
+`, formatErrors(errs, ErrorFormatTable))
+	assert.Equal(t, `
+[
+  {
+    "resource": "instances",
+    "resource_id": [
+      "us-east-1",
+      "i-1"
+    ],
+    "severity": "Error",
+    "summary": "access denied",
+    "detail": "missing permission",
+    "code": "AccessDenied"
+  },
+  {
+    "resource": "volumes",
+    "severity": "Error",
+    "summary": "failed to resolve"
+  }
+]`, formatErrors(errs, ErrorFormatJSON))
+}
@@ -0,0 +1,184 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/cloudquery/cq-provider-sdk/helpers"
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+)
+
+// fixtureFile is the content of a table's fixture, the resource items its resolver sent keyed by fixtureKey
+type fixtureFile map[string][]json.RawMessage
+
+// fixtureStore records the resource items sent by table resolvers or replays them, installed as a schema.ResolverMiddleware.
+// Items are stored per table in <dir>/<table>.json, keyed by the client and parent resource the resolver was called with.
+type fixtureStore struct {
+	dir string
+	// itemTypes maps table names to the type their replayed items are decoded into
+	itemTypes map[string]reflect.Type
+
+	lock     sync.Mutex
+	fixtures map[string]fixtureFile
+	errs     []string
+}
+
+func newFixtureStore(dir string, items map[string]interface{}) *fixtureStore {
+	itemTypes := make(map[string]reflect.Type, len(items))
+	for table, item := range items {
+		itemTypes[table] = reflect.TypeOf(item)
+	}
+	return &fixtureStore{dir: dir, itemTypes: itemTypes, fixtures: make(map[string]fixtureFile)}
+}
+
+// fixtureKey identifies a resolver call by its client, and for relations by its parent's primary key values
+func fixtureKey(meta schema.ClientMeta, parent *schema.Resource) string {
+	var key string
+	if ider, ok := meta.(schema.ClientIdentifier); ok {
+		key = ider.Identify()
+	}
+	if parent != nil {
+		key += "/" + strings.Join(parent.PrimaryKeyValues(), ",")
+	}
+	return key
+}
+
+// Record returns a schema.ResolverMiddleware recording every resource item the wrapped resolver sends, call Save once the fetch is done
+func (s *fixtureStore) Record() schema.ResolverMiddleware {
+	return func(t *schema.Table, next schema.TableResolver) schema.TableResolver {
+		return func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+			key := fixtureKey(meta, parent)
+			// tables whose resolver sends no items still get a fixture, so replaying them isn't mistaken for a missing fixture
+			s.lock.Lock()
+			if s.fixtures[t.Name] == nil {
+				s.fixtures[t.Name] = make(fixtureFile)
+			}
+			s.lock.Unlock()
+			items := make(chan interface{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for elem := range items {
+					s.record(t.Name, key, elem)
+					res <- elem
+				}
+			}()
+			err := next(ctx, meta, parent, items)
+			close(items)
+			<-done
+			return err
+		}
+	}
+}
+
+func (s *fixtureStore) record(table, key string, elem interface{}) {
+	switch elem.(type) {
+	case diag.Diagnostic, diag.Diagnostics:
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, item := range helpers.InterfaceSlice(elem) {
+		data, err := json.Marshal(item)
+		if err != nil {
+			s.errs = append(s.errs, fmt.Sprintf("failed to record item of table %s: %s", table, err))
+			continue
+		}
+		s.fixtures[table][key] = append(s.fixtures[table][key], data)
+	}
+}
+
+// Save writes the recorded fixtures into the store's directory, one file per table
+func (s *fixtureStore) Save() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.errs) > 0 {
+		return fmt.Errorf("failed to record fixtures: %s", strings.Join(s.errs, "; "))
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	for table, fixture := range s.fixtures {
+		data, err := json.MarshalIndent(fixture, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(s.dir, table+".json"), append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replay returns a schema.ResolverMiddleware sending the recorded resource items instead of calling the wrapped resolver
+func (s *fixtureStore) Replay() schema.ResolverMiddleware {
+	return func(t *schema.Table, _ schema.TableResolver) schema.TableResolver {
+		return func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+			fixture, err := s.load(t.Name)
+			if err != nil {
+				return err
+			}
+			for _, data := range fixture[fixtureKey(meta, parent)] {
+				item, err := s.decode(t.Name, data)
+				if err != nil {
+					return fmt.Errorf("failed to replay item of table %s: %w", t.Name, err)
+				}
+				select {
+				case res <- item:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// load reads the table's fixture file once, failing if the table has none, e.g. as it was added since the fixtures
+// were recorded
+func (s *fixtureStore) load(table string) (fixtureFile, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if fixture, ok := s.fixtures[table]; ok {
+		return fixture, nil
+	}
+	path := filepath.Join(s.dir, table+".json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no fixture of table %s at %s, record it with RecordFixturesDir", table, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fixture := make(fixtureFile)
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to read fixture of table %s: %w", table, err)
+	}
+	s.fixtures[table] = fixture
+	return fixture, nil
+}
+
+// decode decodes a replayed item into the table's item type, or into a map if it has none
+func (s *fixtureStore) decode(table string, data json.RawMessage) (interface{}, error) {
+	typ, ok := s.itemTypes[table]
+	if !ok {
+		var item map[string]interface{}
+		err := json.Unmarshal(data, &item)
+		return item, err
+	}
+	if typ.Kind() == reflect.Ptr {
+		item := reflect.New(typ.Elem())
+		err := json.Unmarshal(data, item.Interface())
+		return item.Interface(), err
+	}
+	item := reflect.New(typ)
+	err := json.Unmarshal(data, item.Interface())
+	return item.Elem().Interface(), err
+}
@@ -0,0 +1,120 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixtureClient struct {
+	account string
+}
+
+func (fixtureClient) Logger() hclog.Logger { return hclog.NewNullLogger() }
+
+func (c fixtureClient) Identify() string { return c.account }
+
+type fixtureItem struct {
+	ID   string
+	Size int
+}
+
+var (
+	fixtureTable = &schema.Table{
+		Name:    "test_fixture_instances",
+		Options: schema.TableCreationOptions{PrimaryKeys: []string{"id"}},
+		Columns: []schema.Column{{Name: "id", Type: schema.TypeString}},
+	}
+	fixtureRelation = &schema.Table{Name: "test_fixture_instance_disks", Columns: []schema.Column{{Name: "id", Type: schema.TypeString}}}
+	fixtureEmpty    = &schema.Table{Name: "test_fixture_empty"}
+)
+
+// resolveItems calls resolver, returning the items it sent
+func resolveItems(t *testing.T, resolver schema.TableResolver, meta schema.ClientMeta, parent *schema.Resource) ([]interface{}, error) {
+	t.Helper()
+	res := make(chan interface{})
+	var items []interface{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for item := range res {
+			items = append(items, item)
+		}
+	}()
+	err := resolver(context.Background(), meta, parent, res)
+	close(res)
+	<-done
+	return items, err
+}
+
+func TestFixtureStore_RecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	parent := schema.NewResourceData(schema.PostgresDialect{}, fixtureTable, nil, nil, nil, time.Now())
+	require.NoError(t, parent.Set("id", "i-1"))
+
+	recorder := newFixtureStore(dir, nil)
+	record := recorder.Record()
+	items, err := resolveItems(t, record(fixtureTable, func(_ context.Context, meta schema.ClientMeta, _ *schema.Resource, res chan<- interface{}) error {
+		res <- []fixtureItem{{ID: "i-1", Size: 1}, {ID: meta.(fixtureClient).account, Size: 2}}
+		return nil
+	}), fixtureClient{account: "dev"}, nil)
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+	_, err = resolveItems(t, record(fixtureRelation, func(_ context.Context, _ schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+		res <- &fixtureItem{ID: "disk-of-" + parent.Get("id").(string)}
+		return nil
+	}), fixtureClient{account: "dev"}, parent)
+	require.NoError(t, err)
+	// a resolver failing after sending items records them and returns its error
+	_, err = resolveItems(t, record(fixtureEmpty, func(context.Context, schema.ClientMeta, *schema.Resource, chan<- interface{}) error {
+		return errors.New("access denied")
+	}), fixtureClient{account: "prod"}, nil)
+	assert.EqualError(t, err, "access denied")
+	require.NoError(t, recorder.Save())
+	for _, table := range []string{fixtureTable.Name, fixtureRelation.Name, fixtureEmpty.Name} {
+		assert.FileExists(t, filepath.Join(dir, table+".json"))
+	}
+
+	replayer := newFixtureStore(dir, map[string]interface{}{fixtureTable.Name: fixtureItem{}, fixtureRelation.Name: &fixtureItem{}})
+	replay := replayer.Replay()
+	unexpected := func(context.Context, schema.ClientMeta, *schema.Resource, chan<- interface{}) error {
+		t.Error("resolver called while replaying fixtures")
+		return nil
+	}
+	items, err = resolveItems(t, replay(fixtureTable, unexpected), fixtureClient{account: "dev"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{fixtureItem{ID: "i-1", Size: 1}, fixtureItem{ID: "dev", Size: 2}}, items)
+	// items are replayed to the client and parent they were recorded for
+	items, err = resolveItems(t, replay(fixtureTable, unexpected), fixtureClient{account: "prod"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+	items, err = resolveItems(t, replay(fixtureRelation, unexpected), fixtureClient{account: "dev"}, parent)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{&fixtureItem{ID: "disk-of-i-1"}}, items)
+	// tables without an item type are replayed as maps
+	items, err = resolveItems(t, newFixtureStore(dir, nil).Replay()(fixtureTable, unexpected), fixtureClient{account: "dev"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"ID": "i-1", "Size": 1.0}, items[0])
+	items, err = resolveItems(t, replay(fixtureEmpty, unexpected), fixtureClient{account: "prod"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestFixtureStore_MissingFixture(t *testing.T) {
+	dir := t.TempDir()
+	replay := newFixtureStore(dir, nil).Replay()
+	_, err := resolveItems(t, replay(fixtureTable, nil), fixtureClient{}, nil)
+	assert.EqualError(t, err, "no fixture of table test_fixture_instances at "+filepath.Join(dir, "test_fixture_instances.json")+", record it with RecordFixturesDir")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fixtureTable.Name+".json"), []byte("{"), 0644))
+	_, err = resolveItems(t, newFixtureStore(dir, nil).Replay()(fixtureTable, nil), fixtureClient{}, nil)
+	assert.Error(t, err)
+}
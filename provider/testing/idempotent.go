@@ -0,0 +1,124 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// cqIDSnapshot maps table names to the sorted cq_ids of their rows at some point of the test
+type cqIDSnapshot map[string][]string
+
+// snapshotCQIDs adds the cq_ids of every row of table and its relations to snapshot
+func snapshotCQIDs(conn pgxscan.Querier, table *schema.Table, snapshot cqIDSnapshot) error {
+	var ids []string
+	if err := pgxscan.Select(context.Background(), conn, &ids, fmt.Sprintf("SELECT cq_id::text FROM %s ORDER BY cq_id", strconv.Quote(table.Name))); err != nil {
+		return err
+	}
+	snapshot[table.Name] = ids
+	for _, rel := range table.Relations {
+		if err := snapshotCQIDs(conn, rel, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffCQIDSnapshots returns the differences between the snapshots taken after two fetches: row counts changed, cq_ids
+// duplicated by the second fetch, and cq_ids dropped or added by it
+func diffCQIDSnapshots(first, second cqIDSnapshot) []string {
+	tables := make([]string, 0, len(first))
+	for name := range first {
+		tables = append(tables, name)
+	}
+	for name := range second {
+		if _, ok := first[name]; !ok {
+			tables = append(tables, name)
+		}
+	}
+	sort.Strings(tables)
+
+	var diffs []string
+	for _, name := range tables {
+		before, after := countIDs(first[name]), countIDs(second[name])
+		if len(first[name]) != len(second[name]) {
+			diffs = append(diffs, fmt.Sprintf("table %s has %d rows after the first fetch and %d after the second", name, len(first[name]), len(second[name])))
+		}
+		var duplicated, dropped, added []string
+		for _, id := range sortedKeys(after) {
+			if after[id] > 1 {
+				duplicated = append(duplicated, id)
+			}
+			if before[id] == 0 {
+				added = append(added, id)
+			}
+		}
+		for _, id := range sortedKeys(before) {
+			if after[id] == 0 {
+				dropped = append(dropped, id)
+			}
+		}
+		if len(duplicated) > 0 {
+			diffs = append(diffs, fmt.Sprintf("table %s has duplicate cq_ids after the second fetch: %s", name, strings.Join(duplicated, ",")))
+		}
+		if len(dropped) > 0 {
+			diffs = append(diffs, fmt.Sprintf("table %s cq_ids dropped by the second fetch: %s", name, strings.Join(dropped, ",")))
+		}
+		if len(added) > 0 {
+			diffs = append(diffs, fmt.Sprintf("table %s cq_ids added by the second fetch: %s", name, strings.Join(added, ",")))
+		}
+	}
+	return diffs
+}
+
+func countIDs(ids []string) map[string]int {
+	counts := make(map[string]int, len(ids))
+	for _, id := range ids {
+		counts[id]++
+	}
+	return counts
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// verifyIdempotent fetches the resources of the test case again, failing on any error of the second fetch and on any
+// difference of the cq_ids of tables between the fetches. It returns the sender of the second fetch.
+func verifyIdempotent(ctx context.Context, t *testing.T, resource *ResourceTestCase, conn pgxscan.Querier, dbURL string, tables []*schema.Table) *testResourceSender {
+	t.Helper()
+	first := make(cqIDSnapshot)
+	for _, table := range tables {
+		if err := snapshotCQIDs(conn, table, first); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Log("fetching again to verify the fetch is idempotent")
+	sender, err := fetch(ctx, t, resource, dbURL)
+	if err != nil {
+		checkCanceled(ctx, t, "fetching resources again")
+		t.Fatalf("second fetch failed: %s", err)
+	}
+	verifyFetch(t, resource, sender)
+	second := make(cqIDSnapshot)
+	for _, table := range tables {
+		if err := snapshotCQIDs(conn, table, second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, diff := range diffCQIDSnapshots(first, second) {
+		t.Errorf("fetch isn't idempotent: %s", diff)
+	}
+	return sender
+}
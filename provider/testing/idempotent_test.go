@@ -0,0 +1,21 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffCQIDSnapshots(t *testing.T) {
+	first := cqIDSnapshot{"a": {"1", "2"}, "b": {"3"}, "c": nil}
+	assert.Empty(t, diffCQIDSnapshots(first, cqIDSnapshot{"a": {"1", "2"}, "b": {"3"}, "c": nil}))
+
+	assert.Equal(t, []string{
+		"table a has 2 rows after the first fetch and 3 after the second",
+		"table a has duplicate cq_ids after the second fetch: 2",
+		"table b cq_ids dropped by the second fetch: 3",
+		"table b cq_ids added by the second fetch: 4",
+		"table c has 0 rows after the first fetch and 1 after the second",
+		"table c cq_ids added by the second fetch: 5",
+	}, diffCQIDSnapshots(first, cqIDSnapshot{"a": {"1", "2", "2"}, "b": {"4"}, "c": {"5"}}))
+}
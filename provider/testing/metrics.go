@@ -0,0 +1,113 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+)
+
+// MetricsOutEnv if set to a path, TestResource writes the metrics of its fetch summary to a file of its own named after
+// it, with the test's name inserted before the extension, see WriteMetrics. For example with /tmp/metrics.prom the test
+// TestProvider/ec2 writes /tmp/metrics.TestProvider_ec2.prom, so tests running in parallel don't overwrite each other.
+const MetricsOutEnv = "CQ_METRICS_OUT"
+
+// metricsPrefix prefixes the names of all metrics written by WriteMetrics
+const metricsPrefix = "cq_test_"
+
+// metricSeverities are the severities diagnostics are counted by, each written even if there were none of it
+var metricSeverities = []diag.Severity{diag.IGNORE, diag.WARNING, diag.ERROR, diag.PANIC}
+
+// WriteMetrics writes summary as gauges in the Prometheus text exposition format, for the results of a test to be
+// scraped. The metrics and their labels are stable:
+//   - cq_test_fetch_duration_seconds: the time the fetch took
+//   - cq_test_fetch_resources: the amount of resources fetched
+//   - cq_test_resource_rows{resource}: the amount of resources fetched by each resource
+//   - cq_test_resource_duration_seconds{resource}: the time from the start of the fetch until each resource finished
+//   - cq_test_diagnostics{severity}: the amount of diagnostics of each severity, ignore, warning, error or panic
+//   - cq_test_cache_hits and cq_test_cache_misses: the lookups of the resolver cache
+func WriteMetrics(w io.Writer, summary FetchSummary) error {
+	var b bytes.Buffer
+	writeMetric(&b, "fetch_duration_seconds", "Time the fetch took.", "", map[string]float64{"": summary.Duration.Seconds()})
+	writeMetric(&b, "fetch_resources", "Resources fetched.", "", map[string]float64{"": float64(summary.ResourceCount)})
+
+	rows := make(map[string]float64, len(summary.Resources))
+	for name, count := range summary.Resources {
+		rows[name] = float64(count)
+	}
+	writeMetric(&b, "resource_rows", "Resources fetched by each resource.", "resource", rows)
+	durations := make(map[string]float64, len(summary.Durations))
+	for name, d := range summary.Durations {
+		durations[name] = d.Seconds()
+	}
+	writeMetric(&b, "resource_duration_seconds", "Time from the start of the fetch until each resource finished.", "resource", durations)
+
+	diagnostics := make(map[string]float64, len(metricSeverities))
+	for _, s := range metricSeverities {
+		diagnostics[strings.ToLower(s.String())] = 0
+	}
+	for _, d := range summary.Diagnostics {
+		diagnostics[strings.ToLower(d.Severity().String())]++
+	}
+	writeMetric(&b, "diagnostics", "Diagnostics reported by the fetch, by severity.", "severity", diagnostics)
+
+	writeMetric(&b, "cache_hits", "Lookups of the resolver cache that found their key.", "", map[string]float64{"": float64(summary.Cache.Hits)})
+	writeMetric(&b, "cache_misses", "Lookups of the resolver cache that didn't find their key.", "", map[string]float64{"": float64(summary.Cache.Misses)})
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeMetric writes the gauge name with its samples, keyed by the value of label, or a single sample keyed by an
+// empty string if label is empty. Samples are sorted by label value so the output is stable.
+func writeMetric(b *bytes.Buffer, name, help, label string, samples map[string]float64) {
+	name = metricsPrefix + name
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	values := make([]string, 0, len(samples))
+	for v := range samples {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		value := strconv.FormatFloat(samples[v], 'g', -1, 64)
+		if label == "" {
+			fmt.Fprintf(b, "%s %s\n", name, value)
+			continue
+		}
+		fmt.Fprintf(b, "%s{%s=\"%s\"} %s\n", name, label, escapeLabelValue(v), value)
+	}
+}
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds of a label value
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// unsafeFileNameChars are the characters of test names replaced in the metrics file names
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// metricsFilePath returns the metrics file of the test named test, path with the test's name inserted before its
+// extension
+func metricsFilePath(path, test string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + unsafeFileNameChars.ReplaceAllString(test, "_") + ext
+}
+
+// writeMetricsFile writes the metrics of summary to path, see WriteMetrics
+func writeMetricsFile(path string, summary FetchSummary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteMetrics(f, summary); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,61 @@
+package testing
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	summary := FetchSummary{
+		ResourceCount: 7,
+		Resources:     map[string]uint64{"ec2.instances": 5, `odd"name`: 2},
+		Durations:     map[string]time.Duration{"ec2.instances": 1500 * time.Millisecond, `odd"name`: 250 * time.Millisecond},
+		Duration:      2 * time.Second,
+		Diagnostics: diag.Diagnostics{
+			diag.NewBaseError(nil, diag.RESOLVING, diag.WithSeverity(diag.WARNING)),
+			diag.NewBaseError(nil, diag.ACCESS, diag.WithSeverity(diag.WARNING)),
+			diag.NewBaseError(nil, diag.RESOLVING, diag.WithSeverity(diag.ERROR)),
+		},
+		Cache: execution.CacheStats{Hits: 3, Misses: 1},
+	}
+	var b bytes.Buffer
+	require.NoError(t, WriteMetrics(&b, summary))
+	assert.Equal(t, `# HELP cq_test_fetch_duration_seconds Time the fetch took.
+# TYPE cq_test_fetch_duration_seconds gauge
+cq_test_fetch_duration_seconds 2
+# HELP cq_test_fetch_resources Resources fetched.
+# TYPE cq_test_fetch_resources gauge
+cq_test_fetch_resources 7
+# HELP cq_test_resource_rows Resources fetched by each resource.
+# TYPE cq_test_resource_rows gauge
+cq_test_resource_rows{resource="ec2.instances"} 5
+cq_test_resource_rows{resource="odd\"name"} 2
+# HELP cq_test_resource_duration_seconds Time from the start of the fetch until each resource finished.
+# TYPE cq_test_resource_duration_seconds gauge
+cq_test_resource_duration_seconds{resource="ec2.instances"} 1.5
+cq_test_resource_duration_seconds{resource="odd\"name"} 0.25
+# HELP cq_test_diagnostics Diagnostics reported by the fetch, by severity.
+# TYPE cq_test_diagnostics gauge
+cq_test_diagnostics{severity="error"} 1
+cq_test_diagnostics{severity="ignore"} 0
+cq_test_diagnostics{severity="panic"} 0
+cq_test_diagnostics{severity="warning"} 2
+# HELP cq_test_cache_hits Lookups of the resolver cache that found their key.
+# TYPE cq_test_cache_hits gauge
+cq_test_cache_hits 3
+# HELP cq_test_cache_misses Lookups of the resolver cache that didn't find their key.
+# TYPE cq_test_cache_misses gauge
+cq_test_cache_misses 1
+`, b.String())
+}
+
+func TestMetricsFilePath(t *testing.T) {
+	assert.Equal(t, "/tmp/metrics.TestProvider_ec2.prom", metricsFilePath("/tmp/metrics.prom", "TestProvider/ec2"))
+	assert.Equal(t, "/tmp/metrics.TestProvider_odd_name_", metricsFilePath("/tmp/metrics", "TestProvider/odd name?"))
+}
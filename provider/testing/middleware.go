@@ -0,0 +1,38 @@
+package testing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+)
+
+// ResolverCallCounter counts table resolver invocations per table, install it via ResourceTestCase.ResolverMiddleware
+type ResolverCallCounter struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+// NewResolverCallCounter creates a new ResolverCallCounter
+func NewResolverCallCounter() *ResolverCallCounter {
+	return &ResolverCallCounter{counts: make(map[string]int)}
+}
+
+// Middleware returns a schema.ResolverMiddleware counting every call of the wrapped resolver
+func (c *ResolverCallCounter) Middleware() schema.ResolverMiddleware {
+	return func(t *schema.Table, next schema.TableResolver) schema.TableResolver {
+		return func(ctx context.Context, meta schema.ClientMeta, parent *schema.Resource, res chan<- interface{}) error {
+			c.lock.Lock()
+			c.counts[t.Name]++
+			c.lock.Unlock()
+			return next(ctx, meta, parent, res)
+		}
+	}
+}
+
+// Count returns the number of times the resolver of the given table was called
+func (c *ResolverCallCounter) Count(table string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.counts[table]
+}
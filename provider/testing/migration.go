@@ -0,0 +1,159 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cloudquery/cq-provider-sdk/migration"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/modern-go/reflect2"
+	"github.com/thoas/go-funk"
+)
+
+// TestMigration tests upgrading the tables of old to those of new end to end: it creates the tables of old, inserts
+// the seed rows into the top level table, applies the statements of migration.Diff and verifies every table of new has
+// all its columns, and that the seed rows kept the values of the columns both old and new declare with the same type.
+// Seed rows map column names to values, the columns they omit are NULL. It runs against the database of DATABASE_URL.
+func TestMigration(t *testing.T, dialect schema.Dialect, old, new *schema.Table, seed []map[string]interface{}) {
+	t.Helper()
+	ctx := context.Background()
+	dbURL, err := ResourceTestCase{}.databaseURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := setupDatabase(dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []*schema.Table{old, new} {
+		if err := dropTables(ctx, conn, "", table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ups, err := migration.CreateTableDefinitions(ctx, dialect, old, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range ups {
+		if err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("failed to create the old tables: %s", err)
+		}
+	}
+
+	resources := make(schema.Resources, len(seed))
+	for i, row := range seed {
+		r, err := seedResource(ctx, dialect, old, row)
+		if err != nil {
+			t.Fatalf("seed row %d: %s", i, err)
+		}
+		resources[i] = r
+	}
+	if err := insertResources(ctx, conn, dialect, old, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	ups, err = migration.Diff(dialect, old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range ups {
+		t.Logf("migrating: %s", sql)
+		if err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("failed to migrate: %s", err)
+		}
+	}
+
+	verifyTableColumns(t, conn, dialect, new)
+	verifySeedSurvived(t, conn, dialect, old, new, resources)
+}
+
+// seedResource returns a resource of table with the values of row, resolving its internal columns
+func seedResource(ctx context.Context, dialect schema.Dialect, table *schema.Table, row map[string]interface{}) (*schema.Resource, error) {
+	r := schema.NewResourceData(dialect, table, nil, nil, nil, time.Now())
+	for name, v := range row {
+		if err := r.Set(name, v); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range dialect.Columns(table) {
+		if !c.Internal() {
+			continue
+		}
+		if err := c.Resolver(ctx, seedClient{}, r, c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// verifyTableColumns verifies the database tables of table and its relations have all their columns
+func verifyTableColumns(t *testing.T, conn execution.QueryExecer, dialect schema.Dialect, table *schema.Table) {
+	t.Helper()
+	var existing []string
+	if err := pgxscan.Select(context.Background(), conn, &existing,
+		"SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1", table.Name); err != nil {
+		t.Fatal(err)
+	}
+	if len(existing) == 0 {
+		t.Errorf("table %s doesn't exist after the migration", table.Name)
+	}
+	for _, c := range dialect.Columns(table) {
+		if !funk.ContainsString(existing, c.Name) {
+			t.Errorf("column %s of table %s doesn't exist after the migration", c.Name, table.Name)
+		}
+	}
+	for _, rel := range table.Relations {
+		verifyTableColumns(t, conn, dialect, rel)
+	}
+}
+
+// verifySeedSurvived verifies the seed resources of old still exist with the values of their columns kept by new
+func verifySeedSurvived(t *testing.T, conn execution.QueryExecer, dialect schema.Dialect, old, new *schema.Table, resources schema.Resources) {
+	t.Helper()
+	var count int
+	if err := pgxscan.Get(context.Background(), conn, &count, fmt.Sprintf("SELECT count(*) FROM %s", strconv.Quote(new.Name))); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(resources) {
+		t.Errorf("table %s has %d rows after the migration, expected the %d seed rows", new.Name, count, len(resources))
+	}
+	for i, r := range resources {
+		values, err := dialect.GetResourceValues(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// sq.Eq would expand array values into IN lists, so the conditions are built one by one
+		kept := sq.And{}
+		for j, c := range dialect.Columns(old) {
+			if nc := new.Column(c.Name); !c.Internal() && (nc == nil || nc.Type != c.Type) {
+				continue
+			}
+			if reflect2.IsNil(values[j]) {
+				kept = append(kept, sq.Expr(strconv.Quote(c.Name)+" IS NULL"))
+			} else {
+				kept = append(kept, sq.Expr(strconv.Quote(c.Name)+" = ?", values[j]))
+			}
+		}
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("count(*)").
+			From(strconv.Quote(new.Name)).
+			Where(kept).
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var matching int
+		if err := pgxscan.Get(context.Background(), conn, &matching, query, args...); err != nil {
+			t.Fatal(err)
+		}
+		if matching != 1 {
+			t.Errorf("seed row %d of table %s lost its values in the migration", i, new.Name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package testing
+
+import (
+	"context"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/jackc/pgx/v4"
+)
+
+// SQLObserverFunc is called with every statement and its arguments. Statements of the test harness are reported before
+// they're sent to the database, the ones of the execution engine once they completed.
+type SQLObserverFunc func(sql string, args []interface{})
+
+// observedQueryExecer wraps an execution.QueryExecer reporting each Exec and Query call to onSQL
+type observedQueryExecer struct {
+	execution.QueryExecer
+	onSQL SQLObserverFunc
+}
+
+// observeQueryExecer returns conn wrapped with onSQL, if onSQL is nil conn is returned as is
+func observeQueryExecer(conn execution.QueryExecer, onSQL SQLObserverFunc) execution.QueryExecer {
+	if onSQL == nil {
+		return conn
+	}
+	return observedQueryExecer{QueryExecer: conn, onSQL: onSQL}
+}
+
+func (o observedQueryExecer) Exec(ctx context.Context, query string, args ...interface{}) error {
+	o.onSQL(query, args)
+	return o.QueryExecer.Exec(ctx, query, args...)
+}
+
+func (o observedQueryExecer) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	o.onSQL(query, args)
+	return o.QueryExecer.Query(ctx, query, args...)
+}
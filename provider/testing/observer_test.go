@@ -0,0 +1,36 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sqlRecorder is an SQLObserverFunc recording the statements and arguments it observes
+type sqlRecorder struct {
+	statements []string
+	args       [][]interface{}
+}
+
+func (r *sqlRecorder) observe(sql string, args []interface{}) {
+	r.statements = append(r.statements, sql)
+	r.args = append(r.args, args)
+}
+
+func TestObserveQueryExecer(t *testing.T) {
+	ctx := context.Background()
+	conn := &recordingQueryExecer{}
+	assert.Same(t, conn, observeQueryExecer(conn, nil))
+
+	observed := &sqlRecorder{}
+	observer := observeQueryExecer(conn, observed.observe)
+	require.NoError(t, observer.Exec(ctx, "DELETE FROM test_table WHERE id = $1", "a"))
+	_, err := observer.Query(ctx, "SELECT * FROM test_table")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DELETE FROM test_table WHERE id = $1", "SELECT * FROM test_table"}, observed.statements)
+	assert.Equal(t, [][]interface{}{{"a"}, nil}, observed.args)
+	// statements are still executed
+	assert.Equal(t, []string{"DELETE FROM test_table WHERE id = $1"}, conn.statements)
+}
@@ -0,0 +1,528 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+)
+
+// parquetMagic starts and ends every parquet file
+const parquetMagic = "PAR1"
+
+// parquet physical types
+const (
+	parquetBoolean           int32 = 0
+	parquetInt32             int32 = 1
+	parquetInt64             int32 = 2
+	parquetDouble            int32 = 5
+	parquetByteArray         int32 = 6
+	parquetFixedLenByteArray int32 = 7
+)
+
+// parquet converted types, set besides logical types for readers predating them
+const (
+	parquetNoConvertedType int32 = -1
+	parquetUTF8            int32 = 0
+	parquetEnum            int32 = 4
+	parquetInt16           int32 = 16
+	parquetInt32Converted  int32 = 17
+	parquetInt64Converted  int32 = 18
+	parquetJSON            int32 = 19
+)
+
+const (
+	parquetOptional       int32 = 1
+	parquetPlainEncoding  int32 = 0
+	parquetRLEEncoding    int32 = 3
+	parquetDataPage       int32 = 0
+	parquetUncompressed   int32 = 0
+	parquetFormatVersion  int32 = 1
+	parquetCreatedBy            = "cq-provider-sdk"
+	parquetUUIDTypeLength int32 = 16
+)
+
+// thrift compact protocol types
+const (
+	thriftTrue   byte = 1
+	thriftFalse  byte = 2
+	thriftByte   byte = 3
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// parquetColumn is a table column exported to parquet, as an optional column holding NULL values
+type parquetColumn struct {
+	name string
+	// expr selects the column from the table, cast to the type convert expects
+	expr       string
+	physical   int32
+	typeLength int32
+	converted  int32
+	// logical writes the fields of the column's LogicalType union, nil if the column has none
+	logical func(w *thriftWriter)
+	// convert converts a selected value to the Go type of the physical type: bool, int32, int64, float64 or []byte
+	convert func(v interface{}) (interface{}, error)
+}
+
+// ExportTableParquet writes the rows of table, ordered by its primary key, to a parquet file at path. Every column is an
+// optional parquet column, with NULL values, mapped from the column's schema.ValueType:
+//
+//	TypeBool: BOOLEAN
+//	TypeSmallInt, TypeInt, TypeBigInt: INT32 or INT64 with the Integer logical type of their size
+//	TypeFloat: DOUBLE
+//	TypeUUID: FIXED_LEN_BYTE_ARRAY(16) with the UUID logical type
+//	TypeString, TypeInet, TypeCIDR, TypeMacAddr: BYTE_ARRAY with the String logical type, or Enum for TypeEnum
+//	TypeTimestamp: INT64 with the Timestamp(MICROS) logical type, not adjusted to UTC
+//	TypeByteArray: BYTE_ARRAY
+//	TypeJSON and array types: BYTE_ARRAY with the JSON logical type, arrays are encoded as JSON arrays
+//
+// The file is written uncompressed in a single row group, sufficient for verifying what downstream tooling reads.
+func ExportTableParquet(conn pgxscan.Querier, table *schema.Table, path string) error {
+	var columns []parquetColumn
+	for _, c := range (schema.PostgresDialect{}).Columns(table) {
+		columns = append(columns, newParquetColumn(c))
+	}
+	exprs := make([]string, len(columns))
+	for i, c := range columns {
+		exprs[i] = c.expr
+	}
+	pks := quoteIdentifiers((schema.PostgresDialect{}).PrimaryKeys(table))
+	rows, err := conn.Query(context.Background(), fmt.Sprintf("SELECT %s FROM %s ORDER BY %s",
+		strings.Join(exprs, ", "), strconv.Quote(table.Name), strings.Join(pks, ", ")))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var values [][]interface{}
+	for rows.Next() {
+		row, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		for i, c := range columns {
+			if row[i] == nil {
+				continue
+			}
+			if row[i], err = c.convert(row[i]); err != nil {
+				return fmt.Errorf("table %s column %s: %w", table.Name, c.name, err)
+			}
+		}
+		values = append(values, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeParquet(f, columns, values); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func newParquetColumn(c schema.Column) parquetColumn {
+	name := strconv.Quote(c.Name)
+	pc := parquetColumn{name: c.Name, expr: name + "::text", physical: parquetByteArray, converted: parquetNoConvertedType, convert: convertText}
+	switch c.Type {
+	case schema.TypeBool:
+		pc.expr, pc.physical, pc.convert = name, parquetBoolean, convertIdentity
+	case schema.TypeSmallInt:
+		pc.expr, pc.physical, pc.converted, pc.logical, pc.convert = name+"::int4", parquetInt32, parquetInt16, integerLogicalType(16), convertIdentity
+	case schema.TypeInt:
+		pc.expr, pc.physical, pc.converted, pc.logical, pc.convert = name+"::int4", parquetInt32, parquetInt32Converted, integerLogicalType(32), convertIdentity
+	case schema.TypeBigInt:
+		pc.expr, pc.physical, pc.converted, pc.logical, pc.convert = name+"::int8", parquetInt64, parquetInt64Converted, integerLogicalType(64), convertIdentity
+	case schema.TypeFloat:
+		pc.expr, pc.physical, pc.convert = name+"::float8", parquetDouble, convertIdentity
+	case schema.TypeUUID:
+		pc.physical, pc.typeLength, pc.logical, pc.convert = parquetFixedLenByteArray, parquetUUIDTypeLength, emptyLogicalType(14), convertUUID
+	case schema.TypeString, schema.TypeInet, schema.TypeCIDR, schema.TypeMacAddr, schema.TypeNumeric:
+		// numeric values are exported as their exact text
+		pc.converted, pc.logical = parquetUTF8, emptyLogicalType(1)
+	case schema.TypeEnum:
+		pc.converted, pc.logical = parquetEnum, emptyLogicalType(4)
+	case schema.TypeTimestamp:
+		pc.expr, pc.physical, pc.logical, pc.convert = name, parquetInt64, timestampMicrosLogicalType, convertTimestamp
+	case schema.TypeByteArray:
+		pc.expr, pc.convert = name, convertIdentity
+	case schema.TypeStringArray, schema.TypeIntArray, schema.TypeUUIDArray, schema.TypeInetArray, schema.TypeCIDRArray, schema.TypeMacAddrArray:
+		pc.expr, pc.converted, pc.logical = fmt.Sprintf("to_jsonb(%s)::text", name), parquetJSON, emptyLogicalType(12)
+	default:
+		// TypeJSON and anything else is exported as JSON
+		pc.converted, pc.logical = parquetJSON, emptyLogicalType(12)
+	}
+	return pc
+}
+
+func convertIdentity(v interface{}) (interface{}, error) {
+	return v, nil
+}
+
+func convertText(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected text got %T", v)
+	}
+	return []byte(s), nil
+}
+
+func convertUUID(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected uuid text got %T", v)
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return id[:], nil
+}
+
+func convertTimestamp(v interface{}) (interface{}, error) {
+	ts, ok := v.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("expected timestamp got %T", v)
+	}
+	return ts.UnixMicro(), nil
+}
+
+// emptyLogicalType is a LogicalType whose type, the union field id, has no parameters, e.g. STRING or JSON
+func emptyLogicalType(id int16) func(w *thriftWriter) {
+	return func(w *thriftWriter) {
+		w.structBegin(id)
+		w.structEnd()
+	}
+}
+
+func integerLogicalType(bitWidth int8) func(w *thriftWriter) {
+	return func(w *thriftWriter) {
+		w.structBegin(10)
+		w.byteField(1, bitWidth)
+		w.boolField(2, true)
+		w.structEnd()
+	}
+}
+
+// timestampMicrosLogicalType matches postgres timestamp without time zone, which isn't adjusted to UTC
+func timestampMicrosLogicalType(w *thriftWriter) {
+	w.structBegin(8)
+	w.boolField(1, false)
+	// the TimeUnit union, set to MICROS
+	w.structBegin(2)
+	w.structBegin(2)
+	w.structEnd()
+	w.structEnd()
+	w.structEnd()
+}
+
+// writeParquet writes rows, holding the converted values of columns or nil, as a parquet file with a single row group
+// of one PLAIN encoded data page per column
+func writeParquet(out io.Writer, columns []parquetColumn, rows [][]interface{}) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	chunks := new(thriftWriter)
+	var totalSize int64
+	for i, c := range columns {
+		page, err := encodeParquetPage(c, i, rows)
+		if err != nil {
+			return err
+		}
+		offset := int64(file.Len())
+		file.Write(page)
+		totalSize += int64(len(page))
+
+		// ColumnChunk
+		chunks.begin()
+		chunks.i64Field(2, offset)
+		chunks.structBegin(3)
+		chunks.i32Field(1, c.physical)
+		chunks.listBegin(2, thriftI32, 2)
+		chunks.i32(parquetPlainEncoding)
+		chunks.i32(parquetRLEEncoding)
+		chunks.listBegin(3, thriftBinary, 1)
+		chunks.binary([]byte(c.name))
+		chunks.i32Field(4, parquetUncompressed)
+		chunks.i64Field(5, int64(len(rows)))
+		chunks.i64Field(6, int64(len(page)))
+		chunks.i64Field(7, int64(len(page)))
+		chunks.i64Field(9, offset)
+		chunks.structEnd()
+		chunks.end()
+	}
+
+	// FileMetaData
+	meta := new(thriftWriter)
+	meta.begin()
+	meta.i32Field(1, parquetFormatVersion)
+	meta.listBegin(2, thriftStruct, len(columns)+1)
+	meta.begin()
+	meta.binaryField(4, []byte("schema"))
+	meta.i32Field(5, int32(len(columns)))
+	meta.end()
+	for _, c := range columns {
+		meta.begin()
+		meta.i32Field(1, c.physical)
+		if c.typeLength > 0 {
+			meta.i32Field(2, c.typeLength)
+		}
+		meta.i32Field(3, parquetOptional)
+		meta.binaryField(4, []byte(c.name))
+		if c.converted != parquetNoConvertedType {
+			meta.i32Field(6, c.converted)
+		}
+		if c.logical != nil {
+			meta.structBegin(10)
+			c.logical(meta)
+			meta.structEnd()
+		}
+		meta.end()
+	}
+	meta.i64Field(3, int64(len(rows)))
+	if len(rows) == 0 {
+		meta.listBegin(4, thriftStruct, 0)
+	} else {
+		// RowGroup
+		meta.listBegin(4, thriftStruct, 1)
+		meta.begin()
+		meta.listBegin(1, thriftStruct, len(columns))
+		meta.buf.Write(chunks.buf.Bytes())
+		meta.i64Field(2, totalSize)
+		meta.i64Field(3, int64(len(rows)))
+		meta.end()
+	}
+	meta.binaryField(6, []byte(parquetCreatedBy))
+	meta.end()
+
+	file.Write(meta.buf.Bytes())
+	var footerLength [4]byte
+	binary.LittleEndian.PutUint32(footerLength[:], uint32(meta.buf.Len()))
+	file.Write(footerLength[:])
+	file.WriteString(parquetMagic)
+	_, err := out.Write(file.Bytes())
+	return err
+}
+
+// encodeParquetPage encodes the values of column index of rows as a data page, including its header
+func encodeParquetPage(c parquetColumn, index int, rows [][]interface{}) ([]byte, error) {
+	var (
+		defined = make([]bool, len(rows))
+		values  bytes.Buffer
+		bits    []byte
+		count   int
+		scratch [8]byte
+	)
+	for i, row := range rows {
+		v := row[index]
+		if v == nil {
+			continue
+		}
+		defined[i] = true
+		switch c.physical {
+		case parquetBoolean:
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("column %s expected bool got %T", c.name, v)
+			}
+			if count%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if b {
+				bits[count/8] |= 1 << uint(count%8)
+			}
+		case parquetInt32:
+			n, ok := v.(int32)
+			if !ok {
+				return nil, fmt.Errorf("column %s expected int32 got %T", c.name, v)
+			}
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(n))
+			values.Write(scratch[:4])
+		case parquetInt64:
+			n, ok := v.(int64)
+			if !ok {
+				return nil, fmt.Errorf("column %s expected int64 got %T", c.name, v)
+			}
+			binary.LittleEndian.PutUint64(scratch[:], uint64(n))
+			values.Write(scratch[:])
+		case parquetDouble:
+			f, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("column %s expected float64 got %T", c.name, v)
+			}
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(f))
+			values.Write(scratch[:])
+		case parquetByteArray, parquetFixedLenByteArray:
+			b, ok := v.([]byte)
+			if !ok {
+				return nil, fmt.Errorf("column %s expected []byte got %T", c.name, v)
+			}
+			if c.physical == parquetFixedLenByteArray {
+				if len(b) != int(c.typeLength) {
+					return nil, fmt.Errorf("column %s expected %d bytes got %d", c.name, c.typeLength, len(b))
+				}
+			} else {
+				binary.LittleEndian.PutUint32(scratch[:4], uint32(len(b)))
+				values.Write(scratch[:4])
+			}
+			values.Write(b)
+		}
+		count++
+	}
+	values.Write(bits)
+
+	levels := encodeDefinitionLevels(defined)
+	size := int32(len(levels) + values.Len())
+
+	// PageHeader
+	header := new(thriftWriter)
+	header.begin()
+	header.i32Field(1, parquetDataPage)
+	header.i32Field(2, size)
+	header.i32Field(3, size)
+	header.structBegin(5)
+	header.i32Field(1, int32(len(rows)))
+	header.i32Field(2, parquetPlainEncoding)
+	header.i32Field(3, parquetRLEEncoding)
+	header.i32Field(4, parquetRLEEncoding)
+	header.structEnd()
+	header.end()
+
+	page := append(header.buf.Bytes(), levels...)
+	return append(page, values.Bytes()...), nil
+}
+
+// encodeDefinitionLevels encodes the definition levels of an optional column, 1 for defined values and 0 for NULLs,
+// as RLE runs of the RLE/bit-packing hybrid encoding with bit width 1, prefixed by its length
+func encodeDefinitionLevels(defined []bool) []byte {
+	var runs []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		runs = appendUvarint(runs, uint64(j-i)<<1)
+		if defined[i] {
+			runs = append(runs, 1)
+		} else {
+			runs = append(runs, 0)
+		}
+		i = j
+	}
+	levels := make([]byte, 4, 4+len(runs))
+	binary.LittleEndian.PutUint32(levels, uint32(len(runs)))
+	return append(levels, runs...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	return append(b, scratch[:n]...)
+}
+
+// thriftWriter writes the thrift compact protocol encoding of parquet's metadata structs
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastField are the ids of the last field written in each nested struct, field ids are delta encoded
+	lastField []int16
+}
+
+// begin begins a struct that isn't a field, i.e. a top level struct or a list element
+func (w *thriftWriter) begin() {
+	w.lastField = append(w.lastField, 0)
+}
+
+// end ends a struct begun by begin
+func (w *thriftWriter) end() {
+	w.buf.WriteByte(0)
+	w.lastField = w.lastField[:len(w.lastField)-1]
+}
+
+func (w *thriftWriter) field(typ byte, id int16) {
+	last := &w.lastField[len(w.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.buf.Write(appendUvarint(nil, uint64(v<<1)^uint64(v>>63)))
+}
+
+func (w *thriftWriter) structBegin(id int16) {
+	w.field(thriftStruct, id)
+	w.begin()
+}
+
+func (w *thriftWriter) structEnd() {
+	w.end()
+}
+
+func (w *thriftWriter) boolField(id int16, v bool) {
+	if v {
+		w.field(thriftTrue, id)
+	} else {
+		w.field(thriftFalse, id)
+	}
+}
+
+func (w *thriftWriter) byteField(id int16, v int8) {
+	w.field(thriftByte, id)
+	w.buf.WriteByte(byte(v))
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+	w.field(thriftI32, id)
+	w.i32(v)
+}
+
+func (w *thriftWriter) i32(v int32) {
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+	w.field(thriftI64, id)
+	w.varint(v)
+}
+
+func (w *thriftWriter) binaryField(id int16, v []byte) {
+	w.field(thriftBinary, id)
+	w.binary(v)
+}
+
+func (w *thriftWriter) binary(v []byte) {
+	w.buf.Write(appendUvarint(nil, uint64(len(v))))
+	w.buf.Write(v)
+}
+
+// listBegin writes the header of a list field of size elements of type elem, followed by the elements
+func (w *thriftWriter) listBegin(id int16, elem byte, size int) {
+	w.field(thriftList, id)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elem)
+	w.buf.Write(appendUvarint(nil, uint64(size)))
+}
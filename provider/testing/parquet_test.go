@@ -0,0 +1,385 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thriftReader decodes thrift compact protocol structs into maps of field ids to values, to check written metadata.
+// It's written from the thrift and parquet specs independently of the writer, sharing none of its constants.
+type thriftReader struct {
+	*bytes.Reader
+}
+
+func (r thriftReader) varint(t *testing.T) int64 {
+	v, err := binary.ReadUvarint(r)
+	require.NoError(t, err)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r thriftReader) value(t *testing.T, typ byte) interface{} {
+	switch typ {
+	case 1: // BOOLEAN_TRUE
+		return true
+	case 2: // BOOLEAN_FALSE
+		return false
+	case 3: // BYTE
+		b, err := r.ReadByte()
+		require.NoError(t, err)
+		return int8(b)
+	case 4, 5, 6: // I16, I32, I64
+		return r.varint(t)
+	case 8: // BINARY
+		n, err := binary.ReadUvarint(r)
+		require.NoError(t, err)
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		require.NoError(t, err)
+		return string(b)
+	case 9: // LIST
+		header, err := r.ReadByte()
+		require.NoError(t, err)
+		size := int(header >> 4)
+		if size == 15 {
+			n, err := binary.ReadUvarint(r)
+			require.NoError(t, err)
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(t, header&0x0f)
+		}
+		return list
+	case 12: // STRUCT
+		return r.structure(t)
+	}
+	t.Fatalf("unexpected thrift type %d", typ)
+	return nil
+}
+
+func (r thriftReader) structure(t *testing.T) map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		header, err := r.ReadByte()
+		require.NoError(t, err)
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint(t))
+		}
+		fields[id] = r.value(t, header&0x0f)
+		last = id
+	}
+}
+
+// parquetFile is a parquet file decoded by readParquet
+type parquetFile struct {
+	// columns are the schema elements of the columns, after the root
+	columns []map[int16]interface{}
+	// rows hold the values of the columns, nil for NULL: bool, int32, int64, float64 or []byte by the physical type
+	rows [][]interface{}
+}
+
+// readParquet decodes a parquet file of optional flat columns following the format's spec, checking the footer,
+// the offsets and sizes of the column chunks and the number of values of their pages
+func readParquet(t *testing.T, data []byte) parquetFile {
+	t.Helper()
+	require.Greater(t, len(data), 12)
+	require.Equal(t, "PAR1", string(data[:4]))
+	require.Equal(t, "PAR1", string(data[len(data)-4:]))
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := bytes.NewReader(data[len(data)-8-footerLength : len(data)-8])
+	meta := thriftReader{footer}.structure(t)
+	require.Zero(t, footer.Len(), "trailing bytes after FileMetaData")
+
+	elements := meta[2].([]interface{})
+	root := elements[0].(map[int16]interface{})
+	var file parquetFile
+	for _, e := range elements[1:] {
+		element := e.(map[int16]interface{})
+		require.Equal(t, int64(1), element[3], "repetition of %s isn't OPTIONAL", element[4])
+		file.columns = append(file.columns, element)
+	}
+	require.Equal(t, int64(len(file.columns)), root[5])
+
+	numRows := meta[3].(int64)
+	file.rows = make([][]interface{}, numRows)
+	for i := range file.rows {
+		file.rows[i] = make([]interface{}, len(file.columns))
+	}
+	rowGroups := meta[4].([]interface{})
+	if numRows == 0 {
+		require.Empty(t, rowGroups)
+		return file
+	}
+	require.Len(t, rowGroups, 1)
+	rowGroup := rowGroups[0].(map[int16]interface{})
+	require.Equal(t, numRows, rowGroup[3])
+	chunks := rowGroup[1].([]interface{})
+	require.Len(t, chunks, len(file.columns))
+	var totalSize int64
+	for i, c := range chunks {
+		chunk := c.(map[int16]interface{})
+		column := chunk[3].(map[int16]interface{})
+		element := file.columns[i]
+		require.Equal(t, element[1], column[1], "physical type of column chunk %d", i)
+		require.Equal(t, []interface{}{element[4]}, column[3])
+		require.Equal(t, int64(0), column[4], "codec of column %s isn't UNCOMPRESSED", element[4])
+		require.Equal(t, numRows, column[5])
+		require.Equal(t, chunk[2], column[9])
+
+		offset := column[9].(int64)
+		page := bytes.NewReader(data[offset : len(data)-8-footerLength])
+		header := thriftReader{page}.structure(t)
+		headerSize := int64(len(data)-8-footerLength-int(offset)) - int64(page.Len())
+		require.Equal(t, int64(0), header[1], "page of column %s isn't a DATA_PAGE", element[4])
+		require.Equal(t, header[2], header[3])
+		require.Equal(t, headerSize+header[3].(int64), column[7], "total_compressed_size of column %s", element[4])
+		require.Equal(t, column[6], column[7])
+		totalSize += column[7].(int64)
+		dataPage := header[5].(map[int16]interface{})
+		require.Equal(t, numRows, dataPage[1])
+		require.Equal(t, int64(0), dataPage[2], "encoding of column %s isn't PLAIN", element[4])
+		require.Equal(t, int64(3), dataPage[3], "definition level encoding of column %s isn't RLE", element[4])
+
+		body := make([]byte, header[2].(int64))
+		_, err := io.ReadFull(page, body)
+		require.NoError(t, err)
+		readParquetColumn(t, element, body, file.rows, i)
+	}
+	require.Equal(t, totalSize, rowGroup[2])
+	return file
+}
+
+// readParquetColumn decodes the definition levels and PLAIN values of a data page of column index into rows
+func readParquetColumn(t *testing.T, element map[int16]interface{}, body []byte, rows [][]interface{}, index int) {
+	t.Helper()
+	levelsLength := int(binary.LittleEndian.Uint32(body))
+	defined := readRLEBitWidth1(t, body[4:4+levelsLength], len(rows))
+	values := bytes.NewReader(body[4+levelsLength:])
+	var booleans []byte
+	var count int
+	for i, d := range defined {
+		if !d {
+			continue
+		}
+		switch element[1] {
+		case int64(0): // BOOLEAN, bit-packed LSB first
+			if booleans == nil {
+				booleans = make([]byte, values.Len())
+				_, err := io.ReadFull(values, booleans)
+				require.NoError(t, err)
+			}
+			rows[i][index] = booleans[count/8]&(1<<uint(count%8)) != 0
+		case int64(1): // INT32
+			var v int32
+			require.NoError(t, binary.Read(values, binary.LittleEndian, &v))
+			rows[i][index] = v
+		case int64(2): // INT64
+			var v int64
+			require.NoError(t, binary.Read(values, binary.LittleEndian, &v))
+			rows[i][index] = v
+		case int64(5): // DOUBLE
+			var v float64
+			require.NoError(t, binary.Read(values, binary.LittleEndian, &v))
+			rows[i][index] = v
+		case int64(6): // BYTE_ARRAY
+			var n uint32
+			require.NoError(t, binary.Read(values, binary.LittleEndian, &n))
+			v := make([]byte, n)
+			_, err := io.ReadFull(values, v)
+			require.NoError(t, err)
+			rows[i][index] = v
+		case int64(7): // FIXED_LEN_BYTE_ARRAY
+			v := make([]byte, element[2].(int64))
+			_, err := io.ReadFull(values, v)
+			require.NoError(t, err)
+			rows[i][index] = v
+		default:
+			t.Fatalf("unexpected physical type %v of column %s", element[1], element[4])
+		}
+		count++
+	}
+	if element[1] != int64(0) {
+		require.Zero(t, values.Len(), "trailing bytes after the values of column %s", element[4])
+	}
+}
+
+// readRLEBitWidth1 decodes n levels of bit width 1 encoded by the RLE/bit-packing hybrid encoding
+func readRLEBitWidth1(t *testing.T, data []byte, n int) []bool {
+	t.Helper()
+	r := bytes.NewReader(data)
+	var levels []bool
+	for r.Len() > 0 {
+		header, err := binary.ReadUvarint(r)
+		require.NoError(t, err)
+		if header&1 == 1 {
+			// bit-packed run of groups of 8 values
+			packed := make([]byte, header>>1)
+			_, err := io.ReadFull(r, packed)
+			require.NoError(t, err)
+			for _, b := range packed {
+				for bit := 0; bit < 8; bit++ {
+					levels = append(levels, b&(1<<uint(bit)) != 0)
+				}
+			}
+			continue
+		}
+		value, err := r.ReadByte()
+		require.NoError(t, err)
+		require.LessOrEqual(t, value, byte(1))
+		for i := uint64(0); i < header>>1; i++ {
+			levels = append(levels, value == 1)
+		}
+	}
+	require.GreaterOrEqual(t, len(levels), n)
+	return levels[:n]
+}
+
+func TestWriteParquet_ReadBack(t *testing.T) {
+	var columns []parquetColumn
+	for _, c := range []schema.Column{
+		{Name: "enabled", Type: schema.TypeBool},
+		{Name: "small", Type: schema.TypeSmallInt},
+		{Name: "count", Type: schema.TypeInt},
+		{Name: "big", Type: schema.TypeBigInt},
+		{Name: "ratio", Type: schema.TypeFloat},
+		{Name: "id", Type: schema.TypeUUID},
+		{Name: "name", Type: schema.TypeString},
+		{Name: "status", Type: schema.TypeEnum},
+		{Name: "created_at", Type: schema.TypeTimestamp},
+		{Name: "data", Type: schema.TypeByteArray},
+		{Name: "doc", Type: schema.TypeJSON},
+		{Name: "tags", Type: schema.TypeStringArray},
+		{Name: "ip", Type: schema.TypeInet},
+		{Name: "cidr", Type: schema.TypeCIDR},
+		{Name: "mac", Type: schema.TypeMacAddr},
+		{Name: "price", Type: schema.TypeNumeric},
+	} {
+		columns = append(columns, newParquetColumn(c))
+	}
+	id := uuid.MustParse("5f0e3bd0-8bd4-4a2e-9d2c-2d4f1b0f1c01")
+	createdAt := time.Date(2022, 3, 4, 5, 6, 7, 8000, time.UTC)
+	var rows [][]interface{}
+	// enough rows for the booleans to span several bytes
+	for i := 0; i < 20; i++ {
+		row := []interface{}{
+			i%3 == 0, int32(-i), int32(i * 1000), int64(i) << 40, float64(i) / 4, id[:], []byte("name"), []byte("active"),
+			createdAt.UnixMicro(), []byte{0, byte(i)}, []byte(`{"key": "value"}`), []byte(`["a", "b"]`),
+			[]byte("10.0.0.1"), []byte("10.0.0.0/8"), []byte("08:00:2b:01:02:03"), []byte("12.50"),
+		}
+		// a NULL in a different column of every row, and rows entirely NULL
+		if i%5 == 4 {
+			row = make([]interface{}, len(columns))
+		} else {
+			row[i%len(columns)] = nil
+		}
+		rows = append(rows, row)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeParquet(&buf, columns, rows))
+
+	file := readParquet(t, buf.Bytes())
+	require.Len(t, file.columns, len(columns))
+	for i, c := range columns {
+		assert.Equal(t, c.name, file.columns[i][4])
+	}
+	assert.Equal(t, rows, file.rows)
+
+	// logical types of the columns, by the LogicalType union field id
+	logicalType := func(name string) map[int16]interface{} {
+		for _, c := range file.columns {
+			if c[4] == name {
+				return c[10].(map[int16]interface{})
+			}
+		}
+		t.Fatalf("column %s not found", name)
+		return nil
+	}
+	assert.Equal(t, map[int16]interface{}{10: map[int16]interface{}{1: int8(16), 2: true}}, logicalType("small"))
+	assert.Equal(t, map[int16]interface{}{14: map[int16]interface{}{}}, logicalType("id"))
+	assert.Equal(t, map[int16]interface{}{1: map[int16]interface{}{}}, logicalType("name"))
+	assert.Equal(t, map[int16]interface{}{4: map[int16]interface{}{}}, logicalType("status"))
+	assert.Equal(t, map[int16]interface{}{8: map[int16]interface{}{1: false, 2: map[int16]interface{}{2: map[int16]interface{}{}}}}, logicalType("created_at"))
+	assert.Equal(t, map[int16]interface{}{12: map[int16]interface{}{}}, logicalType("tags"))
+
+	empty := readParquet(t, func() []byte {
+		var buf bytes.Buffer
+		require.NoError(t, writeParquet(&buf, columns, nil))
+		return buf.Bytes()
+	}())
+	assert.Len(t, empty.columns, len(columns))
+	assert.Empty(t, empty.rows)
+}
+
+func TestReadRLEBitWidth1(t *testing.T) {
+	// a bit-packed run of one group, 0b00000101, followed by an RLE run of 3 ones
+	assert.Equal(t, []bool{true, false, true, false, false, false, false, false, true, true, true}, readRLEBitWidth1(t, []byte{3, 5, 6, 1}, 11))
+}
+
+func TestWriteParquet(t *testing.T) {
+	columns := []parquetColumn{
+		newParquetColumn(schema.Column{Name: "name", Type: schema.TypeString}),
+		newParquetColumn(schema.Column{Name: "count", Type: schema.TypeBigInt}),
+		newParquetColumn(schema.Column{Name: "enabled", Type: schema.TypeBool}),
+	}
+	rows := [][]interface{}{
+		{[]byte("a"), int64(1), true},
+		{nil, int64(2), nil},
+		{[]byte("c"), nil, false},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeParquet(&buf, columns, rows))
+	data := buf.Bytes()
+
+	require.Equal(t, parquetMagic, string(data[:4]))
+	require.Equal(t, parquetMagic, string(data[len(data)-4:]))
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := thriftReader{bytes.NewReader(data[len(data)-8-footerLength : len(data)-8])}.structure(t)
+
+	assert.Equal(t, int64(len(rows)), meta[3])
+	elements := meta[2].([]interface{})
+	require.Len(t, elements, len(columns)+1)
+	assert.Equal(t, map[int16]interface{}{4: "schema", 5: int64(len(columns))}, elements[0])
+	assert.Equal(t, map[int16]interface{}{
+		1: int64(parquetByteArray), 3: int64(parquetOptional), 4: "name", 6: int64(parquetUTF8),
+		10: map[int16]interface{}{1: map[int16]interface{}{}},
+	}, elements[1])
+	assert.Equal(t, map[int16]interface{}{
+		1: int64(parquetInt64), 3: int64(parquetOptional), 4: "count", 6: int64(parquetInt64Converted),
+		10: map[int16]interface{}{10: map[int16]interface{}{1: int8(64), 2: true}},
+	}, elements[2])
+
+	rowGroups := meta[4].([]interface{})
+	require.Len(t, rowGroups, 1)
+	chunks := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	require.Len(t, chunks, len(columns))
+
+	// the name column's page holds the definition levels and the PLAIN values of its non-null rows
+	chunk := chunks[0].(map[int16]interface{})[3].(map[int16]interface{})
+	assert.Equal(t, []interface{}{"name"}, chunk[3])
+	page := bytes.NewReader(data[chunk[9].(int64):])
+	header := thriftReader{page}.structure(t)
+	assert.Equal(t, int64(len(rows)), header[5].(map[int16]interface{})[1])
+	body := make([]byte, header[2].(int64))
+	_, err := page.Read(body)
+	require.NoError(t, err)
+	assert.Equal(t, append(encodeDefinitionLevels([]bool{true, false, true}), 1, 0, 0, 0, 'a', 1, 0, 0, 0, 'c'), body)
+}
+
+func TestEncodeDefinitionLevels(t *testing.T) {
+	assert.Equal(t, []byte{0, 0, 0, 0}, encodeDefinitionLevels(nil))
+	// runs of 2 defined, 1 null and 1 defined value, each a varint of the run length shifted left and the level
+	assert.Equal(t, []byte{6, 0, 0, 0, 4, 1, 2, 0, 2, 1}, encodeDefinitionLevels([]bool{true, true, false, true}))
+}
@@ -0,0 +1,70 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/cloudquery/cq-provider-sdk/database/dsn"
+)
+
+// providerResult is the outcome of a single case of TestProviders
+type providerResult struct {
+	Name string
+	// DSN is the case's database URL with its password redacted
+	DSN    string
+	Passed bool
+}
+
+// TestProviders runs TestResource for each case as a subtest named by its provider, in parallel unless the case is
+// NotParallel, and logs which providers passed or failed once all of them finished. Cases may test different providers
+// against different databases with DatabaseURL, each DSN has its own connection pool. Cases sharing a Provider
+// shouldn't run in parallel, since TestResource configures it.
+func TestProviders(t *testing.T, cases []ResourceTestCase) {
+	t.Helper()
+	results := make([]providerResult, len(cases))
+	// parallel subtests only run once their parent returns, so results are aggregated after this group
+	t.Run("providers", func(t *testing.T) {
+		for i, c := range cases {
+			i, c := i, c
+			results[i] = providerResult{Name: c.Provider.Name, DSN: c.redactedDatabaseURL()}
+			t.Run(c.Provider.Name, func(t *testing.T) {
+				t.Cleanup(func() { results[i].Passed = !t.Failed() })
+				TestResource(t, c)
+			})
+		}
+	})
+	t.Logf("provider results:%s", formatProviderResults(results))
+}
+
+// redactedDatabaseURL returns the database URL of the case without its password, for logging
+func (r ResourceTestCase) redactedDatabaseURL() string {
+	dbURL, err := r.databaseURL()
+	if err != nil {
+		return err.Error()
+	}
+	u, err := dsn.ParseConnectionString(dbURL)
+	if err != nil {
+		return err.Error()
+	}
+	return u.URL.Redacted()
+}
+
+// formatProviderResults renders results one per line in the order of the cases, followed by the totals
+func formatProviderResults(results []providerResult) string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tRESULT\tDATABASE")
+	passed := 0
+	for _, r := range results {
+		result := "FAIL"
+		if r.Passed {
+			result = "PASS"
+			passed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, result, r.DSN)
+	}
+	_ = w.Flush()
+	return fmt.Sprintf("\n%s%d of %d providers passed", b.String(), passed, len(results))
+}
@@ -0,0 +1,118 @@
+package testing
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+)
+
+// TestReportVersion is the version of the TestReport format. Fields may be added in the same version, it's only
+// incremented on changes breaking the tools parsing the report.
+const TestReportVersion = 1
+
+// TestReport is the machine-readable report of a TestResource run, written to ResourceTestCase.ReportPath as JSON
+// whether the test passed or not. Sections the run didn't reach, e.g. the tables of a failed fetch, are empty.
+type TestReport struct {
+	Version  int    `json:"version"`
+	Test     string `json:"test"`
+	Provider string `json:"provider"`
+	Passed   bool   `json:"passed"`
+	// StartedAt is when TestResource started, Duration covers the whole run including table creation and verification
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// FetchDurationSeconds covers the fetch only
+	FetchDurationSeconds float64 `json:"fetch_duration_seconds"`
+	// ResourceCount is the total amount of resources fetched
+	ResourceCount uint64 `json:"resource_count"`
+	// Tables are the fetched tables and their relations, sorted by name
+	Tables      []TestReportTable      `json:"tables"`
+	Diagnostics []TestReportDiagnostic `json:"diagnostics"`
+	// VerifyRetries is the number of verifier queries retried on a serialization failure, see VerifyInTransaction
+	VerifyRetries int64 `json:"verify_retries"`
+	// CacheHits and CacheMisses are the lookups of the resolvers in the fetch's execution.Cache
+	CacheHits   uint64 `json:"cache_hits"`
+	CacheMisses uint64 `json:"cache_misses"`
+}
+
+// TestReportTable is the report of a single table
+type TestReportTable struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+	// NilColumns are the columns NULL in every row, excluding the ones ignored in tests
+	NilColumns []string `json:"nil_columns"`
+}
+
+// TestReportDiagnostic is a diagnostic of the fetch
+type TestReportDiagnostic struct {
+	Resource   string   `json:"resource"`
+	ResourceID []string `json:"resource_id,omitempty"`
+	Severity   string   `json:"severity"`
+	Type       string   `json:"type"`
+	Summary    string   `json:"summary"`
+	Detail     string   `json:"detail,omitempty"`
+	Code       string   `json:"code,omitempty"`
+}
+
+func newTestReport(t *testing.T, resource *ResourceTestCase) *TestReport {
+	return &TestReport{
+		Version:     TestReportVersion,
+		Test:        t.Name(),
+		Provider:    resource.Provider.Name,
+		StartedAt:   time.Now().UTC(),
+		Tables:      []TestReportTable{},
+		Diagnostics: []TestReportDiagnostic{},
+	}
+}
+
+// addDiagnostics adds the diagnostics of the fetch summary to the report
+func (r *TestReport) addDiagnostics(diags diag.Diagnostics) {
+	for _, d := range diags {
+		desc := d.Description()
+		r.Diagnostics = append(r.Diagnostics, TestReportDiagnostic{
+			Resource:   desc.Resource,
+			ResourceID: desc.ResourceID,
+			Severity:   d.Severity().String(),
+			Type:       d.Type().String(),
+			Summary:    desc.Summary,
+			Detail:     desc.Detail,
+			Code:       desc.Code,
+		})
+	}
+}
+
+// addTables adds the tables of counts to the report, with their nil columns from the dead column report
+func (r *TestReport) addTables(counts RowCounts, deadColumns *DeadColumnReport) {
+	nilColumns := make(map[string][]string)
+	for _, c := range deadColumns.DeadColumns() {
+		if i := strings.Index(c, "."); i > 0 {
+			nilColumns[c[:i]] = append(nilColumns[c[:i]], c[i+1:])
+		}
+	}
+	var add func(counts RowCounts)
+	add = func(counts RowCounts) {
+		for name, c := range counts {
+			columns := nilColumns[name]
+			if columns == nil {
+				columns = []string{}
+			}
+			r.Tables = append(r.Tables, TestReportTable{Name: name, Rows: c.Count, NilColumns: columns})
+			add(c.Relations)
+		}
+	}
+	add(counts)
+	sort.Slice(r.Tables, func(i, j int) bool { return r.Tables[i].Name < r.Tables[j].Name })
+}
+
+// write writes the report to path as indented JSON
+func (r *TestReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,37 @@
+package testing
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestReport(t *testing.T) {
+	report := &TestReport{Version: TestReportVersion, Tables: []TestReportTable{}, Diagnostics: []TestReportDiagnostic{}}
+	deadColumns := NewDeadColumnReport()
+	deadColumns.populated = map[string]bool{"a.name": false, "a.id": true, "a_children.value": false, "a_children.id": true}
+	report.addTables(RowCounts{"a": {Count: 2, Relations: RowCounts{"a_children": {Count: 3, Relations: RowCounts{}}}}}, deadColumns)
+	report.addDiagnostics(diag.Diagnostics{
+		diag.NewBaseError(errors.New("denied"), diag.RESOLVING, diag.WithResourceName("a"), diag.WithCode("AccessDenied")),
+	})
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, report.write(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var actual TestReport
+	require.NoError(t, json.Unmarshal(data, &actual))
+	assert.Equal(t, []TestReportTable{
+		{Name: "a", Rows: 2, NilColumns: []string{"name"}},
+		{Name: "a_children", Rows: 3, NilColumns: []string{"value"}},
+	}, actual.Tables)
+	assert.Equal(t, []TestReportDiagnostic{
+		{Resource: "a", Severity: "Error", Type: "Resolving", Summary: "denied", Code: "AccessDenied"},
+	}, actual.Diagnostics)
+}
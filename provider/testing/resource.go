@@ -1,17 +1,25 @@
 package testing
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/cloudquery/cq-provider-sdk/cqproto"
 	"github.com/cloudquery/cq-provider-sdk/database"
+	"github.com/cloudquery/cq-provider-sdk/database/dsn"
 	"github.com/cloudquery/cq-provider-sdk/migration"
 	"github.com/cloudquery/cq-provider-sdk/provider"
 	"github.com/cloudquery/cq-provider-sdk/provider/diag"
@@ -22,14 +30,23 @@ import (
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
+	"github.com/thoas/go-funk"
 )
 
 type ResourceTestCase struct {
 	Provider *provider.Provider
-	Config   string
+	// Config is the provider's HCL configuration, it may be partial with omitted attributes filled from the provider's
+	// ConfigDefaults. If the provider's config implements provider.ResourceSelector, only the resources it selects are
+	// created, fetched and verified, while the tables of the others are dropped. With a RemoteProvider the config is
+	// decoded by Provider's Config as well to select the resources, so both must accept it.
+	Config string
+	// ConfigStruct is the provider's configuration as a struct with gohcl `hcl` tags, usually the provider's own config
+	// type, encoded to HCL in place of Config. Only one of Config and ConfigStruct may be set.
+	ConfigStruct interface{}
 	// we want it to be parallel by default
 	NotParallel bool
-	// ParallelFetchingLimit limits parallel resources fetch at a time
+	// ParallelFetchingLimit limits parallel resources fetch at a time. The test fails if an in process fetch resolves
+	// more resources concurrently, see FetchSummary MaxConcurrency.
 	ParallelFetchingLimit uint64
 	// SkipIgnoreInTest flag which detects if schema.Table or schema.Column should be ignored
 	SkipIgnoreInTest bool
@@ -37,19 +54,243 @@ type ResourceTestCase struct {
 	// If no verifiers specified for resource (resource name is not in key set of map),
 	// non emptiness check of all columns in table and its relations will be performed.
 	Verifiers map[string][]Verifier
+	// OnSQL is called with every statement (and its args) executed by the test harness and by the execution engine,
+	// including its inserts, deletes and transactions. Useful for debugging migration and insertion issues. Note it may
+	// be called concurrently.
+	OnSQL SQLObserverFunc
+	// EnforceColumnNaming fails the test if any column name in the provider isn't snake_case
+	EnforceColumnNaming bool
+	// ForbidReservedNames fails the test if any table or column name in the provider is a reserved SQL word, see
+	// provider.Provider LintReservedNames. Such names are otherwise only logged as a warning.
+	ForbidReservedNames bool
+	// RequireIgnoreReasons fails the test if any column in the provider marked IgnoreInTests has no Description
+	// explaining why, listing the undocumented columns, see provider.Provider LintIgnoredColumns
+	RequireIgnoreReasons bool
+	// ExpectSkipped lists resources expected to be skipped under Config because their schema.Table Condition isn't met.
+	// The test fails if any of them is fetched or if any other resource is skipped. Skipped resources aren't verified.
+	ExpectSkipped []string
+	// IncludeTags, if set, tests only the resources whose table has at least one of the tags, see schema.Table Tags.
+	// ExcludeTags doesn't test the resources whose table has any of its tags. Tables of resources excluded by their
+	// tags are neither created nor dropped, so tests of different categories of a provider can run in parallel.
+	IncludeTags []string
+	ExcludeTags []string
+	// StopOnError cancels the remaining fetch once any resource returns an ERROR, the test reports which resources were
+	// canceled vs. completed.
+	StopOnError bool
+	// DBSchema creates the tables in, fetches into and verifies the given postgres schema instead of public.
+	// The schema is created if it doesn't exist, allowing tests of different providers to share a database.
+	DBSchema string
+	// ResolverMiddleware is installed on the provider during the fetch, wrapping every table resolver after the provider's
+	// own middleware. For example, ResolverCallCounter counts resolver invocations per table.
+	ResolverMiddleware []schema.ResolverMiddleware
+	// ExpectDiagnosticMatches are regexps that must each match the summary or detail of at least one diagnostic reported
+	// by the fetch. Diagnostics matching any of them are expected and don't fail the test.
+	ExpectDiagnosticMatches []string
+	// StrictNoDiagnostics fails the test on any diagnostic of the fetch not matching ExpectDiagnosticMatches, including
+	// IGNORE ones and the failures of ExpectColumnErrors columns, e.g. for gating releases of mature providers. Note
+	// relations skipped by their Condition report a diagnostic as well, these need an ExpectDiagnosticMatches pattern.
+	StrictNoDiagnostics bool
+	// ExpectColumnErrors lists the columns with schema.Column IgnoreError whose resolver is expected to fail, as
+	// "table.column". Their failures are only logged, while failures of other IgnoreError columns fail the test. Either
+	// way the column must have a value in at least one row for the default verification.
+	ExpectColumnErrors []string
+	// VerifyInTransaction runs all verifiers inside a single repeatable read transaction, so they see a consistent
+	// snapshot of the tables. Note a failed query aborts the transaction, failing the following verifiers as well.
+	// Queries failing with a serialization failure are retried a few times, see VerifyRetries.
+	VerifyInTransaction bool
+	// VerifyRetries, if set, is incremented by the number of verifier queries retried on a serialization failure when
+	// VerifyInTransaction is set. The count is added to the TestReport as well.
+	VerifyRetries *int64
+	// MaxErrors limits the number of fetch errors collected and reported by the test, further errors are only counted.
+	// Defaults to defaultMaxErrors.
+	MaxErrors int
+	// ErrorFormat controls how the errors of a failed fetch are rendered, defaults to ErrorFormatPlain
+	ErrorFormat ErrorFormat
+	// CheckUniqueCQIDs verifies cq_id is unique in every fetched table after the fetch, see UniqueCQIDsVerifier
+	CheckUniqueCQIDs bool
+	// ExpectClients are the identities of the clients the provider is multiplexed over, e.g. account ids. Each must have
+	// at least one row in every fetched top level table declaring ClientColumn, see ClientCoverageVerifier.
+	ExpectClients []string
+	// ClientColumn is the column identifying the client of the rows checked by ExpectClients, defaults to account_id
+	ClientColumn string
+	// HeavyColumns are excluded from the json_agg of the rows done by the default verification, to avoid materializing
+	// large (e.g. multi-megabyte JSON) values of the whole table. Their non-nullness is checked by a separate count instead.
+	HeavyColumns []string
+	// NonEmptyArrayColumns are array columns which the default verification treats as empty unless at least one row has
+	// a non-empty array, so a resolver always yielding empty arrays is flagged. Other columns only need a non nil value.
+	NonEmptyArrayColumns []string
+	// RemoteProvider, when set, is configured and fetched over gRPC instead of Provider, e.g. a provider binary served
+	// via serve.Serve. Provider is still required for the tables' schema used to create and verify the tables.
+	// Options acting on the provider in process can't be used with it, the test fails up front if any of them is set:
+	// OnSQL, SlowQueryThreshold, ResolverMiddleware, Clock, RecoverPanics, ClientFactory, RecordFixturesDir,
+	// ReplayFixturesDir and AssertDeterministic. Diagnostics received over gRPC lose their underlying errors, so neither
+	// can ExpectSkipped and ExpectColumnErrors, use ExpectDiagnosticMatches to expect their diagnostics instead.
+	RemoteProvider cqproto.CQProvider
+	// RecordFixturesDir, when set, records the resource items sent by every table resolver during the fetch into JSON
+	// fixture files in the directory, one file per table, written once the fetch succeeds.
+	RecordFixturesDir string
+	// ReplayFixturesDir, when set, replays the recorded resource items instead of calling the table resolvers, allowing
+	// the test to run without network. The provider is still configured, and column resolvers, relations and post
+	// resolvers run as usual, so they must not call the provider's APIs themselves. Relation items are matched to their
+	// parent by its primary key, so parents keyed by a random cq_id can't be replayed. Tables without a recorded fixture
+	// fail the fetch. Only one of RecordFixturesDir and ReplayFixturesDir may be set.
+	ReplayFixturesDir string
+	// FixtureItems maps table names to a value of the type their resolver sends, replayed items are decoded into it.
+	// Items of tables missing from it are replayed as map[string]interface{}.
+	FixtureItems map[string]interface{}
+	// Clock is installed on the provider during the fetch, e.g. a FixedClock makes time dependent columns such as
+	// cq_fetch_date stable for snapshot comparison. It only applies in process, not to a RemoteProvider.
+	Clock schema.Clock
+	// RecoverPanics, true if nil, reports resolver panics as PANIC diagnostics failing the test, with the stack in their
+	// details. Set it to false while debugging to let a panic crash the test binary and go test print the real stack:
+	// the remaining tests of the package don't run then and the test tables aren't cleaned up. It only applies in
+	// process, not to a RemoteProvider.
+	RecoverPanics *bool
+	// MaxItemsPerResource limits the items fetched by the top level table of each resource, for fast bounded smoke tests.
+	// Relations are still fetched for every item kept.
+	MaxItemsPerResource int
+	// MaxTotalRows, if positive, limits the rows fetched across all tables and relations, aborting the fetch with an
+	// ERROR diagnostic once exceeded. It's a safety valve protecting CI from runaway resolvers, MaxItemsPerResource
+	// bounds each resource instead.
+	MaxTotalRows int
+	// MaxConnectionsPerResource, if positive, limits the database connections each resource uses at a time, see
+	// cqproto.FetchResourcesRequest MaxConnectionsPerResource for its interaction with ParallelFetchingLimit.
+	MaxConnectionsPerResource int
+	// BaselineSummaryPath is a JSON file of the resource counts of a previous fetch, the test fails if the count of
+	// any resource in it drifted by more than BaselineTolerance percent. It's written if it doesn't exist yet, or if
+	// UpdateBaselineSummaryEnv is set to true.
+	BaselineSummaryPath string
+	// BaselineTolerance is the drift percentage allowed from BaselineSummaryPath counts, defaults to 0 (exact counts)
+	BaselineTolerance float64
+	// RowSnapshotDir, if set, holds a JSON snapshot of the rows of each verified resource, named after the resource, the
+	// test fails if the fetched rows changed, see RowSnapshotVerifier. Stabilize changing values with Clock and
+	// SnapshotMasker.
+	RowSnapshotDir string
+	// SnapshotMasker are the rules masking the values of the row snapshots of RowSnapshotDir, applied in order
+	SnapshotMasker []MaskRule
+	// PreserveOnFailure drops the tables once the test passed, but keeps them if it failed so the fetched data can be
+	// inspected, logging the DSN and schema to connect to. Tables are otherwise left as is until the next run drops them.
+	PreserveOnFailure bool
+	// VerifyMaxDepth limits the default verification to the given number of levels of each resource's table tree, e.g. 2
+	// verifies the top level table and its direct relations. Deeper relations are neither verified nor reported as
+	// failures. Zero verifies all relations.
+	VerifyMaxDepth int
+	// DeadColumnReport, when set, aggregates the columns never populated by the fetch, so a report shared by the tests
+	// of several configs can be printed once they all ran. Each test logs the report of its own fetch regardless.
+	DeadColumnReport *DeadColumnReport
+	// BeforeMigrate are SQL statements executed before the tables are dropped and created, e.g. to install an extension
+	// with CREATE EXTENSION IF NOT EXISTS. AfterMigrate are executed once the tables are created. Both run in DBSchema's
+	// search_path, and any failing statement fails the test.
+	BeforeMigrate []string
+	AfterMigrate  []string
+	// TypeOverrides maps value types to the DDL type of their columns when creating the tables, overriding the
+	// dialect's mapping, e.g. to prototype a new type mapping before adding it to the dialect. Values are still inserted
+	// as the dialect's GetResourceValues returns them, so the database must be able to cast them to the overriding type.
+	TypeOverrides map[schema.ValueType]string
+	// AssertIdempotent fetches twice, failing unless the second fetch left the same row counts and cq_ids in every
+	// table, i.e. no rows were duplicated or dropped. Rows are upserted on their primary keys, so this catches resolvers
+	// producing unstable ids. The tables are verified with the data of the second fetch.
+	AssertIdempotent bool
+	// AssertDeterministic fetches a second time into a schema of its own, named after DBSchema with a _deterministic
+	// suffix, failing on any row or column value differing between the fetches, such as values depending on the wall
+	// clock or map iteration order. Rows are matched by their table's primary keys. Encrypted columns aren't compared,
+	// nor the parent cq_id columns of relations, random for parents without primary keys. It can't be used with a
+	// RemoteProvider.
+	AssertDeterministic bool
+	// DeterministicIgnoreColumns lists further columns AssertDeterministic doesn't compare, named "column" for every
+	// table or "table.column", e.g. volatile fetch timestamps. They're still verified like any other column.
+	DeterministicIgnoreColumns []string
+	// ReportPath, when set, is where a JSON TestReport of the run is written once the test finished, whether it passed
+	// or not, e.g. for dashboards tracking the row counts, nil columns and diagnostics of providers over time.
+	ReportPath string
+	// ClientFactory, when set, replaces the provider's Configure in the test, e.g. to return a client built with a mock
+	// or pre-issued OAuth token instead of performing the live exchange. It receives the decoded config, defaults
+	// applied, and the returned client is the schema.ClientMeta passed to the resolvers, so it must be of the type the
+	// resolvers assert. Configure is otherwise never called, so providers whose Configure does more than building the
+	// client should expose that part for the factory to reuse. It doesn't apply to a RemoteProvider.
+	ClientFactory func(ctx context.Context, config interface{}) (schema.ClientMeta, diag.Diagnostics)
+	// DatabaseURL is the DSN of the database the test runs against, defaults to the DATABASE_URL environment variable
+	DatabaseURL string
+	// SlowQueryThreshold, if positive, reports a WARNING diagnostic with code SlowQueryCode for every statement executed
+	// by the test harness and by the execution engine, including inserts, taking longer than it. The diagnostics are
+	// logged and added to the TestReport, they don't fail the test. It only applies in process, like OnSQL.
+	SlowQueryThreshold time.Duration
+
+	// slowQueries records the queries exceeding SlowQueryThreshold
+	slowQueries *slowQueryRecorder
+	// remoteConfig is the config of the RemoteProvider decoded by Provider, selecting the resources to fetch
+	remoteConfig interface{}
 }
 
 // Verifier verifies tables specified by table schema (main table and its relations).
 type Verifier func(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool)
 
 type testResourceSender struct {
-	Errors []string
+	Errors []fetchError
+	// Skipped resources whose table Condition wasn't met
+	Skipped map[string]bool
+	// Statuses of each fetched resource
+	Statuses map[string]cqproto.ResourceFetchStatus
+	// FetchedResources are the resources whose resolver produced at least one resource item
+	FetchedResources map[string]bool
+	// Diagnostics reported by the fetch
+	Diagnostics diag.Diagnostics
+	// maxErrors collected in Errors, truncatedErrors counts the errors exceeding it
+	maxErrors       int
+	truncatedErrors int
+	// expected are the compiled ExpectDiagnosticMatches
+	expected []*regexp.Regexp
+	// strict collects every diagnostic not expected as an error, see StrictNoDiagnostics
+	strict bool
+	// ColumnErrors are failures of columns with schema.Column IgnoreError, which were set to NULL
+	ColumnErrors []string
+	// expectedColumns are the ExpectColumnErrors, whose failures aren't collected as errors
+	expectedColumns []string
+
+	// lock guards the sender, responses may be sent concurrently
+	lock    sync.Mutex
+	summary FetchSummary
+	// cache is passed to the resolvers of an in process fetch, for the cache stats of the summary
+	cache *execution.Cache
+	// concurrency records the resources resolved concurrently by an in process fetch, see FetchSummary MaxConcurrency
+	concurrency *execution.ConcurrencyTracker
+	// started is when the sender was created, right before the fetch
+	started time.Time
+}
+
+func newTestResourceSender(maxErrors int) *testResourceSender {
+	if maxErrors <= 0 {
+		maxErrors = defaultMaxErrors
+	}
+	return &testResourceSender{
+		Errors:           []fetchError{},
+		Skipped:          make(map[string]bool),
+		Statuses:         make(map[string]cqproto.ResourceFetchStatus),
+		FetchedResources: make(map[string]bool),
+		maxErrors:        maxErrors,
+		summary:          FetchSummary{Resources: make(map[string]uint64), Durations: make(map[string]time.Duration)},
+		cache:            execution.NewCache(),
+		concurrency:      execution.NewConcurrencyTracker(),
+		started:          time.Now(),
+	}
 }
 
+// TestDialectEnv names the dialect creating the tables of TestResource and HelperTestView, either builtin or registered
+// with schema.RegisterDialect. Note verifiers still query the tables with postgres SQL.
+const TestDialectEnv = "CQ_TEST_DIALECT"
+
+// testApplicationName is the application_name of the connections of the test harness itself, the connections of the
+// fetch are named by the provider, see provider.Provider RunID
+const testApplicationName = "cq-provider-sdk-test"
+
+const (
+	defaultDatabaseURL = "host=localhost user=postgres password=pass DB.name=postgres port=5432"
+	defaultMaxErrors   = 100
+)
+
 var (
-	dbConnOnce sync.Once
-	pool       execution.QueryExecer
-	dbErr      error
+	poolsLock sync.Mutex
+	pools     = make(map[string]execution.Storage)
 )
 
 func init() {
@@ -57,52 +298,390 @@ func init() {
 	_ = faker.SetRandomMapAndSliceMaxSize(1)
 }
 
+// TestResource creates the tables of the provider's resources, fetches them and verifies the fetched rows, see
+// ResourceTestCase.
 func TestResource(t *testing.T, resource ResourceTestCase) {
+	t.Helper()
+	TestResourceCtx(context.Background(), t, resource)
+}
+
+// TestResourceCtx is like TestResource, but fetches and verifies with ctx, so canceling it, e.g. by a larger test
+// orchestration, aborts the test promptly stating what was interrupted. Verifiers keep querying with the context they
+// pass, which is replaced by ctx unless it can be canceled itself.
+func TestResourceCtx(ctx context.Context, t *testing.T, resource ResourceTestCase) {
 	if !resource.NotParallel {
 		t.Parallel()
 	}
 	t.Helper()
+	if resource.RemoteProvider != nil {
+		if options := resource.inProcessOptions(); len(options) > 0 {
+			t.Fatalf("%s can't be used with a RemoteProvider", strings.Join(options, ", "))
+		}
+	}
+
+	var report *TestReport
+	if resource.ReportPath != "" {
+		report = newTestReport(t, &resource)
+		t.Cleanup(func() {
+			report.Passed = !t.Failed()
+			report.DurationSeconds = time.Since(report.StartedAt).Seconds()
+			if err := report.write(resource.ReportPath); err != nil {
+				t.Errorf("failed to write test report to %s: %s", resource.ReportPath, err)
+			}
+		})
+	}
 
 	// No need for configuration or db connection, get it out of the way first
 	// testTableIdentifiersForProvider(t, resource.Provider)
+	if resource.EnforceColumnNaming {
+		if diags := resource.Provider.LintColumnNames(provider.SnakeCaseColumnNamePattern); diags.HasDiags() {
+			t.Fatal(diags)
+		}
+	}
+	if resource.RequireIgnoreReasons {
+		if diags := resource.Provider.LintIgnoredColumns(); diags.HasDiags() {
+			t.Fatal(diags)
+		}
+	}
+	if diags := resource.Provider.LintReservedNames(); diags.HasDiags() {
+		if resource.ForbidReservedNames {
+			t.Fatal(diags)
+		}
+		t.Logf("warning: %s", diags)
+	}
 
-	conn, err := setupDatabase()
+	dbURL, err := resource.databaseURL()
 	if err != nil {
 		t.Fatal(err)
 	}
+	db, err := setupDatabase(dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.SlowQueryThreshold > 0 {
+		slowQueries := newSlowQueryRecorder(resource.SlowQueryThreshold)
+		resource.slowQueries = slowQueries
+		t.Cleanup(func() {
+			diags := slowQueries.diagnostics()
+			for _, d := range diags {
+				t.Logf("warning: %s", d.Description().Summary)
+			}
+			if report != nil {
+				report.addDiagnostics(diags)
+			}
+		})
+	}
+	conn := withTestContext(ctx, timeQueryExecer(observeQueryExecer(db, resource.OnSQL), resource.slowQueries))
+	if resource.DBSchema != "" {
+		if err := conn.Exec(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", strconv.Quote(resource.DBSchema))); err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	l := testlog.New(t)
 	l.SetLevel(hclog.Info)
 	resource.Provider.Logger = l
 
-	for _, table := range resource.Provider.ResourceMap {
-		if err := dropAndCreateTable(context.Background(), conn, table); err != nil {
-			assert.FailNow(t, fmt.Sprintf("failed to create tables %s", table.Name), err)
+	// the provider can't be configured twice, so the second fetch of AssertDeterministic gets a copy not configured yet
+	var deterministic *ResourceTestCase
+	if resource.AssertDeterministic {
+		second := resource
+		p := *resource.Provider
+		second.Provider = &p
+		second.DBSchema = dbSchemaName(resource.DBSchema) + deterministicSchemaSuffix
+		deterministic = &second
+	}
+
+	if err := configure(ctx, &resource, dbURL); err != nil {
+		checkCanceled(ctx, t, "configuring the provider")
+		t.Fatal(err)
+	}
+	// only the tables of resources selected by the config and tags are created, tables of resources deselected by the
+	// config are dropped, while those excluded by their tags are left to the tests of their category
+	selectedByConfig := make(map[string]bool, len(resource.Provider.ResourceMap))
+	for _, name := range resource.selectedByConfig() {
+		selectedByConfig[name] = true
+	}
+	selected := make(map[string]bool, len(resource.Provider.ResourceMap))
+	var tables []*schema.Table
+	for _, name := range resource.selectedResources() {
+		selected[name] = true
+		tables = append(tables, resource.Provider.ResourceMap[name])
+	}
+	resource.ExpectSkipped = funk.FilterString(resource.ExpectSkipped, func(name string) bool {
+		return selected[name] || !selectedByConfig[name]
+	})
+	for name, table := range resource.Provider.ResourceMap {
+		if selectedByConfig[name] {
+			continue
+		}
+		if err := dropTables(ctx, conn, resource.DBSchema, table); err != nil {
+			t.Fatal(err)
 		}
 	}
+	for _, sql := range resource.BeforeMigrate {
+		if err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("BeforeMigrate statement %q failed: %s", sql, err)
+		}
+	}
+	if print, _ := strconv.ParseBool(os.Getenv(PrintDDLEnv)); print {
+		logDDLPlan(t, tables, resource.TypeOverrides)
+	}
+	if err := dropAndCreateTables(ctx, conn, resource.DBSchema, tables, resource.TypeOverrides); err != nil {
+		assert.FailNow(t, "failed to create tables", err)
+	}
+	for _, sql := range resource.AfterMigrate {
+		if err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("AfterMigrate statement %q failed: %s", sql, err)
+		}
+	}
+	if resource.PreserveOnFailure {
+		t.Cleanup(func() {
+			if t.Failed() {
+				t.Logf("test failed, preserving tables for inspection in schema %q of database %q", dbSchemaName(resource.DBSchema), resource.redactedDatabaseURL())
+				return
+			}
+			// only the tables this case created, those excluded by their tags may still be used by parallel tests
+			for _, table := range tables {
+				if err := dropTables(context.Background(), conn, resource.DBSchema, table); err != nil {
+					t.Errorf("failed to drop table %s: %s", table.Name, err)
+				}
+			}
+		})
+	}
 
-	if err = fetch(t, &resource); err != nil {
+	fetchStart := time.Now()
+	sender, err := fetch(ctx, t, &resource, dbURL)
+	fetchDuration := time.Since(fetchStart)
+	if report != nil {
+		report.FetchDurationSeconds = fetchDuration.Seconds()
+	}
+	if err != nil {
+		checkCanceled(ctx, t, "fetching resources")
 		t.Fatal(err)
 	}
+	verifyFetch(t, &resource, sender)
+	summary := sender.FetchSummary()
+	summary.Duration = fetchDuration
+	t.Logf("fetched %d resources from %d tables with %d diagnostics", summary.ResourceCount, len(summary.Resources), len(summary.Diagnostics))
+	if summary.Cache.Hits+summary.Cache.Misses > 0 {
+		t.Logf("resolver cache: %d hits, %d misses", summary.Cache.Hits, summary.Cache.Misses)
+	}
+	if limit := resource.ParallelFetchingLimit; limit > 0 && uint64(summary.MaxConcurrency) > limit {
+		t.Errorf("fetch resolved %d resources concurrently, exceeding ParallelFetchingLimit %d", summary.MaxConcurrency, limit)
+	}
+	if report != nil {
+		report.ResourceCount = summary.ResourceCount
+		report.CacheHits, report.CacheMisses = summary.Cache.Hits, summary.Cache.Misses
+		report.addDiagnostics(summary.Diagnostics)
+	}
+	if path := os.Getenv(MetricsOutEnv); path != "" {
+		path = metricsFilePath(path, t.Name())
+		if err := writeMetricsFile(path, summary); err != nil {
+			t.Errorf("failed to write metrics to %s: %s", path, err)
+		}
+	}
+	if resource.BaselineSummaryPath != "" {
+		verifyBaselineSummary(t, resource.BaselineSummaryPath, resource.BaselineTolerance, summary)
+	}
+
+	if resource.AssertIdempotent {
+		// the tables are verified with the data of the second fetch, so are its skipped and fetched resources
+		sender = verifyIdempotent(ctx, t, &resource, conn, dbURL, tables)
+	}
+	if deterministic != nil {
+		verifyDeterministic(ctx, t, &resource, deterministic, conn, tables)
+	}
+
+	var querier pgxscan.Querier = conn
+	var tx execution.TXQueryExecer
+	if resource.VerifyInTransaction {
+		tx, err = beginSnapshot(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// rollback in case a verifier stops the test, this is a no-op once committed
+		defer func() { _ = tx.Rollback(context.Background()) }()
+		retrying := newRetryingQueryExecer(withTestContext(ctx, timeQueryExecer(observeQueryExecer(tx, resource.OnSQL), resource.slowQueries)), true)
+		defer func() {
+			if resource.VerifyRetries != nil {
+				*resource.VerifyRetries += retrying.Retries()
+			}
+			if report != nil {
+				report.VerifyRetries = retrying.Retries()
+			}
+		}()
+		querier = retrying
+	}
+
+	rowCounts := make(RowCounts, len(tables))
+	for _, table := range tables {
+		counts, err := CollectRowCounts(querier, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("row counts for %s:\n%s", table.Name, counts)
+		rowCounts[table.Name] = counts[table.Name]
+	}
+
+	deadColumns := NewDeadColumnReport()
+	for name := range selected {
+		if sender.Skipped[name] {
+			continue
+		}
+		if err := deadColumns.collect(querier, resource.Provider.ResourceMap[name], false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Logf("dead column report: %s", deadColumns)
+	if resource.DeadColumnReport != nil {
+		resource.DeadColumnReport.merge(deadColumns)
+	}
+	if report != nil {
+		report.addTables(rowCounts, deadColumns)
+	}
 
 	for resourceName, table := range resource.Provider.ResourceMap {
+		checkCanceled(ctx, t, "verifying resources")
+		if !selected[resourceName] {
+			t.Logf("resource %s isn't selected by the config or tags, not verifying", resourceName)
+			continue
+		}
+		if sender.Skipped[resourceName] {
+			t.Logf("resource %s was skipped, not verifying", resourceName)
+			continue
+		}
+		if resource.CheckUniqueCQIDs {
+			UniqueCQIDsVerifier()(t, table, querier, resource.SkipIgnoreInTest)
+		}
+		if len(resource.ExpectClients) > 0 {
+			verifyClientCoverage(t, &resource, table, querier)
+		}
+		verifyEncryptedColumns(t, table, querier)
+		if resource.RowSnapshotDir != "" {
+			RowSnapshotVerifier(filepath.Join(resource.RowSnapshotDir, resourceName+".json"), resource.SnapshotMasker...)(t, table, querier, resource.SkipIgnoreInTest)
+		}
 		if verifiers, ok := resource.Verifiers[resourceName]; ok {
 			for _, verifier := range verifiers {
-				verifier(t, table, conn, resource.SkipIgnoreInTest)
+				verifier(t, table, querier, resource.SkipIgnoreInTest)
+			}
+		} else if !sender.FetchedResources[resourceName] {
+			// report resources that were never fetched, rather than every column of their empty tables being nil
+			if resource.SkipIgnoreInTest || !table.IgnoreInTests {
+				t.Errorf("resource %s didn't fetch any items", resourceName)
 			}
 		} else {
 			// fallback to default verification
-			verifyNoEmptyColumns(t, table, conn, resource.SkipIgnoreInTest)
+			verifyNoEmptyColumns(t, table, querier, resource.SkipIgnoreInTest, resource.HeavyColumns, resource.NonEmptyArrayColumns, nil, resource.VerifyMaxDepth)
+		}
+	}
+
+	if tx != nil {
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
 		}
 	}
 }
 
-// fetch - fetches resources from the cloud and puts them into database. database config can be specified via DATABASE_URL env variable
-func fetch(t *testing.T, resource *ResourceTestCase) error {
+// TestSingleResource is like TestResource, but creates the tables of, fetches and verifies only the named resource of
+// the provider, its table and relations, ignoring all others. ExpectSkipped entries of other resources are ignored.
+func TestSingleResource(t *testing.T, resource ResourceTestCase, name string) {
 	t.Helper()
-	resourceNames := make([]string, 0, len(resource.Provider.ResourceMap))
-	for name, table := range resource.Provider.ResourceMap {
-		if !resource.SkipIgnoreInTest && table.IgnoreInTests {
+	table, ok := resource.Provider.ResourceMap[name]
+	if !ok {
+		t.Fatalf("resource %s doesn't exist in provider %s", name, resource.Provider.Name)
+	}
+	// shallow copy the provider, so tests of other resources sharing it aren't affected
+	p := *resource.Provider
+	p.ResourceMap = map[string]*schema.Table{name: table}
+	resource.Provider = &p
+	if funk.ContainsString(resource.ExpectSkipped, name) {
+		resource.ExpectSkipped = []string{name}
+	} else {
+		resource.ExpectSkipped = nil
+	}
+	TestResource(t, resource)
+}
+
+// beginSnapshot begins a read only repeatable read transaction, all its queries see the same snapshot of the database
+func beginSnapshot(ctx context.Context, db execution.TXer) (execution.TXQueryExecer, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Exec(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// fetchRemote fetches the requested resources from a provider over gRPC, passing every response received to sender
+func fetchRemote(ctx context.Context, remote cqproto.CQProvider, request *cqproto.FetchResourcesRequest, sender *testResourceSender) error {
+	stream, err := remote.FetchResources(ctx, request)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sender.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// configure configures the provider, or the RemoteProvider if set, with the test case's config
+func configure(ctx context.Context, resource *ResourceTestCase, dbURL string) error {
+	config, err := resource.providerConfig()
+	if err != nil {
+		return err
+	}
+	if resource.ClientFactory != nil {
+		// shallow copy the provider, so tests sharing it without a ClientFactory aren't affected
+		p := *resource.Provider
+		p.Configure = func(_ hclog.Logger, config interface{}) (schema.ClientMeta, diag.Diagnostics) {
+			return resource.ClientFactory(ctx, config)
+		}
+		resource.Provider = &p
+	}
+	configureRequest := &cqproto.ConfigureProviderRequest{
+		CloudQueryVersion: "",
+		Connection:        cqproto.ConnectionDetails{DSN: dbURL},
+		Config:            config,
+	}
+	var configureProvider = resource.Provider.ConfigureProvider
+	if resource.RemoteProvider != nil {
+		configureProvider = resource.RemoteProvider.ConfigureProvider
+	}
+	if resp, err := configureProvider(ctx, configureRequest); err != nil {
+		return err
+	} else if resp != nil && resp.Diagnostics.HasErrors() {
+		return resp.Diagnostics
+	}
+	if resource.RemoteProvider != nil {
+		remoteConfig, diags := resource.Provider.DecodeConfig(config)
+		if diags.HasErrors() {
+			return diags
+		}
+		resource.remoteConfig = remoteConfig
+	}
+	return nil
+}
+
+// fetch - fetches resources from the cloud and puts them into database given by dbURL
+func fetch(ctx context.Context, t *testing.T, resource *ResourceTestCase, dbURL string) (*testResourceSender, error) {
+	t.Helper()
+	// the provider must be configured already, so the resources deselected by the config aren't fetched
+	selected := resource.selectedResources()
+	resourceNames := make([]string, 0, len(selected))
+	for _, name := range selected {
+		if table := resource.Provider.ResourceMap[name]; !resource.SkipIgnoreInTest && table.IgnoreInTests {
 			t.Logf("skipping resource: %s in tests", name)
 			continue
 		}
@@ -111,39 +690,175 @@ func fetch(t *testing.T, resource *ResourceTestCase) error {
 
 	t.Logf("fetch resources %v", resourceNames)
 
-	if resp, err := resource.Provider.ConfigureProvider(context.Background(), &cqproto.ConfigureProviderRequest{
-		CloudQueryVersion: "",
-		Connection: cqproto.ConnectionDetails{DSN: getEnv("DATABASE_URL",
-			"host=localhost user=postgres password=pass DB.name=postgres port=5432")},
-		Config: []byte(resource.Config),
-	}); err != nil {
-		return err
-	} else if resp != nil && resp.Diagnostics.HasErrors() {
-		return resp.Diagnostics
+	if resource.OnSQL != nil || resource.slowQueries != nil {
+		resource.Provider.SetStorageCreator(func(ctx context.Context, logger hclog.Logger, dbURL string) (execution.Storage, error) {
+			opts := []database.Option{database.WithApplicationName(resource.Provider.ApplicationName())}
+			if resource.OnSQL != nil {
+				// the pool reports the statements built by the storage itself too, such as the ones of Insert and Delete
+				opts = append(opts, database.WithQueryObserver(resource.OnSQL))
+			}
+			db, err := database.New(ctx, logger, dbURL, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return timeStorage(db, resource.slowQueries), nil
+		})
 	}
 
-	var resourceSender = &testResourceSender{
-		Errors: []string{},
+	// fixture middleware is innermost, so ResolverMiddleware still wraps the recorded or replayed resolver
+	middleware := resource.ResolverMiddleware
+	var recorder *fixtureStore
+	switch {
+	case resource.RecordFixturesDir != "" && resource.ReplayFixturesDir != "":
+		return nil, errors.New("only one of RecordFixturesDir and ReplayFixturesDir may be set")
+	case resource.RecordFixturesDir != "":
+		recorder = newFixtureStore(resource.RecordFixturesDir, resource.FixtureItems)
+		middleware = append(append([]schema.ResolverMiddleware{}, middleware...), recorder.Record())
+	case resource.ReplayFixturesDir != "":
+		middleware = append(append([]schema.ResolverMiddleware{}, middleware...), newFixtureStore(resource.ReplayFixturesDir, resource.FixtureItems).Replay())
 	}
 
-	if err := resource.Provider.FetchResources(context.Background(),
-		&cqproto.FetchResourcesRequest{
-			Resources:             resourceNames,
-			ParallelFetchingLimit: resource.ParallelFetchingLimit,
-		},
-		resourceSender,
-	); err != nil {
-		return err
+	if resource.Clock != nil {
+		providerClock := resource.Provider.Clock
+		resource.Provider.Clock = resource.Clock
+		defer func() { resource.Provider.Clock = providerClock }()
 	}
 
+	if resource.RecoverPanics != nil {
+		providerRecovery := resource.Provider.DisablePanicRecovery
+		resource.Provider.DisablePanicRecovery = !*resource.RecoverPanics
+		defer func() { resource.Provider.DisablePanicRecovery = providerRecovery }()
+	}
+
+	if len(middleware) > 0 {
+		providerMiddleware := resource.Provider.ResolverMiddleware
+		resource.Provider.ResolverMiddleware = append(append([]schema.ResolverMiddleware{}, providerMiddleware...), middleware...)
+		defer func() { resource.Provider.ResolverMiddleware = providerMiddleware }()
+	}
+
+	resourceSender := newTestResourceSender(resource.MaxErrors)
+	resourceSender.strict = resource.StrictNoDiagnostics
+	resourceSender.expectedColumns = resource.ExpectColumnErrors
+	for _, pattern := range resource.ExpectDiagnosticMatches {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected diagnostic pattern %q: %w", pattern, err)
+		}
+		resourceSender.expected = append(resourceSender.expected, re)
+	}
+
+	fetchRequest := &cqproto.FetchResourcesRequest{
+		Resources:                 resourceNames,
+		ParallelFetchingLimit:     resource.ParallelFetchingLimit,
+		StopOnError:               resource.StopOnError,
+		MaxItemsPerResource:       resource.MaxItemsPerResource,
+		MaxTotalRows:              resource.MaxTotalRows,
+		MaxConnectionsPerResource: resource.MaxConnectionsPerResource,
+	}
+	var err error
+	if resource.RemoteProvider != nil {
+		err = fetchRemote(ctx, resource.RemoteProvider, fetchRequest, resourceSender)
+	} else {
+		fetchCtx := execution.WithConcurrencyTracker(execution.WithCache(ctx, resourceSender.cache), resourceSender.concurrency)
+		err = resource.Provider.FetchResources(fetchCtx, fetchRequest, resourceSender)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recorder != nil {
+		if err := recorder.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	if resource.StopOnError {
+		var completed, canceled []string
+		for name, status := range resourceSender.Statuses {
+			if status == cqproto.ResourceFetchCanceled {
+				canceled = append(canceled, name)
+			} else {
+				completed = append(completed, name)
+			}
+		}
+		sort.Strings(completed)
+		sort.Strings(canceled)
+		t.Logf("completed resources: %v, canceled resources: %v", completed, canceled)
+	}
+
+	if resourceSender.truncatedErrors > 0 {
+		resourceSender.Errors = append(resourceSender.Errors, fetchError{Summary: fmt.Sprintf("truncated %d more errors", resourceSender.truncatedErrors)})
+	}
 	if len(resourceSender.Errors) > 0 {
-		return fmt.Errorf("error/s occur during test, %s", strings.Join(resourceSender.Errors, ", "))
+		return nil, fmt.Errorf("error/s occur during test, %s", formatErrors(resourceSender.Errors, resource.ErrorFormat))
 	}
 
-	return nil
+	return resourceSender, nil
+}
+
+// verifyFetch verifies the skipped resources and diagnostics reported by a fetch were expected, see ExpectSkipped and
+// ExpectDiagnosticMatches
+func verifyFetch(t *testing.T, resource *ResourceTestCase, sender *testResourceSender) {
+	t.Helper()
+	if len(sender.Errors) > 0 {
+		t.Fatalf("error/s occur during test, %s", formatErrors(sender.Errors, resource.ErrorFormat))
+	}
+	verifySkipped(t, resource.ExpectSkipped, sender.Skipped)
+	verifyDiagnosticMatches(t, sender.expected, sender.Diagnostics)
+	for _, e := range sender.ColumnErrors {
+		t.Logf("expected column resolver error, column set to NULL: %s", e)
+	}
+}
+
+// verifySkipped verifies exactly the expected resources were skipped by their table Condition
+func verifySkipped(t *testing.T, expected []string, skipped map[string]bool) {
+	t.Helper()
+	for _, name := range expected {
+		if !skipped[name] {
+			t.Errorf("expected resource %s to be skipped, but it was fetched", name)
+		}
+	}
+	for name := range skipped {
+		if !funk.ContainsString(expected, name) {
+			t.Errorf("resource %s was skipped unexpectedly", name)
+		}
+	}
 }
 
-func verifyNoEmptyColumns(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool) {
+// verifyDiagnosticMatches verifies each of the expected patterns matches the summary or detail of at least one diagnostic
+func verifyDiagnosticMatches(t *testing.T, expected []*regexp.Regexp, diags diag.Diagnostics) {
+	t.Helper()
+	for _, re := range unmatchedPatterns(expected, diags) {
+		t.Errorf("expected a diagnostic matching %q, but none was reported", re)
+	}
+}
+
+// unmatchedPatterns returns the expected patterns matching none of diags
+func unmatchedPatterns(expected []*regexp.Regexp, diags diag.Diagnostics) []*regexp.Regexp {
+	var unmatched []*regexp.Regexp
+	for _, re := range expected {
+		found := false
+		for _, d := range diags {
+			if diagnosticMatches(re, d) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, re)
+		}
+	}
+	return unmatched
+}
+
+func diagnosticMatches(re *regexp.Regexp, d diag.Diagnostic) bool {
+	return re.MatchString(d.Description().Summary) || re.MatchString(d.Description().Detail)
+}
+
+// verifyNoEmptyColumns verifies every column of table and its relations has at least one non nil value. If filter isn't
+// nil only the rows of table matching it are verified, and of its relations only the rows descending from them.
+// If maxDepth is positive only that many levels of the table tree are verified, table itself being the first.
+// Columns in nonEmptyArrays additionally need at least one non-empty array.
+func verifyNoEmptyColumns(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool, heavyColumns, nonEmptyArrays []string, filter sq.Sqlizer, maxDepth int) {
 	t.Helper()
 	t.Run(table.Name, func(t *testing.T) {
 		t.Helper()
@@ -151,10 +866,25 @@ func verifyNoEmptyColumns(t *testing.T, table *schema.Table, conn pgxscan.Querie
 		if !shouldSkipIgnoreInTest && table.IgnoreInTests {
 			t.Skipf("table %s marked as IgnoreInTest. Skipping...", table.Name)
 		}
+		var heavy, light []string
+		for _, c := range (schema.PostgresDialect{}).Columns(table) {
+			if funk.ContainsString(heavyColumns, c.Name) {
+				heavy = append(heavy, c.Name)
+			} else {
+				light = append(light, c.Name)
+			}
+		}
 		s := sq.StatementBuilder.
 			PlaceholderFormat(sq.Dollar).
 			Select(fmt.Sprintf("json_agg(%s)", table.Name)).
-			From(table.Name)
+			From(table.Name).
+			Where(filter)
+		if len(heavy) > 0 {
+			s = sq.StatementBuilder.
+				PlaceholderFormat(sq.Dollar).
+				Select("json_agg(light)").
+				FromSelect(sq.Select(quoteIdentifiers(light)...).From(strconv.Quote(table.Name)).Where(filter), "light")
+		}
 		query, args, err := s.ToSql()
 		if err != nil {
 			t.Fatal(err)
@@ -168,50 +898,179 @@ func verifyNoEmptyColumns(t *testing.T, table *schema.Table, conn pgxscan.Querie
 			t.Errorf("expected to have at least 1 entry at table %s got zero", table.Name)
 			return
 		}
-
-		nilColumns := map[string]bool{}
-		// mark all columns as nil
-		for _, c := range table.Columns {
-			if shouldSkipIgnoreInTest || !c.IgnoreInTests {
-				nilColumns[c.Name] = true
-			}
-		}
-
 		for _, row := range data {
-			for c, v := range row {
-				if v != nil {
-					// as long as we had one row or result with this column not nil it means the resolver worked
-					nilColumns[c] = false
-				}
+			if err := decryptRow(table, row); err != nil {
+				t.Fatal(err)
 			}
 		}
 
-		var nilColumnsArr []string
-		for c, v := range nilColumns {
-			if v {
-				nilColumnsArr = append(nilColumnsArr, c)
+		populatedHeavy := make(map[string]bool, len(heavy))
+		for _, c := range heavy {
+			condition := fmt.Sprintf("%s IS NOT NULL", strconv.Quote(c))
+			if funk.ContainsString(nonEmptyArrays, c) {
+				condition = fmt.Sprintf("cardinality(%s) > 0", strconv.Quote(c))
 			}
+			query, args, err := sq.StatementBuilder.
+				PlaceholderFormat(sq.Dollar).
+				Select(fmt.Sprintf("count(*) FILTER (WHERE %s)", condition)).
+				From(strconv.Quote(table.Name)).
+				Where(filter).
+				ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var count int64
+			if err := pgxscan.Get(context.Background(), conn, &count, query, args...); err != nil {
+				t.Fatal(err)
+			}
+			populatedHeavy[c] = count > 0
 		}
 
-		if len(nilColumnsArr) != 0 {
+		if nilColumnsArr := emptyColumns(table, data, populatedHeavy, shouldSkipIgnoreInTest, nonEmptyArrays); len(nilColumnsArr) != 0 {
 			t.Errorf("found nil column in table %s. columns=%s", table.Name, strings.Join(nilColumnsArr, ","))
 		}
+		if maxDepth == 1 {
+			if len(table.Relations) > 0 {
+				t.Logf("not verifying relations of table %s, they exceed the max verification depth", table.Name)
+			}
+			return
+		}
 		for _, childTable := range table.Relations {
-			verifyNoEmptyColumns(t, childTable, conn, shouldSkipIgnoreInTest)
+			verifyNoEmptyColumns(t, childTable, conn, shouldSkipIgnoreInTest, heavyColumns, nonEmptyArrays, relationFilter(table, childTable, filter), maxDepth-1)
 		}
 	})
 }
 
-func dropAndCreateTable(ctx context.Context, conn execution.QueryExecer, table *schema.Table) error {
-	ups, err := migration.CreateTableDefinitions(ctx, schema.PostgresDialect{}, table, nil)
+// emptyColumns returns the sorted columns of table without a value in any of rows, nor populated according to
+// populatedHeavy, which holds whether each heavy column left out of rows has a value. Columns with IgnoreInTests are
+// left out unless shouldSkipIgnoreInTest.
+func emptyColumns(table *schema.Table, rows []map[string]interface{}, populatedHeavy map[string]bool, shouldSkipIgnoreInTest bool, nonEmptyArrays []string) []string {
+	nilColumns := map[string]bool{}
+	// mark all columns as nil
+	for _, c := range table.Columns {
+		if shouldSkipIgnoreInTest || !c.IgnoreInTests {
+			nilColumns[c.Name] = true
+		}
+	}
+
+	for _, row := range rows {
+		for c, v := range row {
+			if isEmptyColumnValue(c, v, nonEmptyArrays) {
+				continue
+			}
+			if v != nil {
+				// as long as we had one row or result with this column not nil it means the resolver worked
+				nilColumns[c] = false
+			}
+		}
+	}
+	for c, populated := range populatedHeavy {
+		if populated {
+			nilColumns[c] = false
+		}
+	}
+
+	var empty []string
+	for c, v := range nilColumns {
+		if v {
+			empty = append(empty, c)
+		}
+	}
+	sort.Strings(empty)
+	return empty
+}
+
+// isEmptyColumnValue returns whether v, the JSON decoded value of column c, is an empty array of a column in nonEmptyArrays
+func isEmptyColumnValue(c string, v interface{}, nonEmptyArrays []string) bool {
+	if !funk.ContainsString(nonEmptyArrays, c) {
+		return false
+	}
+	a, ok := v.([]interface{})
+	return ok && len(a) == 0
+}
+
+// verifyClientCoverage verifies every one of ExpectClients has rows in table, if it declares the ClientColumn. Tables
+// ignored in tests aren't verified, nor are tables without the column, which usually aren't multiplexed.
+func verifyClientCoverage(t *testing.T, resource *ResourceTestCase, table *schema.Table, conn pgxscan.Querier) {
+	t.Helper()
+	column := resource.ClientColumn
+	if column == "" {
+		column = "account_id"
+	}
+	if table.Column(column) == nil {
+		t.Logf("table %s has no column %s, not verifying its clients", table.Name, column)
+		return
+	}
+	if table.IgnoreInTests && !resource.SkipIgnoreInTest {
+		return
+	}
+	ClientCoverageVerifier(column, resource.ExpectClients...)(t, table, conn, resource.SkipIgnoreInTest)
+}
+
+// verifyEncryptedColumns verifies the stored values of the columns of table and its relations with Encrypt set are
+// ciphertext, which Decrypt turns back into a different plaintext. Values are never logged, as they may be secrets.
+func verifyEncryptedColumns(t *testing.T, table *schema.Table, conn pgxscan.Querier) {
+	t.Helper()
+	for _, c := range table.Columns {
+		if c.Encrypt == nil {
+			continue
+		}
+		var stored [][]byte
+		query := fmt.Sprintf("SELECT %[1]s FROM %[2]s WHERE %[1]s IS NOT NULL", strconv.Quote(c.Name), strconv.Quote(table.Name))
+		if err := pgxscan.Select(context.Background(), conn, &stored, query); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkEncryptedValues(c, stored); err != nil {
+			t.Errorf("table %s: %s", table.Name, err)
+		}
+	}
+	for _, rel := range table.Relations {
+		verifyEncryptedColumns(t, rel, conn)
+	}
+}
+
+// checkEncryptedValues returns an error if any of the stored values of the encrypted column c isn't decryptable or
+// is the same as its plaintext
+func checkEncryptedValues(c schema.Column, stored [][]byte) error {
+	if c.Decrypt == nil {
+		return fmt.Errorf("column %s has Encrypt set without Decrypt, its stored values can't be verified", c.Name)
+	}
+	for _, ciphertext := range stored {
+		plaintext, err := c.Decrypt(ciphertext)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt a stored value of column %s: %w", c.Name, err)
+		}
+		if bytes.Equal(plaintext, ciphertext) {
+			return fmt.Errorf("column %s is stored as plaintext, its Encrypt function doesn't encrypt", c.Name)
+		}
+	}
+	return nil
+}
+
+// testDialect returns the dialect named by TestDialectEnv, defaulting to postgres
+func testDialect() (schema.Dialect, error) {
+	return schema.GetDialect(schema.DialectType(getEnv(TestDialectEnv, string(schema.Postgres))))
+}
+
+// dropAndCreateTables drops all tables before creating them, so tables referencing each other are created in order.
+// The tables are created by the dialect named by TestDialectEnv, defaulting to postgres, with the types of overrides
+// replacing its type mapping, see ResourceTestCase TypeOverrides.
+func dropAndCreateTables(ctx context.Context, conn execution.QueryExecer, dbSchema string, tables []*schema.Table, overrides map[schema.ValueType]string) error {
+	dialect, err := testDialect()
 	if err != nil {
 		return err
 	}
-
-	if err := dropTables(ctx, conn, table); err != nil {
+	ups, err := migration.CreateTablesDefinitions(ctx, withTypeOverrides(dialect, overrides), tables)
+	if err != nil {
 		return err
 	}
 
+	for _, table := range tables {
+		if err := dropTables(ctx, conn, dbSchema, table); err != nil {
+			return err
+		}
+	}
+
 	for _, sql := range ups {
 		if err := conn.Exec(ctx, sql); err != nil {
 			return err
@@ -221,39 +1080,192 @@ func dropAndCreateTable(ctx context.Context, conn execution.QueryExecer, table *
 	return nil
 }
 
-func dropTables(ctx context.Context, db execution.QueryExecer, table *schema.Table) error {
-	if err := db.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", strconv.Quote(table.Name))); err != nil {
+// providerTables returns the top level tables of the provider's resources
+func providerTables(p *provider.Provider) []*schema.Table {
+	tables := make([]*schema.Table, 0, len(p.ResourceMap))
+	for _, table := range p.ResourceMap {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// dropTables drops the table and its relations, qualified by dbSchema if given so tables of other schemas in the
+// search_path aren't dropped instead
+func dropTables(ctx context.Context, db execution.QueryExecer, dbSchema string, table *schema.Table) error {
+	if err := db.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", qualifiedName(dbSchema, table.Name))); err != nil {
 		return err
 	}
+	for _, c := range table.Columns {
+		if c.Type != schema.TypeEnum {
+			continue
+		}
+		if err := db.Exec(ctx, fmt.Sprintf("DROP TYPE IF EXISTS %s CASCADE", qualifiedName(dbSchema, schema.EnumTypeName(table, c)))); err != nil {
+			return err
+		}
+	}
 	for _, rel := range table.Relations {
-		if err := dropTables(ctx, db, rel); err != nil {
+		if err := dropTables(ctx, db, dbSchema, rel); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// qualifiedName quotes name, qualifying it with dbSchema if given
+func qualifiedName(dbSchema, name string) string {
+	if dbSchema == "" {
+		return strconv.Quote(name)
+	}
+	return strconv.Quote(dbSchema) + "." + strconv.Quote(name)
+}
+
+// relationFilter scopes the rows of relation to those whose parent matches the parent filter, it returns nil if filter is nil
+func relationFilter(parent, relation *schema.Table, filter sq.Sqlizer) sq.Sqlizer {
+	if filter == nil {
+		return nil
+	}
+	pc := schema.FindParentIdColumn(relation)
+	if pc == nil {
+		return filter
+	}
+	return sq.Expr(fmt.Sprintf("%s IN (?)", strconv.Quote(pc.Name)), sq.Select("cq_id").From(strconv.Quote(parent.Name)).Where(filter))
+}
+
 func (f *testResourceSender) Send(r *cqproto.FetchResourcesResponse) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.summary.add(r, time.Since(f.started))
+	f.Statuses[r.ResourceName] = r.Summary.Status
+	if r.Summary.ResourceCount > 0 {
+		f.FetchedResources[r.ResourceName] = true
+	}
 	if r.Error != "" {
 		fmt.Printf(r.Error)
-		f.Errors = append(f.Errors, r.Error)
+		f.addError(fetchError{Resource: r.ResourceName, Severity: diag.ERROR.String(), Summary: r.Error})
 	}
+	f.Diagnostics = append(f.Diagnostics, r.Summary.Diagnostics...)
 	for _, d := range r.Summary.Diagnostics {
-		if d.Severity() != diag.IGNORE {
-			f.Errors = append(f.Errors, fmt.Sprintf("resource: %s. summary: %s, details %s", d.Description().Resource, d.Description().Summary, d.Description().Detail))
+		// relations skipped by their condition report the same error, but only after their parent fetched some resources
+		if errors.Is(d, execution.ErrConditionNotMet) && r.Summary.ResourceCount == 0 {
+			f.Skipped[r.ResourceName] = true
+		}
+		var colErr execution.ColumnResolveError
+		if errors.As(d, &colErr) {
+			column := colErr.Table + "@" + colErr.Column
+			if id := d.Description().ResourceID; len(id) > 0 {
+				column += fmt.Sprintf(" (id: %s)", strings.Join(id, ","))
+			}
+			f.ColumnErrors = append(f.ColumnErrors, fmt.Sprintf("%s: %s", column, colErr.Err))
+			if !f.strict && funk.ContainsString(f.expectedColumns, colErr.Table+"."+colErr.Column) {
+				continue
+			}
+		}
+		if (f.strict || d.Severity() != diag.IGNORE) && !f.isExpected(d) {
+			f.addError(newFetchError(d))
 		}
 	}
 	return nil
 }
 
-func setupDatabase() (execution.QueryExecer, error) {
-	dbConnOnce.Do(func() {
-		pool, dbErr = database.New(context.Background(), hclog.NewNullLogger(), getEnv("DATABASE_URL", "host=localhost user=postgres password=pass DB.name=postgres port=5432"))
-		if dbErr != nil {
-			return
+// addError collects the error, unless maxErrors were already collected in which case it's only counted
+func (f *testResourceSender) addError(err fetchError) {
+	if len(f.Errors) >= f.maxErrors {
+		f.truncatedErrors++
+		return
+	}
+	f.Errors = append(f.Errors, err)
+}
+
+// isExpected returns true if diagnostic d matches any of ExpectDiagnosticMatches
+func (f *testResourceSender) isExpected(d diag.Diagnostic) bool {
+	for _, re := range f.expected {
+		if diagnosticMatches(re, d) {
+			return true
 		}
+	}
+	return false
+}
+
+// selectedResources returns the sorted names of the resources selected by the provider's config, see selectedByConfig,
+// whose table passes IncludeTags and ExcludeTags
+func (r ResourceTestCase) selectedResources() []string {
+	return funk.FilterString(r.selectedByConfig(), func(name string) bool {
+		tags := r.Provider.ResourceMap[name].Tags
+		if len(r.IncludeTags) > 0 && len(funk.IntersectString(r.IncludeTags, tags)) == 0 {
+			return false
+		}
+		return len(funk.IntersectString(r.ExcludeTags, tags)) == 0
 	})
-	return pool, dbErr
+}
+
+// inProcessOptions returns the names of the options set on the test case which don't apply to a RemoteProvider, see
+// ResourceTestCase RemoteProvider
+func (r ResourceTestCase) inProcessOptions() []string {
+	var options []string
+	for name, set := range map[string]bool{
+		"OnSQL":               r.OnSQL != nil,
+		"SlowQueryThreshold":  r.SlowQueryThreshold > 0,
+		"ResolverMiddleware":  len(r.ResolverMiddleware) > 0,
+		"Clock":               r.Clock != nil,
+		"RecoverPanics":       r.RecoverPanics != nil,
+		"ClientFactory":       r.ClientFactory != nil,
+		"RecordFixturesDir":   r.RecordFixturesDir != "",
+		"ReplayFixturesDir":   r.ReplayFixturesDir != "",
+		"AssertDeterministic": r.AssertDeterministic,
+		"ExpectSkipped":       len(r.ExpectSkipped) > 0,
+		"ExpectColumnErrors":  len(r.ExpectColumnErrors) > 0,
+	} {
+		if set {
+			options = append(options, name)
+		}
+	}
+	sort.Strings(options)
+	return options
+}
+
+// selectedByConfig returns the resources selected by the config, see provider.ResourceSelector. The config of a
+// RemoteProvider is decoded by Provider as well, as it's never configured itself.
+func (r ResourceTestCase) selectedByConfig() []string {
+	if r.RemoteProvider != nil {
+		return r.Provider.SelectResources(r.remoteConfig)
+	}
+	return r.Provider.SelectedResources()
+}
+
+// databaseURL returns the DSN to test with, DatabaseURL or the DATABASE_URL env variable if not set.
+// If DBSchema is set the search_path of the DSN is set to it, so unqualified table names resolve to it.
+func (r ResourceTestCase) databaseURL() (string, error) {
+	dbURL := r.DatabaseURL
+	if dbURL == "" {
+		dbURL = getEnv("DATABASE_URL", defaultDatabaseURL)
+	}
+	if r.DBSchema == "" {
+		return dbURL, nil
+	}
+	return dsn.SetDSNElement(dbURL, map[string]string{"search_path": r.DBSchema + ",public"})
+}
+
+// dbSchemaName returns the postgres schema the tables are created in
+func dbSchemaName(dbSchema string) string {
+	if dbSchema == "" {
+		return "public"
+	}
+	return dbSchema
+}
+
+// setupDatabase returns a connection pool to dbURL, pools are shared by all tests using the same dbURL
+func setupDatabase(dbURL string) (execution.Storage, error) {
+	poolsLock.Lock()
+	defer poolsLock.Unlock()
+	if p, ok := pools[dbURL]; ok {
+		return p, nil
+	}
+	p, err := database.New(context.Background(), hclog.NewNullLogger(), dbURL, database.WithApplicationName(testApplicationName))
+	if err != nil {
+		return nil, err
+	}
+	pools[dbURL] = p
+	return p, nil
 }
 
 func getEnv(key, fallback string) string {
@@ -0,0 +1,204 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/database/postgres"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// maxVerifyRetries is the number of times a verifier query failing on a serialization failure is retried
+	maxVerifyRetries = 3
+	// verifyRetryBackoff is multiplied by the attempt number to get the delay before each retry
+	verifyRetryBackoff = 50 * time.Millisecond
+	// verifySavepoint is the savepoint a query is rolled back to before being retried in a transaction
+	verifySavepoint = "cq_verify_retry"
+)
+
+// retryingQueryExecer wraps the execution.QueryExecer of the verifiers, retrying Exec and Query calls failing with a
+// serialization failure (SQLSTATE 40001) up to maxVerifyRetries times. Query reads all rows before returning them, as
+// the failure may only be reported while reading. In a transaction every call is preceded by a savepoint the failed
+// call is rolled back to, otherwise the failure would abort the transaction.
+type retryingQueryExecer struct {
+	execution.QueryExecer
+	inTx     bool
+	connInfo *pgtype.ConnInfo
+	retries  *int64
+}
+
+func newRetryingQueryExecer(conn execution.QueryExecer, inTx bool) *retryingQueryExecer {
+	connInfo := pgtype.NewConnInfo()
+	connInfo.RegisterDataType(pgtype.DataType{Value: &postgres.UUID{}, Name: "uuid", OID: pgtype.UUIDOID})
+	return &retryingQueryExecer{QueryExecer: conn, inTx: inTx, connInfo: connInfo, retries: new(int64)}
+}
+
+// Retries returns the number of retries done so far
+func (r *retryingQueryExecer) Retries() int64 {
+	return atomic.LoadInt64(r.retries)
+}
+
+func (r *retryingQueryExecer) Exec(ctx context.Context, query string, args ...interface{}) error {
+	return r.retry(ctx, func() error {
+		return r.QueryExecer.Exec(ctx, query, args...)
+	})
+}
+
+func (r *retryingQueryExecer) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	var buffered *bufferedRows
+	err := r.retry(ctx, func() error {
+		rows, err := r.QueryExecer.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		buffered, err = bufferRows(rows, r.connInfo)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buffered, nil
+}
+
+// retry calls fn until it doesn't fail with a serialization failure or maxVerifyRetries is reached
+func (r *retryingQueryExecer) retry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if r.inTx {
+			if err := r.QueryExecer.Exec(ctx, "SAVEPOINT "+verifySavepoint); err != nil {
+				return err
+			}
+		}
+		err := fn()
+		if err == nil || !isSerializationFailure(err) || attempt == maxVerifyRetries {
+			if r.inTx && err == nil {
+				return r.QueryExecer.Exec(ctx, "RELEASE SAVEPOINT "+verifySavepoint)
+			}
+			return err
+		}
+		if r.inTx {
+			if err := r.QueryExecer.Exec(ctx, "ROLLBACK TO SAVEPOINT "+verifySavepoint); err != nil {
+				return err
+			}
+		}
+		atomic.AddInt64(r.retries, 1)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * verifyRetryBackoff):
+		}
+	}
+}
+
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.SerializationFailure
+}
+
+// bufferedRows are pgx.Rows read in full, decoded using connInfo
+type bufferedRows struct {
+	connInfo *pgtype.ConnInfo
+	fields   []pgproto3.FieldDescription
+	tag      pgconn.CommandTag
+	values   [][][]byte
+	current  int
+}
+
+// bufferRows reads all of rows and closes them
+func bufferRows(rows pgx.Rows, connInfo *pgtype.ConnInfo) (*bufferedRows, error) {
+	buffered := &bufferedRows{connInfo: connInfo, fields: rows.FieldDescriptions(), current: -1}
+	for rows.Next() {
+		raw := rows.RawValues()
+		row := make([][]byte, len(raw))
+		for i, v := range raw {
+			if v != nil {
+				row[i] = append([]byte{}, v...)
+			}
+		}
+		buffered.values = append(buffered.values, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	buffered.tag = rows.CommandTag()
+	return buffered, nil
+}
+
+func (b *bufferedRows) Close() {
+	b.current = len(b.values)
+}
+
+func (b *bufferedRows) Err() error { return nil }
+
+func (b *bufferedRows) CommandTag() pgconn.CommandTag { return b.tag }
+
+func (b *bufferedRows) FieldDescriptions() []pgproto3.FieldDescription { return b.fields }
+
+func (b *bufferedRows) Next() bool {
+	if b.current < len(b.values) {
+		b.current++
+	}
+	return b.current < len(b.values)
+}
+
+func (b *bufferedRows) Scan(dest ...interface{}) error {
+	return pgx.ScanRow(b.connInfo, b.fields, b.RawValues(), dest...)
+}
+
+func (b *bufferedRows) Values() ([]interface{}, error) {
+	raw := b.RawValues()
+	values := make([]interface{}, len(raw))
+	for i, buf := range raw {
+		if buf == nil {
+			continue
+		}
+		var value pgtype.Value
+		if dt, ok := b.connInfo.DataTypeForOID(b.fields[i].DataTypeOID); ok {
+			value = pgtype.NewValue(dt.Value)
+		}
+		v, err := decodeValue(b.connInfo, b.fields[i].Format, value, buf)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (b *bufferedRows) RawValues() [][]byte {
+	if b.current < 0 || b.current >= len(b.values) {
+		return nil
+	}
+	return b.values[b.current]
+}
+
+// decodeValue decodes buf of the given format using value, falling back to the generic decoders if value is nil or
+// can't decode the format
+func decodeValue(connInfo *pgtype.ConnInfo, format int16, value pgtype.Value, buf []byte) (interface{}, error) {
+	if format == pgx.BinaryFormatCode {
+		decoder, ok := value.(pgtype.BinaryDecoder)
+		if !ok {
+			decoder = &pgtype.GenericBinary{}
+		}
+		if err := decoder.DecodeBinary(connInfo, buf); err != nil {
+			return nil, err
+		}
+		return decoder.(pgtype.Value).Get(), nil
+	}
+	decoder, ok := value.(pgtype.TextDecoder)
+	if !ok {
+		decoder = &pgtype.GenericText{}
+	}
+	if err := decoder.DecodeText(connInfo, buf); err != nil {
+		return nil, err
+	}
+	return decoder.(pgtype.Value).Get(), nil
+}
@@ -0,0 +1,100 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRows read values, failing with err once read
+type failingRows struct {
+	pgx.Rows
+	values [][]byte
+	err    error
+	read   bool
+}
+
+func (r *failingRows) Close() {}
+
+func (r *failingRows) Err() error { return r.err }
+
+func (r *failingRows) CommandTag() pgconn.CommandTag { return pgconn.CommandTag("SELECT 1") }
+
+func (r *failingRows) FieldDescriptions() []pgproto3.FieldDescription {
+	return []pgproto3.FieldDescription{{Name: []byte("count"), DataTypeOID: pgtype.Int8OID, Format: pgx.TextFormatCode}}
+}
+
+func (r *failingRows) Next() bool {
+	if r.read || r.err != nil {
+		return false
+	}
+	r.read = true
+	return true
+}
+
+func (r *failingRows) RawValues() [][]byte { return r.values }
+
+// serializationFailingQueryExecer fails its first failures calls with a serialization failure, recording all statements
+type serializationFailingQueryExecer struct {
+	failures   int
+	statements []string
+}
+
+func (s *serializationFailingQueryExecer) Exec(_ context.Context, query string, _ ...interface{}) error {
+	s.statements = append(s.statements, query)
+	if query == "UPDATE t SET a = 1" {
+		return s.fail()
+	}
+	return nil
+}
+
+func (s *serializationFailingQueryExecer) Query(_ context.Context, query string, _ ...interface{}) (pgx.Rows, error) {
+	s.statements = append(s.statements, query)
+	// the failure is reported while reading the rows, as postgres does
+	return &failingRows{values: [][]byte{[]byte("42")}, err: s.fail()}, nil
+}
+
+func (s *serializationFailingQueryExecer) fail() error {
+	if s.failures == 0 {
+		return nil
+	}
+	s.failures--
+	return &pgconn.PgError{Code: pgerrcode.SerializationFailure, Message: "could not serialize access"}
+}
+
+func TestRetryingQueryExecer(t *testing.T) {
+	conn := &serializationFailingQueryExecer{failures: 2}
+	retrying := newRetryingQueryExecer(conn, true)
+
+	rows, err := retrying.Query(context.Background(), "SELECT count(*) FROM t")
+	require.NoError(t, err)
+	var counts []int64
+	for rows.Next() {
+		var count int64
+		require.NoError(t, rows.Scan(&count))
+		counts = append(counts, count)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int64{42}, counts)
+	assert.EqualValues(t, 2, retrying.Retries())
+	assert.Equal(t, []string{
+		"SAVEPOINT cq_verify_retry", "SELECT count(*) FROM t", "ROLLBACK TO SAVEPOINT cq_verify_retry",
+		"SAVEPOINT cq_verify_retry", "SELECT count(*) FROM t", "ROLLBACK TO SAVEPOINT cq_verify_retry",
+		"SAVEPOINT cq_verify_retry", "SELECT count(*) FROM t", "RELEASE SAVEPOINT cq_verify_retry",
+	}, conn.statements)
+
+	// retries are bounded
+	conn = &serializationFailingQueryExecer{failures: maxVerifyRetries + 1}
+	retrying = newRetryingQueryExecer(conn, false)
+	err = retrying.Exec(context.Background(), "UPDATE t SET a = 1")
+	assert.True(t, isSerializationFailure(err))
+	assert.EqualValues(t, maxVerifyRetries, retrying.Retries())
+	assert.Len(t, conn.statements, maxVerifyRetries+1)
+}
@@ -0,0 +1,153 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+)
+
+// UpdateSchemaSnapshotEnv if set to true, VerifySchemaSnapshot overwrites the snapshot instead of comparing to it
+const UpdateSchemaSnapshotEnv = "CQ_UPDATE_SCHEMA_SNAPSHOT"
+
+// SchemaSnapshot is the serializable resource/table/column tree of a provider, used to detect schema changes
+// between releases. Each resource maps to its top level table, relations are nested under their parent table.
+type SchemaSnapshot struct {
+	Name      string                   `json:"name"`
+	Resources map[string]TableSnapshot `json:"resources"`
+}
+
+// TableSnapshot is the serializable schema of a table and its relations
+type TableSnapshot struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	PrimaryKeys []string         `json:"primary_keys,omitempty"`
+	Columns     []ColumnSnapshot `json:"columns"`
+	Relations   []TableSnapshot  `json:"relations,omitempty"`
+}
+
+// ColumnSnapshot is the serializable schema of a column
+type ColumnSnapshot struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+}
+
+// NewSchemaSnapshot creates the SchemaSnapshot of the given provider
+func NewSchemaSnapshot(p *provider.Provider) SchemaSnapshot {
+	s := p.Schema()
+	resources := make(map[string]TableSnapshot, len(s.ResourceTables))
+	for name, table := range s.ResourceTables {
+		resources[name] = newTableSnapshot(table)
+	}
+	return SchemaSnapshot{Name: s.Name, Resources: resources}
+}
+
+func newTableSnapshot(table *schema.Table) TableSnapshot {
+	columns := make([]ColumnSnapshot, len(table.Columns))
+	for i, c := range table.Columns {
+		columns[i] = ColumnSnapshot{Name: c.Name, Type: c.Type.String(), Description: c.Description, Sensitive: c.Sensitive}
+	}
+	var relations []TableSnapshot
+	for _, rel := range table.Relations {
+		relations = append(relations, newTableSnapshot(rel))
+	}
+	return TableSnapshot{
+		Name:        table.Name,
+		Description: table.Description,
+		PrimaryKeys: table.Options.PrimaryKeys,
+		Columns:     columns,
+		Relations:   relations,
+	}
+}
+
+// VerifySchemaSnapshot compares the provider's schema to the JSON snapshot stored at path, failing if it changed.
+// The snapshot is written if it doesn't exist yet, or if UpdateSchemaSnapshotEnv is set to true.
+func VerifySchemaSnapshot(t *testing.T, p *provider.Provider, path string) {
+	t.Helper()
+	actual, err := json.MarshalIndent(NewSchemaSnapshot(p), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = append(actual, '\n')
+
+	update, _ := strconv.ParseBool(os.Getenv(UpdateSchemaSnapshotEnv))
+	expected, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || update {
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("schema snapshot written to %s", path)
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("provider schema changed compared to snapshot %s, set %s=true to update it.\nexpected:\n%s\nactual:\n%s", path, UpdateSchemaSnapshotEnv, expected, actual)
+	}
+}
+
+// AssertSchemaCompatible fails if the provider's schema isn't backwards compatible with oldSchemaJSON, a
+// SchemaSnapshot serialized from a prior release. Removing a resource, table or column, or changing a column's type,
+// is incompatible, while added ones are allowed. Note integer columns of any size share the TypeBigInt type.
+func AssertSchemaCompatible(t *testing.T, oldSchemaJSON []byte, newProvider *provider.Provider) {
+	t.Helper()
+	var old SchemaSnapshot
+	if err := json.Unmarshal(oldSchemaJSON, &old); err != nil {
+		t.Fatalf("failed to read old schema: %s", err)
+	}
+	current := NewSchemaSnapshot(newProvider)
+	var incompatible []string
+	for name, oldTable := range old.Resources {
+		table, ok := current.Resources[name]
+		if !ok {
+			incompatible = append(incompatible, fmt.Sprintf("resource %s was removed", name))
+			continue
+		}
+		incompatible = append(incompatible, tableIncompatibilities(oldTable, table)...)
+	}
+	if len(incompatible) > 0 {
+		sort.Strings(incompatible)
+		t.Errorf("provider schema isn't compatible with the old schema:\n%s", strings.Join(incompatible, "\n"))
+	}
+}
+
+// tableIncompatibilities lists the incompatible changes from old to table, recursing into their relations
+func tableIncompatibilities(old, table TableSnapshot) []string {
+	var incompatible []string
+	columns := make(map[string]ColumnSnapshot, len(table.Columns))
+	for _, c := range table.Columns {
+		columns[c.Name] = c
+	}
+	for _, oldColumn := range old.Columns {
+		c, ok := columns[oldColumn.Name]
+		if !ok {
+			incompatible = append(incompatible, fmt.Sprintf("table %s column %s was removed", old.Name, oldColumn.Name))
+		} else if c.Type != oldColumn.Type {
+			incompatible = append(incompatible, fmt.Sprintf("table %s column %s type changed from %s to %s", old.Name, oldColumn.Name, oldColumn.Type, c.Type))
+		}
+	}
+	relations := make(map[string]TableSnapshot, len(table.Relations))
+	for _, rel := range table.Relations {
+		relations[rel.Name] = rel
+	}
+	for _, oldRelation := range old.Relations {
+		rel, ok := relations[oldRelation.Name]
+		if !ok {
+			incompatible = append(incompatible, fmt.Sprintf("table %s relation %s was removed", old.Name, oldRelation.Name))
+			continue
+		}
+		incompatible = append(incompatible, tableIncompatibilities(oldRelation, rel)...)
+	}
+	return incompatible
+}
@@ -0,0 +1,32 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableIncompatibilities(t *testing.T) {
+	old := TableSnapshot{
+		Name:    "test_table",
+		Columns: []ColumnSnapshot{{Name: "id", Type: "TypeBigInt"}, {Name: "name", Type: "TypeString"}, {Name: "tags", Type: "TypeJSON"}},
+		Relations: []TableSnapshot{
+			{Name: "test_table_children", Columns: []ColumnSnapshot{{Name: "value", Type: "TypeString"}}},
+			{Name: "test_table_removed"},
+		},
+	}
+	current := TableSnapshot{
+		Name:    "test_table",
+		Columns: []ColumnSnapshot{{Name: "id", Type: "TypeBigInt"}, {Name: "name", Type: "TypeUUID"}, {Name: "added", Type: "TypeString"}},
+		Relations: []TableSnapshot{
+			{Name: "test_table_children", Columns: []ColumnSnapshot{{Name: "value", Type: "TypeString"}, {Name: "added", Type: "TypeBool"}}},
+			{Name: "test_table_added"},
+		},
+	}
+	assert.Equal(t, []string{
+		"table test_table column name type changed from TypeString to TypeUUID",
+		"table test_table column tags was removed",
+		"table test_table relation test_table_removed was removed",
+	}, tableIncompatibilities(old, current))
+	assert.Empty(t, tableIncompatibilities(old, old))
+}
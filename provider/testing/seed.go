@@ -0,0 +1,233 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/cloudquery/faker/v3"
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-hclog"
+	"github.com/thoas/go-funk"
+)
+
+// seedBatchSize is the amount of resources inserted by a single statement of SeedFakeData
+const seedBatchSize = 100
+
+// seedClient is the schema.ClientMeta the internal column resolvers of faked resources are called with
+type seedClient struct{}
+
+func (seedClient) Logger() hclog.Logger { return hclog.NewNullLogger() }
+
+// SeedFakeData inserts rows resources with faked values matching the type of each column into table, without calling
+// any resolvers, e.g. for demos or load testing the verification. Each relation gets rows resources as well, spread
+// over the resources of its parent and linked to them by their parent id column. The table is expected to be created
+// by the dialect named by TestDialectEnv, like the tables of TestResource.
+func SeedFakeData(conn execution.QueryExecer, table *schema.Table, rows int) error {
+	dialect, err := testDialect()
+	if err != nil {
+		return err
+	}
+	return seedTable(context.Background(), conn, dialect, table, nil, rows)
+}
+
+func seedTable(ctx context.Context, conn execution.QueryExecer, dialect schema.Dialect, table *schema.Table, parents schema.Resources, rows int) error {
+	resources, err := fakeResources(ctx, dialect, table, parents, rows)
+	if err != nil {
+		return err
+	}
+	if err := insertResources(ctx, conn, dialect, table, resources); err != nil {
+		return err
+	}
+	for _, rel := range table.Relations {
+		if err := seedTable(ctx, conn, dialect, rel, resources, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertResources inserts resources into table, seedBatchSize resources per statement
+func insertResources(ctx context.Context, conn execution.QueryExecer, dialect schema.Dialect, table *schema.Table, resources schema.Resources) error {
+	for start := 0; start < len(resources); start += seedBatchSize {
+		end := start + seedBatchSize
+		if end > len(resources) {
+			end = len(resources)
+		}
+		stmt := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).Insert(table.Name).Columns(quoteIdentifiers(resources.ColumnNames())...)
+		for _, r := range resources[start:end] {
+			values, err := dialect.GetResourceValues(r)
+			if err != nil {
+				return fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			stmt = stmt.Values(values...)
+		}
+		query, args, err := stmt.ToSql()
+		if err != nil {
+			return err
+		}
+		if err := conn.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert into table %s: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+// fakeResources returns rows resources of table with faked column values, the i-th resource is a child of the
+// i-th parent modulo their amount. The parent id and internal columns are resolved by their resolvers. String primary
+// keys are suffixed by the resource's index, so the rows don't collide on the faked words.
+func fakeResources(ctx context.Context, dialect schema.Dialect, table *schema.Table, parents schema.Resources, rows int) (schema.Resources, error) {
+	var parentIdColumn string
+	if c := schema.FindParentIdColumn(table); c != nil {
+		parentIdColumn = c.Name
+	}
+	resources := make(schema.Resources, 0, rows)
+	for i := 0; i < rows; i++ {
+		var parent *schema.Resource
+		if len(parents) > 0 {
+			parent = parents[i%len(parents)]
+		}
+		r := schema.NewResourceData(dialect, table, parent, nil, nil, time.Now())
+		// internal columns, such as cq_id which may be derived from the primary keys, are resolved last
+		var internal []schema.Column
+		for _, c := range dialect.Columns(table) {
+			switch {
+			case c.Internal():
+				internal = append(internal, c)
+				continue
+			case c.Name == parentIdColumn && parent != nil:
+				if err := c.Resolver(ctx, seedClient{}, r, c); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			v := fakeColumnValue(c)
+			if c.Type == schema.TypeString && funk.ContainsString(table.Options.PrimaryKeys, c.Name) {
+				v = uniqueString(v.(string), i, c.MaxLength)
+			}
+			if err := r.Set(c.Name, v); err != nil {
+				return nil, err
+			}
+		}
+		for _, c := range internal {
+			if err := c.Resolver(ctx, seedClient{}, r, c); err != nil {
+				return nil, fmt.Errorf("table %s: %w", table.Name, err)
+			}
+		}
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
+
+// fakeColumnValue returns a random value of the column's type, respecting its EnumValues, MaxLength, Precision and Scale
+func fakeColumnValue(c schema.Column) interface{} {
+	switch c.Type {
+	case schema.TypeBool:
+		return rand.Intn(2) == 1
+	case schema.TypeSmallInt:
+		return int16(rand.Intn(math.MaxInt16))
+	case schema.TypeInt:
+		return rand.Int31()
+	case schema.TypeBigInt:
+		return rand.Int63()
+	case schema.TypeFloat:
+		return rand.Float64() * 1000
+	case schema.TypeNumeric:
+		return fakeNumeric(c.Precision, c.Scale)
+	case schema.TypeUUID:
+		return uuid.New()
+	case schema.TypeString:
+		word := []rune(faker.Word())
+		if c.MaxLength > 0 && len(word) > c.MaxLength {
+			word = word[:c.MaxLength]
+		}
+		return string(word)
+	case schema.TypeByteArray:
+		return []byte(faker.Password())
+	case schema.TypeStringArray:
+		return []string{faker.Word(), faker.Word()}
+	case schema.TypeIntArray:
+		return []int{rand.Intn(1000), rand.Intn(1000)}
+	case schema.TypeTimestamp:
+		return time.Unix(faker.UnixTime(), 0).UTC()
+	case schema.TypeJSON:
+		return map[string]interface{}{"name": faker.Word(), "count": rand.Intn(1000)}
+	case schema.TypeUUIDArray:
+		return []uuid.UUID{uuid.New(), uuid.New()}
+	case schema.TypeInet:
+		return fakeIP()
+	case schema.TypeInetArray:
+		return []net.IP{fakeIP(), fakeIP()}
+	case schema.TypeCIDR:
+		return fakeCIDR()
+	case schema.TypeCIDRArray:
+		return []*net.IPNet{fakeCIDR(), fakeCIDR()}
+	case schema.TypeMacAddr:
+		return fakeMAC()
+	case schema.TypeMacAddrArray:
+		return []net.HardwareAddr{fakeMAC(), fakeMAC()}
+	case schema.TypeEnum:
+		if len(c.EnumValues) == 0 {
+			return nil
+		}
+		return c.EnumValues[rand.Intn(len(c.EnumValues))]
+	default:
+		return nil
+	}
+}
+
+// uniqueString suffixes word by index, truncating word so the result fits maxLength if positive
+func uniqueString(word string, index, maxLength int) string {
+	suffix := "-" + strconv.Itoa(index)
+	runes := []rune(word)
+	if maxLength > 0 && len(runes)+len(suffix) > maxLength {
+		if maxLength <= len(suffix) {
+			return strconv.Itoa(index)
+		}
+		runes = runes[:maxLength-len(suffix)]
+	}
+	return string(runes) + suffix
+}
+
+func fakeIP() net.IP {
+	return net.ParseIP(faker.IPv4())
+}
+
+func fakeCIDR() *net.IPNet {
+	_, cidr, _ := net.ParseCIDR(fmt.Sprintf("%s/%d", faker.IPv4(), rand.Intn(16)+16))
+	return cidr
+}
+
+func fakeMAC() net.HardwareAddr {
+	mac, _ := net.ParseMAC(faker.MacAddress())
+	return mac
+}
+
+// fakeNumeric returns a random decimal string fitting numeric(precision, scale), or numeric(18, 6) without a precision
+func fakeNumeric(precision, scale int) string {
+	if precision <= 0 {
+		precision, scale = 18, 6
+	}
+	digits := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte('0' + rand.Intn(10))
+		}
+		return string(b)
+	}
+	value := digits(precision - scale)
+	if value == "" {
+		value = "0"
+	}
+	if scale > 0 {
+		value += "." + digits(scale)
+	}
+	return value
+}
@@ -0,0 +1,138 @@
+package testing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingQueryExecer records the statements and arguments it executes
+type recordingQueryExecer struct {
+	statements []string
+	args       [][]interface{}
+}
+
+func (r *recordingQueryExecer) Exec(_ context.Context, query string, args ...interface{}) error {
+	r.statements = append(r.statements, query)
+	r.args = append(r.args, args)
+	return nil
+}
+
+func (r *recordingQueryExecer) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return nil, nil
+}
+
+var seedTestTable = &schema.Table{
+	Name:    "test_seed",
+	Options: schema.TableCreationOptions{PrimaryKeys: []string{"id"}},
+	Columns: []schema.Column{
+		{Name: "id", Type: schema.TypeUUID},
+		{Name: "enabled", Type: schema.TypeBool},
+		{Name: "small", Type: schema.TypeSmallInt},
+		{Name: "count", Type: schema.TypeInt},
+		{Name: "big", Type: schema.TypeBigInt},
+		{Name: "ratio", Type: schema.TypeFloat},
+		{Name: "name", Type: schema.TypeString, MaxLength: 2},
+		{Name: "data", Type: schema.TypeByteArray},
+		{Name: "tags", Type: schema.TypeStringArray},
+		{Name: "ports", Type: schema.TypeIntArray},
+		{Name: "created_at", Type: schema.TypeTimestamp},
+		{Name: "doc", Type: schema.TypeJSON},
+		{Name: "ids", Type: schema.TypeUUIDArray},
+		{Name: "ip", Type: schema.TypeInet},
+		{Name: "ips", Type: schema.TypeInetArray},
+		{Name: "cidr", Type: schema.TypeCIDR},
+		{Name: "cidrs", Type: schema.TypeCIDRArray},
+		{Name: "mac", Type: schema.TypeMacAddr},
+		{Name: "macs", Type: schema.TypeMacAddrArray},
+		{Name: "status", Type: schema.TypeEnum, EnumValues: []string{"active", "inactive"}},
+	},
+	Relations: []*schema.Table{
+		{
+			Name: "test_seed_children",
+			Columns: []schema.Column{
+				{Name: "test_seed_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver},
+				{Name: "name", Type: schema.TypeString},
+			},
+		},
+	},
+}
+
+func TestFakeResources(t *testing.T) {
+	parents, err := fakeResources(context.Background(), schema.PostgresDialect{}, seedTestTable, nil, 3)
+	require.NoError(t, err)
+	require.Len(t, parents, 3)
+	for _, r := range parents {
+		for _, c := range seedTestTable.Columns {
+			v := r.Get(c.Name)
+			assert.NotNil(t, v, c.Name)
+			assert.NoError(t, c.ValidateType(v), c.Name)
+		}
+		assert.LessOrEqual(t, len([]rune(r.Get("name").(string))), 2)
+		// cq_id derives from the faked primary key
+		assert.Equal(t, r.Id(), r.Get("cq_id"))
+	}
+
+	children, err := fakeResources(context.Background(), schema.PostgresDialect{}, seedTestTable.Relations[0], parents, 5)
+	require.NoError(t, err)
+	require.Len(t, children, 5)
+	for i, r := range children {
+		assert.Equal(t, parents[i%3].Id(), r.Get("test_seed_cq_id"))
+	}
+}
+
+func TestSeedFakeData(t *testing.T) {
+	conn := &recordingQueryExecer{}
+	require.NoError(t, SeedFakeData(conn, seedTestTable, seedBatchSize+1))
+	require.Len(t, conn.statements, 4)
+	assert.True(t, strings.HasPrefix(conn.statements[0], `INSERT INTO test_seed ("cq_id","cq_meta","id",`), conn.statements[0])
+	assert.True(t, strings.HasPrefix(conn.statements[2], `INSERT INTO test_seed_children ("cq_id","cq_meta","test_seed_cq_id","name")`), conn.statements[2])
+	columns := len(seedTestTable.Columns) + 2
+	assert.Len(t, conn.args[0], seedBatchSize*columns)
+	assert.Len(t, conn.args[1], columns)
+	assert.Len(t, conn.args[3], 4)
+}
+
+func TestUniqueString(t *testing.T) {
+	assert.Equal(t, "name-12", uniqueString("name", 12, 0))
+	assert.Equal(t, "na-12", uniqueString("name", 12, 5))
+	assert.Equal(t, "12", uniqueString("name", 12, 3))
+}
+
+var seedStringKeyTable = &schema.Table{
+	Name:    "test_seed_string_key",
+	Options: schema.TableCreationOptions{PrimaryKeys: []string{"name"}},
+	Columns: []schema.Column{
+		{Name: "name", Type: schema.TypeString, MaxLength: 8},
+		{Name: "count", Type: schema.TypeInt},
+	},
+}
+
+func TestFakeResources_StringPrimaryKey(t *testing.T) {
+	resources, err := fakeResources(context.Background(), schema.PostgresDialect{}, seedStringKeyTable, nil, 1000)
+	require.NoError(t, err)
+	names := make(map[string]bool, len(resources))
+	for _, r := range resources {
+		name := r.Get("name").(string)
+		assert.LessOrEqual(t, len(name), 8)
+		names[name] = true
+	}
+	assert.Len(t, names, 1000)
+}
+
+func TestSeedFakeData_StringPrimaryKey(t *testing.T) {
+	ctx := context.Background()
+	conn, err := setupDatabase(getEnv("DATABASE_URL", defaultDatabaseURL))
+	require.NoError(t, err)
+	require.NoError(t, dropAndCreateTables(ctx, conn, "", []*schema.Table{seedStringKeyTable}, nil))
+
+	require.NoError(t, SeedFakeData(conn, seedStringKeyTable, 1000))
+	counts, err := CollectRowCounts(conn, seedStringKeyTable)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), counts[seedStringKeyTable.Name].Count)
+}
@@ -0,0 +1,163 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// SlowQueryCode is the code of the diagnostics reported for queries exceeding ResourceTestCase SlowQueryThreshold
+	SlowQueryCode = "slow_query"
+	// maxSlowQueryLength is the length slow queries are truncated to in their diagnostics
+	maxSlowQueryLength = 200
+)
+
+// slowQueryRecorder collects a WARNING diagnostic for every query taking longer than threshold
+type slowQueryRecorder struct {
+	threshold time.Duration
+	lock      sync.Mutex
+	diags     diag.Diagnostics
+}
+
+func newSlowQueryRecorder(threshold time.Duration) *slowQueryRecorder {
+	return &slowQueryRecorder{threshold: threshold}
+}
+
+// record reports query if it took longer than the threshold since start
+func (r *slowQueryRecorder) record(query string, start time.Time) {
+	elapsed := time.Since(start)
+	if elapsed <= r.threshold {
+		return
+	}
+	d := diag.NewBaseError(nil, diag.DATABASE, diag.WithSeverity(diag.WARNING), diag.WithCode(SlowQueryCode),
+		diag.WithSummary("query took %s, exceeding %s: %s", elapsed.Round(time.Millisecond), r.threshold, truncateQuery(query)))
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.diags = r.diags.Add(d)
+}
+
+// diagnostics returns the diagnostics of the slow queries recorded so far
+func (r *slowQueryRecorder) diagnostics() diag.Diagnostics {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append(diag.Diagnostics{}, r.diags...)
+}
+
+// truncateQuery collapses the whitespace of query, truncating it to maxSlowQueryLength
+func truncateQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) <= maxSlowQueryLength {
+		return query
+	}
+	return query[:maxSlowQueryLength] + "..."
+}
+
+// timedQueryExecer wraps an execution.QueryExecer recording its slow Exec and Query calls. Query is timed until its
+// rows are returned, not until they're read.
+type timedQueryExecer struct {
+	execution.QueryExecer
+	recorder *slowQueryRecorder
+}
+
+// timedStorage wraps an execution.Storage recording its slow Exec, Query, Insert, CopyFrom, Delete and RemoveStaleData
+// calls, and those of the transactions it begins
+type timedStorage struct {
+	execution.Storage
+	recorder *slowQueryRecorder
+}
+
+// timedTx wraps an execution.TXQueryExecer recording its slow Exec and Query calls, and those of its nested transactions
+type timedTx struct {
+	execution.TXQueryExecer
+	recorder *slowQueryRecorder
+}
+
+// timeQueryExecer returns conn wrapped with recorder, if recorder is nil conn is returned as is
+func timeQueryExecer(conn execution.QueryExecer, recorder *slowQueryRecorder) execution.QueryExecer {
+	if recorder == nil {
+		return conn
+	}
+	return timedQueryExecer{QueryExecer: conn, recorder: recorder}
+}
+
+// timeStorage returns storage wrapped with recorder, if recorder is nil storage is returned as is
+func timeStorage(storage execution.Storage, recorder *slowQueryRecorder) execution.Storage {
+	if recorder == nil {
+		return storage
+	}
+	return timedStorage{Storage: storage, recorder: recorder}
+}
+
+func (o timedQueryExecer) Exec(ctx context.Context, query string, args ...interface{}) error {
+	defer o.recorder.record(query, time.Now())
+	return o.QueryExecer.Exec(ctx, query, args...)
+}
+
+func (o timedQueryExecer) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	defer o.recorder.record(query, time.Now())
+	return o.QueryExecer.Query(ctx, query, args...)
+}
+
+func (o timedStorage) Exec(ctx context.Context, query string, args ...interface{}) error {
+	defer o.recorder.record(query, time.Now())
+	return o.Storage.Exec(ctx, query, args...)
+}
+
+func (o timedStorage) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	defer o.recorder.record(query, time.Now())
+	return o.Storage.Query(ctx, query, args...)
+}
+
+func (o timedStorage) Insert(ctx context.Context, t *schema.Table, instance schema.Resources, shouldCascade bool, cascadeDeleteFilters map[string]interface{}) error {
+	defer o.recorder.record(fmt.Sprintf("INSERT INTO %s (%d resources)", t.Name, len(instance)), time.Now())
+	return o.Storage.Insert(ctx, t, instance, shouldCascade, cascadeDeleteFilters)
+}
+
+func (o timedStorage) CopyFrom(ctx context.Context, resources schema.Resources, shouldCascade bool, cascadeDeleteFilters map[string]interface{}) error {
+	defer o.recorder.record(fmt.Sprintf("COPY %s (%d resources)", resources.TableName(), len(resources)), time.Now())
+	return o.Storage.CopyFrom(ctx, resources, shouldCascade, cascadeDeleteFilters)
+}
+
+func (o timedStorage) Delete(ctx context.Context, t *schema.Table, kvFilters []interface{}) error {
+	defer o.recorder.record(fmt.Sprintf("DELETE FROM %s", t.Name), time.Now())
+	return o.Storage.Delete(ctx, t, kvFilters)
+}
+
+func (o timedStorage) RemoveStaleData(ctx context.Context, t *schema.Table, executionStart time.Time, kvFilters []interface{}) error {
+	defer o.recorder.record(fmt.Sprintf("DELETE FROM %s (stale data)", t.Name), time.Now())
+	return o.Storage.RemoveStaleData(ctx, t, executionStart, kvFilters)
+}
+
+func (o timedStorage) Begin(ctx context.Context) (execution.TXQueryExecer, error) {
+	tx, err := o.Storage.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return timedTx{TXQueryExecer: tx, recorder: o.recorder}, nil
+}
+
+func (o timedTx) Exec(ctx context.Context, query string, args ...interface{}) error {
+	defer o.recorder.record(query, time.Now())
+	return o.TXQueryExecer.Exec(ctx, query, args...)
+}
+
+func (o timedTx) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	defer o.recorder.record(query, time.Now())
+	return o.TXQueryExecer.Query(ctx, query, args...)
+}
+
+func (o timedTx) Begin(ctx context.Context) (execution.TXQueryExecer, error) {
+	tx, err := o.TXQueryExecer.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return timedTx{TXQueryExecer: tx, recorder: o.recorder}, nil
+}
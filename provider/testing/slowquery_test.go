@@ -0,0 +1,94 @@
+package testing
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sleepingQueryExecer takes delay to execute any statement
+type sleepingQueryExecer struct {
+	delay time.Duration
+}
+
+func (s sleepingQueryExecer) Exec(context.Context, string, ...interface{}) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s sleepingQueryExecer) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	time.Sleep(s.delay)
+	return nil, nil
+}
+
+// sleepingStorage is an execution.Storage taking delay to delete from any table
+type sleepingStorage struct {
+	execution.Storage
+	delay time.Duration
+}
+
+func (s sleepingStorage) Delete(context.Context, *schema.Table, []interface{}) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s sleepingStorage) RemoveStaleData(context.Context, *schema.Table, time.Time, []interface{}) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s sleepingStorage) Begin(context.Context) (execution.TXQueryExecer, error) {
+	return &recordingTx{}, nil
+}
+
+func TestTimedQueryExecer(t *testing.T) {
+	recorder := newSlowQueryRecorder(10 * time.Millisecond)
+	fast := timeQueryExecer(sleepingQueryExecer{}, recorder)
+	slow := timeQueryExecer(sleepingQueryExecer{delay: 20 * time.Millisecond}, recorder)
+
+	require.NoError(t, fast.Exec(context.Background(), "SELECT 1"))
+	require.NoError(t, slow.Exec(context.Background(), "SELECT\n\tpg_sleep(1)"))
+	_, err := slow.Query(context.Background(), "SELECT "+strings.Repeat("a, ", 100)+"b")
+	require.NoError(t, err)
+
+	diags := recorder.diagnostics()
+	require.Len(t, diags, 2)
+	for _, d := range diags {
+		assert.Equal(t, diag.WARNING, d.Severity())
+		assert.Equal(t, diag.DATABASE, d.Type())
+		assert.Equal(t, SlowQueryCode, d.Description().Code)
+	}
+	assert.Regexp(t, `^query took \d+ms, exceeding 10ms: SELECT pg_sleep\(1\)$`, diags[0].Description().Summary)
+	assert.Regexp(t, `: SELECT (a, )+a\.\.\.$`, diags[1].Description().Summary)
+
+	// without a recorder the conn isn't wrapped
+	assert.Equal(t, sleepingQueryExecer{}, timeQueryExecer(sleepingQueryExecer{}, nil))
+}
+
+func TestTimedStorage(t *testing.T) {
+	ctx := context.Background()
+	recorder := newSlowQueryRecorder(10 * time.Millisecond)
+	table := &schema.Table{Name: "test_table"}
+	require.NoError(t, timeStorage(sleepingStorage{}, recorder).Delete(ctx, table, nil))
+	storage := timeStorage(sleepingStorage{delay: 20 * time.Millisecond}, recorder)
+	require.NoError(t, storage.Delete(ctx, table, []interface{}{"name", "a"}))
+	require.NoError(t, storage.RemoveStaleData(ctx, table, time.Now(), nil))
+
+	diags := recorder.diagnostics()
+	require.Len(t, diags, 2)
+	assert.Regexp(t, `^query took \d+ms, exceeding 10ms: DELETE FROM test_table$`, diags[0].Description().Summary)
+	assert.Regexp(t, `: DELETE FROM test_table \(stale data\)$`, diags[1].Description().Summary)
+
+	// the statements of the transactions begun by the storage are timed too
+	tx, err := storage.Begin(ctx)
+	require.NoError(t, err)
+	assert.IsType(t, timedTx{}, tx)
+}
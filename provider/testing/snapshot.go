@@ -0,0 +1,164 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// UpdateRowSnapshotEnv if set to true, RowSnapshotVerifier overwrites the snapshots instead of comparing to them
+const UpdateRowSnapshotEnv = "CQ_UPDATE_ROW_SNAPSHOT"
+
+// MaskRule replaces every match of Pattern in the values of a row snapshot with Replacement, which may refer to
+// submatches as in regexp.Regexp ReplaceAllString. Rules mask values such as email addresses whatever their column,
+// as opposed to Column.Sensitive masking whole columns.
+type MaskRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// RowSnapshotVerifier compares the rows of table and its relations to the JSON snapshot stored at path, failing if they
+// changed. The snapshot holds the declared columns of each row as text, rows sorted, so internal columns such as cq_id
+// don't make it unstable, nor do the parent id columns of relations holding their parent's cq_id. Values of sensitive
+// and encrypted columns are masked, then the masker rules apply in order to every value. The snapshot is written if it
+// doesn't exist yet, or if UpdateRowSnapshotEnv is set to true.
+func RowSnapshotVerifier(path string, masker ...MaskRule) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		rows := make(map[string][]Row)
+		if err := collectSnapshotRows(conn, table, rows); err != nil {
+			t.Fatal(err)
+		}
+		actual, err := encodeRowSnapshot(table, rows, masker)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		update, _ := strconv.ParseBool(os.Getenv(UpdateRowSnapshotEnv))
+		expected, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) || update {
+			if err := os.WriteFile(path, actual, 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("row snapshot written to %s", path)
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("RowSnapshotVerifier failed: rows of %s changed compared to snapshot %s, set %s=true to update it.\nexpected:\n%s\nactual:\n%s",
+				table.Name, path, UpdateRowSnapshotEnv, expected, actual)
+		}
+	}
+}
+
+// collectSnapshotRows selects the declared columns of table and its relations as text into rows, keyed by table name.
+// Parent id columns are skipped, cq_ids are random unless derived from primary keys.
+func collectSnapshotRows(conn pgxscan.Querier, table *schema.Table, rows map[string][]Row) error {
+	var parentIdColumn string
+	if pc := schema.FindParentIdColumn(table); pc != nil {
+		parentIdColumn = pc.Name
+	}
+	var columns []string
+	for _, c := range table.Columns {
+		if c.Name == parentIdColumn {
+			continue
+		}
+		columns = append(columns, fmt.Sprintf("%[1]s::text AS %[1]s", strconv.Quote(c.Name)))
+	}
+	if len(columns) > 0 {
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).Select(columns...).From(strconv.Quote(table.Name)).ToSql()
+		if err != nil {
+			return err
+		}
+		var tableRows []Row
+		if err := pgxscan.Select(context.Background(), conn, &tableRows, query, args...); err != nil {
+			return fmt.Errorf("failed to select rows of table %s: %w", table.Name, err)
+		}
+		rows[table.Name] = tableRows
+	}
+	for _, rel := range table.Relations {
+		if err := collectSnapshotRows(conn, rel, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeRowSnapshot returns the masked snapshot of rows, the rows of the tables of table keyed by table name
+func encodeRowSnapshot(table *schema.Table, rows map[string][]Row, masker []MaskRule) ([]byte, error) {
+	snapshot := make(map[string][]json.RawMessage, len(rows))
+	var mask func(table *schema.Table) error
+	mask = func(table *schema.Table) error {
+		if tableRows, ok := rows[table.Name]; ok {
+			encoded := make([]json.RawMessage, len(tableRows))
+			for i, row := range tableRows {
+				masked := make(map[string]interface{}, len(row))
+				for name, v := range row {
+					masked[name] = maskSnapshotValue(table.Column(name), v, masker)
+				}
+				data, err := marshalSnapshot(masked, "")
+				if err != nil {
+					return err
+				}
+				encoded[i] = data
+			}
+			// rows are returned in no particular order
+			sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+			snapshot[table.Name] = encoded
+		}
+		for _, rel := range table.Relations {
+			if err := mask(rel); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := mask(table); err != nil {
+		return nil, err
+	}
+	return marshalSnapshot(snapshot, "  ")
+}
+
+// marshalSnapshot encodes v as JSON indented by indent, keeping characters such as < and > of masked values readable
+func marshalSnapshot(v interface{}, indent string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// maskSnapshotValue masks v, a value of column c, if c is sensitive or encrypted, then applies the masker rules in order
+func maskSnapshotValue(c *schema.Column, v interface{}, masker []MaskRule) interface{} {
+	if c != nil {
+		if c.Encrypt != nil {
+			// the ciphertext differs between fetches
+			return schema.MaskedValue
+		}
+		v = c.Mask(v)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	for _, rule := range masker {
+		s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package testing
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeRowSnapshot(t *testing.T) {
+	table := &schema.Table{
+		Name: "test_users",
+		Columns: []schema.Column{
+			{Name: "name", Type: schema.TypeString},
+			{Name: "email", Type: schema.TypeString},
+			{Name: "password", Type: schema.TypeString, Sensitive: true},
+		},
+		Relations: []*schema.Table{
+			{
+				Name: "test_user_logins",
+				Columns: []schema.Column{
+					{Name: "source_ip", Type: schema.TypeInet},
+					{Name: "details", Type: schema.TypeJSON},
+				},
+			},
+		},
+	}
+	rows := map[string][]Row{
+		"test_users": {
+			{"name": "jane", "email": "jane@example.com", "password": "hunter2"},
+			{"name": "bob", "email": "Bob <bob.smith@example.org>", "password": nil},
+		},
+		"test_user_logins": {
+			{"source_ip": "10.0.0.12/32", "details": `{"client": "cli", "from": "192.168.1.7"}`},
+			{"source_ip": nil, "details": nil},
+		},
+	}
+	masker := []MaskRule{
+		{Pattern: regexp.MustCompile(`[\w.+-]+@([\w-]+\.)+\w+`), Replacement: "<email>"},
+		{Pattern: regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`), Replacement: "<ip>"},
+	}
+	actual, err := encodeRowSnapshot(table, rows, masker)
+	require.NoError(t, err)
+	expected, err := os.ReadFile("testdata/row_snapshot.golden.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+
+	// rules apply in order, the digits are masked before the address rule could match them
+	assert.Equal(t, "N.N.N.N", maskSnapshotValue(nil, "10.0.0.1", []MaskRule{
+		{Pattern: regexp.MustCompile(`\d+`), Replacement: "N"},
+		{Pattern: regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`), Replacement: "<ip>"},
+	}))
+}
+
+func TestCollectSnapshotRows(t *testing.T) {
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte("source_ip"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}},
+		values:   [][][]byte{{[]byte("10.0.0.1")}},
+		current:  -1,
+	}}
+	rel := &schema.Table{
+		Name: "test_user_logins",
+		Columns: []schema.Column{
+			{Name: "user_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver},
+			{Name: "source_ip", Type: schema.TypeInet},
+		},
+	}
+	table := &schema.Table{Name: "test_users", Relations: []*schema.Table{rel}}
+	rows := make(map[string][]Row)
+	require.NoError(t, collectSnapshotRows(conn, table, rows))
+	// the parent id holds the random cq_id of the parent, it isn't snapshotted
+	assert.Equal(t, `SELECT "source_ip"::text AS "source_ip" FROM "test_user_logins"`, conn.query)
+	assert.Equal(t, map[string][]Row{"test_user_logins": {{"source_ip": "10.0.0.1"}}}, rows)
+}
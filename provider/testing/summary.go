@@ -0,0 +1,121 @@
+package testing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudquery/cq-provider-sdk/cqproto"
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+)
+
+// FetchSummary is the merged summary of all the fetch responses received by the test harness
+type FetchSummary struct {
+	// ResourceCount is the total amount of resources fetched
+	ResourceCount uint64
+	// Resources maps each resource that finished fetching to the amount of resources it fetched
+	Resources map[string]uint64
+	// Durations maps each resource that finished fetching to the time from the start of the fetch until it finished
+	Durations map[string]time.Duration
+	// Duration is the time the whole fetch took, set by TestResource once the fetch finished
+	Duration time.Duration
+	// Diagnostics of all fetched resources
+	Diagnostics diag.Diagnostics
+	// Cache are the lookups of the resolvers in the fetch's execution.Cache, always zero for a RemoteProvider
+	Cache execution.CacheStats
+	// MaxConcurrency is the maximum number of resources resolved concurrently, always zero for a RemoteProvider
+	MaxConcurrency int
+}
+
+// add merges the response's summary, received elapsed after the start of the fetch. The caller must hold the sender's
+// lock.
+func (s *FetchSummary) add(r *cqproto.FetchResourcesResponse, elapsed time.Duration) {
+	s.ResourceCount += r.Summary.ResourceCount
+	s.Resources[r.ResourceName] += r.Summary.ResourceCount
+	s.Durations[r.ResourceName] = elapsed
+	s.Diagnostics = append(s.Diagnostics, r.Summary.Diagnostics...)
+}
+
+// FetchSummary returns a copy of the summary merged from all responses sent so far
+func (f *testResourceSender) FetchSummary() FetchSummary {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	resources := make(map[string]uint64, len(f.summary.Resources))
+	for name, count := range f.summary.Resources {
+		resources[name] = count
+	}
+	durations := make(map[string]time.Duration, len(f.summary.Durations))
+	for name, d := range f.summary.Durations {
+		durations[name] = d
+	}
+	return FetchSummary{
+		ResourceCount:  f.summary.ResourceCount,
+		Resources:      resources,
+		Durations:      durations,
+		Diagnostics:    append(diag.Diagnostics{}, f.summary.Diagnostics...),
+		Cache:          f.cache.Stats(),
+		MaxConcurrency: f.concurrency.Max(),
+	}
+}
+
+// UpdateBaselineSummaryEnv if set to true, TestResource overwrites the baseline summary instead of comparing to it
+const UpdateBaselineSummaryEnv = "CQ_UPDATE_BASELINE_SUMMARY"
+
+// verifyBaselineSummary compares the resource counts of summary to the JSON baseline stored at path, failing on
+// resources whose count drifted by more than tolerance percent. The baseline is written if it doesn't exist yet, or if
+// UpdateBaselineSummaryEnv is set to true.
+func verifyBaselineSummary(t *testing.T, path string, tolerance float64, summary FetchSummary) {
+	t.Helper()
+	update, _ := strconv.ParseBool(os.Getenv(UpdateBaselineSummaryEnv))
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || update {
+		data, err := json.MarshalIndent(summary.Resources, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("baseline summary written to %s", path)
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var baseline map[string]uint64
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		t.Fatalf("failed to read baseline summary %s: %s", path, err)
+	}
+	if drifted := summaryDrift(baseline, summary.Resources, tolerance); len(drifted) > 0 {
+		t.Errorf("resource counts drifted from baseline summary %s by more than %g%%, set %s=true to update it:\n%s",
+			path, tolerance, UpdateBaselineSummaryEnv, strings.Join(drifted, "\n"))
+	}
+}
+
+// summaryDrift lists the resources of baseline whose count in current drifted by more than tolerance percent.
+// Resources missing from current count as zero, resources missing from baseline aren't compared.
+func summaryDrift(baseline, current map[string]uint64, tolerance float64) []string {
+	var drifted []string
+	for name, expected := range baseline {
+		actual := current[name]
+		drift := math.Inf(1)
+		if expected > 0 {
+			drift = math.Abs(float64(actual)-float64(expected)) / float64(expected) * 100
+		} else if actual == 0 {
+			drift = 0
+		}
+		if drift > tolerance {
+			drifted = append(drifted, fmt.Sprintf("%s: expected %d, got %d", name, expected, actual))
+		}
+	}
+	sort.Strings(drifted)
+	return drifted
+}
@@ -0,0 +1,174 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/cqproto"
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/execution"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestResourceSender_FetchSummary(t *testing.T) {
+	const (
+		resources = 50
+		responses = 20
+	)
+	sender := newTestResourceSender(0)
+	var wg sync.WaitGroup
+	for i := 0; i < resources; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for j := 0; j < responses; j++ {
+				assert.NoError(t, sender.Send(&cqproto.FetchResourcesResponse{
+					ResourceName: name,
+					Summary: cqproto.ResourceFetchSummary{
+						Status:        cqproto.ResourceFetchComplete,
+						ResourceCount: 3,
+						Diagnostics:   diag.Diagnostics{diag.NewBaseError(nil, diag.RESOLVING, diag.WithSeverity(diag.IGNORE), diag.WithSummary("ignored"))},
+					},
+				}))
+			}
+		}(fmt.Sprintf("resource_%d", i))
+	}
+	wg.Wait()
+
+	summary := sender.FetchSummary()
+	assert.Equal(t, uint64(resources*responses*3), summary.ResourceCount)
+	assert.Len(t, summary.Resources, resources)
+	for name, count := range summary.Resources {
+		assert.Equal(t, uint64(responses*3), count, name)
+	}
+	assert.Len(t, summary.Durations, resources)
+	assert.Len(t, summary.Diagnostics, resources*responses)
+	assert.Empty(t, sender.Errors)
+
+	sender.cache.Set("accounts", 1)
+	sender.cache.Get("accounts")
+	sender.cache.Get("regions")
+	assert.Equal(t, execution.CacheStats{Hits: 1, Misses: 1}, sender.FetchSummary().Cache)
+}
+
+func TestTestResourceSender_StrictNoDiagnostics(t *testing.T) {
+	diags := diag.Diagnostics{
+		diag.NewBaseError(nil, diag.RESOLVING, diag.WithSeverity(diag.IGNORE), diag.WithSummary("ignored")),
+		diag.NewBaseError(nil, diag.RESOLVING, diag.WithSeverity(diag.WARNING), diag.WithSummary("expected warning")),
+		diag.NewBaseError(execution.ColumnResolveError{Table: "test_table", Column: "name", Err: errors.New("column failed")}, diag.RESOLVING, diag.WithSeverity(diag.WARNING),
+			diag.WithResourceId([]string{"arn:parent", "child-1"})),
+	}
+	for _, strict := range []bool{false, true} {
+		sender := newTestResourceSender(0)
+		sender.strict = strict
+		sender.expected = []*regexp.Regexp{regexp.MustCompile("expected")}
+		assert.NoError(t, sender.Send(&cqproto.FetchResourcesResponse{
+			ResourceName: "test_resource",
+			Summary:      cqproto.ResourceFetchSummary{Status: cqproto.ResourceFetchComplete, Diagnostics: diags},
+		}))
+		assert.Equal(t, []string{"test_table@name (id: arn:parent,child-1): column failed"}, sender.ColumnErrors)
+		if strict {
+			assert.Len(t, sender.Errors, 2)
+		} else {
+			assert.Len(t, sender.Errors, 1)
+		}
+
+		// failures of columns listed in ExpectColumnErrors are only collected, unless strict
+		sender = newTestResourceSender(0)
+		sender.strict = strict
+		sender.expected = []*regexp.Regexp{regexp.MustCompile("expected")}
+		sender.expectedColumns = []string{"test_table.name"}
+		assert.NoError(t, sender.Send(&cqproto.FetchResourcesResponse{
+			ResourceName: "test_resource",
+			Summary:      cqproto.ResourceFetchSummary{Status: cqproto.ResourceFetchComplete, Diagnostics: diags},
+		}))
+		assert.Len(t, sender.ColumnErrors, 1)
+		if strict {
+			assert.Len(t, sender.Errors, 2)
+		} else {
+			assert.Empty(t, sender.Errors)
+		}
+	}
+}
+
+func TestSummaryDrift(t *testing.T) {
+	baseline := map[string]uint64{"stable": 100, "grown": 100, "shrunk": 100, "missing": 10, "empty": 0, "no_longer_empty": 0}
+	current := map[string]uint64{"stable": 105, "grown": 111, "shrunk": 89, "empty": 0, "no_longer_empty": 1, "added": 50}
+	assert.Equal(t, []string{
+		"grown: expected 100, got 111",
+		"missing: expected 10, got 0",
+		"no_longer_empty: expected 0, got 1",
+		"shrunk: expected 100, got 89",
+	}, summaryDrift(baseline, current, 10))
+	assert.Empty(t, summaryDrift(baseline, baseline, 0))
+}
+
+func TestTestResourceSender_ExpectDiagnosticMatches(t *testing.T) {
+	diags := diag.Diagnostics{
+		diag.NewBaseError(nil, diag.ACCESS, diag.WithSeverity(diag.ERROR), diag.WithSummary("access denied to us-east-1")),
+		diag.NewBaseError(nil, diag.RESOLVING, diag.WithSeverity(diag.WARNING), diag.WithSummary("failed to resolve"), diag.WithDetails("throttled by api")),
+		diag.NewBaseError(nil, diag.RESOLVING, diag.WithSeverity(diag.ERROR), diag.WithSummary("unexpected failure")),
+	}
+	sender := newTestResourceSender(0)
+	sender.expected = []*regexp.Regexp{regexp.MustCompile("^access denied"), regexp.MustCompile("throttled")}
+	assert.NoError(t, sender.Send(&cqproto.FetchResourcesResponse{
+		ResourceName: "test_resource",
+		Summary:      cqproto.ResourceFetchSummary{Status: cqproto.ResourceFetchComplete, Diagnostics: diags},
+	}))
+	// diagnostics matching a pattern by their summary or detail don't fail the test
+	require.Len(t, sender.Errors, 1)
+	assert.Equal(t, "unexpected failure", sender.Errors[0].Summary)
+
+	unexpected := regexp.MustCompile("quota exceeded")
+	assert.Empty(t, unmatchedPatterns(sender.expected, diags))
+	assert.Equal(t, []*regexp.Regexp{unexpected}, unmatchedPatterns(append(sender.expected, unexpected), diags))
+	assert.Equal(t, sender.expected, unmatchedPatterns(sender.expected, nil))
+}
+
+func TestFetch_InvalidDiagnosticPattern(t *testing.T) {
+	resource := ResourceTestCase{Provider: newMemoryProvider(newMemoryStorage(), 1, 1, 1), ExpectDiagnosticMatches: []string{"access (denied"}}
+	ctx := context.Background()
+	require.NoError(t, configure(ctx, &resource, ""))
+	_, err := fetch(ctx, t, &resource, "")
+	assert.EqualError(t, err, "invalid expected diagnostic pattern \"access (denied\": error parsing regexp: missing closing ): `access (denied`")
+}
+
+func TestTestResourceSender_MaxErrors(t *testing.T) {
+	assert.Equal(t, defaultMaxErrors, newTestResourceSender(0).maxErrors)
+
+	sender := newTestResourceSender(2)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, sender.Send(&cqproto.FetchResourcesResponse{
+			ResourceName: fmt.Sprintf("resource_%d", i),
+			Error:        fmt.Sprintf("failed to fetch resource_%d", i),
+		}))
+	}
+	assert.Equal(t, []fetchError{
+		{Resource: "resource_0", Severity: diag.ERROR.String(), Summary: "failed to fetch resource_0"},
+		{Resource: "resource_1", Severity: diag.ERROR.String(), Summary: "failed to fetch resource_1"},
+	}, sender.Errors)
+	assert.Equal(t, 3, sender.truncatedErrors)
+}
+
+func TestFetch_MaxErrors(t *testing.T) {
+	p := newMemoryProvider(newMemoryStorage(), 5, 1, 0)
+	for _, table := range p.ResourceMap {
+		table.Resolver = func(context.Context, schema.ClientMeta, *schema.Resource, chan<- interface{}) error {
+			return errors.New("access denied")
+		}
+	}
+	resource := ResourceTestCase{Provider: p, MaxErrors: 2}
+	ctx := context.Background()
+	require.NoError(t, configure(ctx, &resource, ""))
+	_, err := fetch(ctx, t, &resource, "")
+	require.Error(t, err)
+	assert.Equal(t, 2, strings.Count(err.Error(), "access denied"))
+	assert.Contains(t, err.Error(), "truncated 3 more errors")
+}
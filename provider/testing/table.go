@@ -0,0 +1,47 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/provider"
+	"github.com/cloudquery/cq-provider-sdk/provider/diag"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/hashicorp/go-hclog"
+)
+
+// tableTestConfig is the empty configuration of the provider of TestTable
+type tableTestConfig struct{}
+
+func (tableTestConfig) Example() string { return "" }
+
+// tableTestClient is the client passed to the resolvers of TestTable if no ClientFactory is set
+type tableTestClient struct {
+	logger hclog.Logger
+}
+
+func (c tableTestClient) Logger() hclog.Logger { return c.logger }
+
+// TestTable tests a single table without building a provider, e.g. while developing it: the table is created, fetched
+// and verified like a resource of TestResource. Its resolvers get the client of resource.ClientFactory, called with an
+// empty configuration, or a client only providing the test's logger if not set. Verifiers and ExpectSkipped refer to
+// the table by its name. resource.Provider and resource.RemoteProvider must not be set.
+func TestTable(t *testing.T, table *schema.Table, resource ResourceTestCase) {
+	t.Helper()
+	if resource.Provider != nil || resource.RemoteProvider != nil {
+		t.Fatal("TestTable can't be used with a Provider or RemoteProvider")
+	}
+	resource.Provider = tableProvider(table)
+	TestResource(t, resource)
+}
+
+// tableProvider returns a provider whose only resource is table, named after it
+func tableProvider(table *schema.Table) *provider.Provider {
+	return &provider.Provider{
+		Name:        "test",
+		ResourceMap: map[string]*schema.Table{table.Name: table},
+		Config:      func() provider.Config { return &tableTestConfig{} },
+		Configure: func(logger hclog.Logger, _ interface{}) (schema.ClientMeta, diag.Diagnostics) {
+			return tableTestClient{logger: logger}, nil
+		},
+	}
+}
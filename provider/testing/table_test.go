@@ -0,0 +1,26 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudquery/cq-provider-sdk/cqproto"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableProvider(t *testing.T) {
+	table := &schema.Table{Name: "test_table", Columns: []schema.Column{{Name: "name", Type: schema.TypeString}}}
+	p := tableProvider(table)
+	p.Logger = hclog.NewNullLogger()
+	resp, err := p.ConfigureProvider(context.Background(), &cqproto.ConfigureProviderRequest{})
+	require.NoError(t, err)
+	assert.False(t, resp.Diagnostics.HasErrors())
+	assert.Equal(t, []string{"test_table"}, p.SelectedResources())
+
+	client, diags := p.Configure(p.Logger, nil)
+	assert.Nil(t, diags)
+	assert.Same(t, p.Logger, client.Logger())
+}
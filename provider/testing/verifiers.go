@@ -2,12 +2,22 @@ package testing
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/cloudquery/cq-provider-sdk/provider/schema"
 	"github.com/cloudquery/faker/v3/support/slice"
 	"github.com/georgysavva/scany/pgxscan"
+	"github.com/thoas/go-funk"
 )
 
 type Row map[string]interface{}
@@ -44,6 +54,11 @@ func getRows(t *testing.T, conn pgxscan.Querier, table *schema.Table, shouldSkip
 	if err != nil {
 		t.Fatal(err)
 	}
+	for _, row := range rows {
+		if err := decryptRow(table, row); err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	for _, c := range table.Columns {
 		if shouldSkipIgnoreInTest && c.IgnoreInTests {
@@ -56,6 +71,117 @@ func getRows(t *testing.T, conn pgxscan.Querier, table *schema.Table, shouldSkip
 	return rows
 }
 
+// decryptRow replaces the values of the columns of table with Encrypt set in row, a row of table decoded from json_agg,
+// by their plaintext, so verifiers see the values as resolved rather than their ciphertext
+func decryptRow(table *schema.Table, row map[string]interface{}) error {
+	for _, c := range table.Columns {
+		if c.Encrypt == nil {
+			continue
+		}
+		v, ok := row[c.Name].(string)
+		if !ok {
+			continue
+		}
+		// json_agg encodes bytea values as their hex format, e.g. "\\x0a0b"
+		ciphertext, err := hex.DecodeString(strings.TrimPrefix(v, `\x`))
+		if err != nil {
+			return fmt.Errorf("failed to decode a stored value of column %s: %w", c.Name, err)
+		}
+		plaintext, err := c.DecryptValue(ciphertext)
+		if err != nil {
+			return err
+		}
+		row[c.Name] = plaintext
+	}
+	return nil
+}
+
+// ScanVerifier is a base verifier scanning all rows of a specific table from schema into typed values with pgxscan,
+// for assertions keeping the column types, unlike the JSON decoded Row. assertRows must be a func(*testing.T, []T)
+// where T is a struct, or a pointer to one, mapped to the table's columns like pgxscan does: by their db tag or the
+// snake case of their name. Only the columns of T are selected, so it doesn't have to declare all of them.
+func ScanVerifier(tableName string, assertRows interface{}) Verifier {
+	fn := reflect.ValueOf(assertRows)
+	var verifier Verifier
+	verifier = func(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool) {
+		t.Helper()
+		if tableName == table.Name {
+			rowsType, err := scanRowsType(fn)
+			if err != nil {
+				t.Fatalf("ScanVerifier failed: %s", err)
+			}
+			if shouldSkipIgnoreInTest && table.IgnoreInTests {
+				t.Skipf("table %s marked as IgnoreInTest. Skipping...", table.Name)
+			}
+			query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoteIdentifiers(scanColumns(rowsType.Elem())), ", "), strconv.Quote(table.Name))
+			rows := reflect.New(rowsType)
+			if err := pgxscan.Select(context.Background(), conn, rows.Interface(), query); err != nil {
+				t.Fatalf("ScanVerifier failed: %s", err)
+			}
+			fn.Call([]reflect.Value{reflect.ValueOf(t), rows.Elem()})
+		}
+		for _, r := range table.Relations {
+			verifier(t, r, conn, shouldSkipIgnoreInTest)
+		}
+	}
+	return verifier
+}
+
+// scanRowsType returns the []T type of the rows fn, the assertRows of ScanVerifier, is called with
+func scanRowsType(fn reflect.Value) (reflect.Type, error) {
+	if fn.Kind() != reflect.Func || fn.Type().NumIn() != 2 || fn.Type().NumOut() != 0 || fn.Type().In(0) != reflect.TypeOf(&testing.T{}) {
+		return nil, fmt.Errorf("expected a func(*testing.T, []T), got %T", fn.Interface())
+	}
+	rowsType := fn.Type().In(1)
+	if rowsType.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("expected a func(*testing.T, []T), got %s", fn.Type())
+	}
+	elem := rowsType.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected the rows of %s to be structs", fn.Type())
+	}
+	return rowsType, nil
+}
+
+var (
+	scanFirstCapRe = regexp.MustCompile("(.)([A-Z][a-z]+)")
+	scanAllCapRe   = regexp.MustCompile("([a-z0-9])([A-Z])")
+)
+
+// scanColumns returns the columns pgxscan scans into the fields of structType: their db tag, or the snake case of
+// their name. Fields of embedded structs without a db tag are promoted.
+func scanColumns(structType reflect.Type) []string {
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	var columns []string
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, tagged := field.Tag.Lookup("db")
+		tag = strings.Split(tag, ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && !tagged {
+			if t := field.Type; t.Kind() == reflect.Struct || (t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct) {
+				columns = append(columns, scanColumns(t)...)
+			}
+			continue
+		}
+		if !tagged {
+			tag = strings.ToLower(scanAllCapRe.ReplaceAllString(scanFirstCapRe.ReplaceAllString(field.Name, "${1}_${2}"), "${1}_${2}"))
+		}
+		columns = append(columns, tag)
+	}
+	return columns
+}
+
 // VerifyNoEmptyColumnsExcept verifies that for each row in table its columns are not empty except passed
 func VerifyNoEmptyColumnsExcept(tableName string, except ...string) Verifier {
 	return VerifyRowPredicateInTable(tableName, func(t *testing.T, row Row) {
@@ -67,6 +193,16 @@ func VerifyNoEmptyColumnsExcept(tableName string, except ...string) Verifier {
 	})
 }
 
+// NoEmptyColumnsVerifierWhere performs the default non emptiness check of all columns in table and its relations, scoped
+// to the rows of the main table matching filter and the relation rows descending from them. For example, verifying each
+// account separately with sq.Eq{"account_id": id}.
+func NoEmptyColumnsVerifierWhere(filter sq.Sqlizer) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool) {
+		t.Helper()
+		verifyNoEmptyColumns(t, table, conn, shouldSkipIgnoreInTest, nil, nil, filter, 0)
+	}
+}
+
 // VerifyAtMostOneOf verifies that for each row in table at most one column from oneof is not empty
 func VerifyAtMostOneOf(tableName string, oneof ...string) Verifier {
 	return VerifyRowPredicateInTable(tableName, func(t *testing.T, row Row) {
@@ -101,3 +237,848 @@ func VerifyAtLeastOneRow() Verifier {
 		rows.Close()
 	}
 }
+
+// TimestampRangeVerifier verifies all non-null values of a timestamp column are within [min, max], reporting the primary keys of offending rows.
+// It checks every table in the schema (main table and its relations) that declares the column.
+// If min is zero it defaults to 1970-01-01, if max is zero it defaults to one day from now.
+func TimestampRangeVerifier(column string, min, max time.Time) Verifier {
+	if min.IsZero() {
+		min = time.Unix(0, 0).UTC()
+	}
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool) {
+		t.Helper()
+		rangeMax := max
+		if rangeMax.IsZero() {
+			rangeMax = time.Now().UTC().Add(24 * time.Hour)
+		}
+		tables := tablesWithColumn(table, column)
+		if len(tables) == 0 {
+			t.Fatalf("TimestampRangeVerifier failed: column %s doesn't exist in table %s or its relations", column, table.Name)
+		}
+		for _, tbl := range tables {
+			pks := schema.PostgresDialect{}.PrimaryKeys(tbl)
+			query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+				Select(append(quoteIdentifiers(pks), strconv.Quote(column))...).
+				From(strconv.Quote(tbl.Name)).
+				Where(fmt.Sprintf("%[1]s IS NOT NULL AND (%[1]s < ?::timestamp OR %[1]s > ?::timestamp)", strconv.Quote(column)), min, rangeMax).
+				ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rows []Row
+			if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			t.Errorf("TimestampRangeVerifier failed: table %s column %s has %d values outside [%s, %s]: %s",
+				tbl.Name, column, len(rows), min.Format(time.RFC3339), rangeMax.Format(time.RFC3339), strings.Join(timestampRangeOffenders(tbl, column, pks, rows), "; "))
+		}
+	}
+}
+
+// timestampRangeOffenders describes the rows of tbl whose column is out of range by their primary keys and value
+func timestampRangeOffenders(tbl *schema.Table, column string, pks []string, rows []Row) []string {
+	offenders := make([]string, len(rows))
+	for i, row := range rows {
+		offenders[i] = fmt.Sprintf("%s (%s=%v)", formatPrimaryKey(tbl, row, pks), column, maskValue(tbl, column, row[column]))
+	}
+	return offenders
+}
+
+// OrphanVerifier verifies the given relations have no rows whose parent cq_id doesn't exist in their parent table,
+// failing with the primary keys of orphaned rows. Unlike referential integrity it works when foreign keys aren't
+// enforced by the database, e.g. rows orphaned after their parent was overwritten during the fetch.
+// Only relations listed by name are checked.
+func OrphanVerifier(relations ...string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		found := make(map[string]bool, len(relations))
+		verifyNoOrphans(t, table, conn, relations, found)
+		for _, name := range relations {
+			if !found[name] {
+				t.Fatalf("OrphanVerifier failed: relation %s doesn't exist in table %s", name, table.Name)
+			}
+		}
+	}
+}
+
+func verifyNoOrphans(t *testing.T, parent *schema.Table, conn pgxscan.Querier, relations []string, found map[string]bool) {
+	t.Helper()
+	for _, rel := range parent.Relations {
+		verifyNoOrphans(t, rel, conn, relations, found)
+		if !slice.Contains(relations, rel.Name) {
+			continue
+		}
+		found[rel.Name] = true
+		pc := schema.FindParentIdColumn(rel)
+		if pc == nil {
+			t.Fatalf("OrphanVerifier failed: relation %s has no parent id column", rel.Name)
+		}
+		pks := schema.PostgresDialect{}.PrimaryKeys(rel)
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select(quoteIdentifiers(pks)...).
+			From(strconv.Quote(rel.Name) + " c").
+			Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s p WHERE p.cq_id = c.%s)", strconv.Quote(parent.Name), strconv.Quote(pc.Name))).
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []Row
+		if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		orphans := make([]string, len(rows))
+		for i, row := range rows {
+			orphans[i] = formatPrimaryKey(rel, row, pks)
+		}
+		t.Errorf("OrphanVerifier failed: relation %s has %d rows without a parent in %s: %s", rel.Name, len(rows), parent.Name, strings.Join(orphans, "; "))
+	}
+}
+
+// UniqueCQIDsVerifier verifies cq_id is unique in table and its relations, reporting the natural keys of colliding rows.
+// Tables whose primary key is cq_id are skipped, since the database enforces its uniqueness already. Useful when the
+// tables weren't created with the SDK's UNIQUE(cq_id) constraint, e.g. by provider migrations.
+func UniqueCQIDsVerifier() Verifier {
+	var verifier Verifier
+	verifier = func(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool) {
+		t.Helper()
+		for _, rel := range table.Relations {
+			verifier(t, rel, conn, shouldSkipIgnoreInTest)
+		}
+		keys := funk.SubtractString(schema.PostgresDialect{}.PrimaryKeys(table), []string{"cq_id"})
+		if len(keys) == 0 {
+			return
+		}
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select(append([]string{"cq_id"}, quoteIdentifiers(keys)...)...).
+			From(strconv.Quote(table.Name)).
+			Where(fmt.Sprintf("cq_id IN (SELECT cq_id FROM %s GROUP BY cq_id HAVING count(*) > 1)", strconv.Quote(table.Name))).
+			OrderBy("cq_id").
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []Row
+		if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+			t.Fatal(err)
+		}
+		for _, collision := range cqIDCollisions(table, keys, rows) {
+			t.Errorf("UniqueCQIDsVerifier failed: %s", collision)
+		}
+	}
+	return verifier
+}
+
+// cqIDCollisions describes the rows of table sharing a cq_id by their keys, rows are ordered by cq_id
+func cqIDCollisions(table *schema.Table, keys []string, rows []Row) []string {
+	collisions := make(map[string][]string)
+	var ids []string
+	for _, row := range rows {
+		id := fmt.Sprintf("%v", row["cq_id"])
+		if _, ok := collisions[id]; !ok {
+			ids = append(ids, id)
+		}
+		collisions[id] = append(collisions[id], formatPrimaryKey(table, row, keys))
+	}
+	descriptions := make([]string, len(ids))
+	for i, id := range ids {
+		descriptions[i] = fmt.Sprintf("table %s has %d rows with cq_id %s: %s", table.Name, len(collisions[id]), id, strings.Join(collisions[id], "; "))
+	}
+	return descriptions
+}
+
+// BooleanVarietyVerifier verifies a boolean column has both values across the rows of every table in the schema that
+// declares it, reporting the single observed value otherwise. A column that's always false usually means its resolver
+// never sets it. Tables listed in allowConstantIn are skipped, for columns where a constant value is intended.
+func BooleanVarietyVerifier(column string, allowConstantIn ...string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		tables := tablesWithColumn(table, column)
+		if len(tables) == 0 {
+			t.Fatalf("BooleanVarietyVerifier failed: column %s doesn't exist in table %s or its relations", column, table.Name)
+		}
+		for _, tbl := range tables {
+			if slice.Contains(allowConstantIn, tbl.Name) {
+				continue
+			}
+			if c := tbl.Column(column); c.Type != schema.TypeBool {
+				t.Fatalf("BooleanVarietyVerifier failed: column %s of table %s is %s, not a boolean", column, tbl.Name, c.Type)
+			}
+			query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+				Select(strconv.Quote(column)).
+				Distinct().
+				From(strconv.Quote(tbl.Name)).
+				Where(sq.NotEq{strconv.Quote(column): nil}).
+				ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []bool
+			if err := pgxscan.Select(context.Background(), conn, &values, query, args...); err != nil {
+				t.Fatal(err)
+			}
+			if mismatch := constantBoolean(values); mismatch != "" {
+				t.Errorf("BooleanVarietyVerifier failed: table %s column %s %s", tbl.Name, column, mismatch)
+			}
+		}
+	}
+}
+
+// constantBoolean describes the distinct non-null values of a boolean column if it only has one, or returns an empty
+// string. A column without any non-null values is left to the non emptiness check.
+func constantBoolean(values []bool) string {
+	if len(values) != 1 {
+		return ""
+	}
+	return fmt.Sprintf("is %t in every row", values[0])
+}
+
+// EnumCoverageVerifier verifies every one of the expected values of column is observed in at least one row of every
+// table in the schema that declares it, reporting the values never observed. It proves the fetch, or the fixtures or
+// faker data it ran on, covered all of them. If expected is empty the EnumValues of a TypeEnum column are expected.
+func EnumCoverageVerifier(column string, expected []string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		tables := tablesWithColumn(table, column)
+		if len(tables) == 0 {
+			t.Fatalf("EnumCoverageVerifier failed: column %s doesn't exist in table %s or its relations", column, table.Name)
+		}
+		for _, tbl := range tables {
+			values := expected
+			if len(values) == 0 {
+				values = tbl.Column(column).EnumValues
+			}
+			if len(values) == 0 {
+				t.Fatalf("EnumCoverageVerifier failed: no expected values given for column %s of table %s", column, tbl.Name)
+			}
+			query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+				Select(strconv.Quote(column) + "::text").
+				Distinct().
+				From(strconv.Quote(tbl.Name)).
+				Where(sq.NotEq{strconv.Quote(column): nil}).
+				ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var observed []string
+			if err := pgxscan.Select(context.Background(), conn, &observed, query, args...); err != nil {
+				t.Fatal(err)
+			}
+			var missing []string
+			for _, v := range values {
+				if !funk.ContainsString(observed, v) {
+					missing = append(missing, v)
+				}
+			}
+			if len(missing) > 0 {
+				t.Errorf("EnumCoverageVerifier failed: table %s column %s never has the values %s", tbl.Name, column, strings.Join(missing, ", "))
+			}
+		}
+	}
+}
+
+// ValidJSONVerifier verifies all non-null values of a JSON column are a valid JSON object or array, in every table in the
+// schema that declares it, reporting the primary keys of offending rows. For example, a resolver storing a raw string
+// stores a JSON string instead, and a column created as text by provider migrations may hold malformed JSON.
+func ValidJSONVerifier(column string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		tables := tablesWithColumn(table, column)
+		if len(tables) == 0 {
+			t.Fatalf("ValidJSONVerifier failed: column %s doesn't exist in table %s or its relations", column, table.Name)
+		}
+		for _, tbl := range tables {
+			pks := schema.PostgresDialect{}.PrimaryKeys(tbl)
+			query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+				Select(append(quoteIdentifiers(pks), strconv.Quote(column)+"::text AS "+strconv.Quote(column))...).
+				From(strconv.Quote(tbl.Name)).
+				Where(sq.NotEq{strconv.Quote(column): nil}).
+				ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rows []Row
+			if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+				t.Fatal(err)
+			}
+			var offenders []string
+			for _, row := range rows {
+				value, _ := row[column].(string)
+				if err := validateJSONValue(value); err != nil {
+					offenders = append(offenders, fmt.Sprintf("%s (%s)", formatPrimaryKey(tbl, row, pks), err))
+				}
+			}
+			if len(offenders) > 0 {
+				t.Errorf("ValidJSONVerifier failed: table %s column %s has %d invalid JSON values: %s", tbl.Name, column, len(offenders), strings.Join(offenders, "; "))
+			}
+		}
+	}
+}
+
+// validateJSONValue returns an error unless value is a JSON object or array
+func validateJSONValue(value string) error {
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil
+	default:
+		return fmt.Errorf("expected JSON object or array, got %T", v)
+	}
+}
+
+// RelationCardinalityVerifier verifies every parent row has between minPerParent and maxPerParent rows in the given
+// relation, reporting the primary keys of offending parents and their child counts. Parents without children count as
+// zero. A negative maxPerParent means no upper bound.
+func RelationCardinalityVerifier(relation string, minPerParent, maxPerParent int) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		parent, rel := findRelation(table, relation)
+		if rel == nil {
+			t.Fatalf("RelationCardinalityVerifier failed: relation %s doesn't exist in table %s", relation, table.Name)
+		}
+		pc := schema.FindParentIdColumn(rel)
+		if pc == nil {
+			t.Fatalf("RelationCardinalityVerifier failed: relation %s has no parent id column", rel.Name)
+		}
+		pks := schema.PostgresDialect{}.PrimaryKeys(parent)
+		having := sq.Expr("count(c.cq_id) < ?", minPerParent)
+		if maxPerParent >= 0 {
+			having = sq.Or{having, sq.Expr("count(c.cq_id) > ?", maxPerParent)}
+		}
+		columns := make([]string, len(pks))
+		for i, pk := range pks {
+			columns[i] = "p." + strconv.Quote(pk)
+		}
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select(append(columns, "count(c.cq_id) AS child_count")...).
+			From(strconv.Quote(parent.Name) + " p").
+			LeftJoin(fmt.Sprintf("%s c ON c.%s = p.cq_id", strconv.Quote(rel.Name), strconv.Quote(pc.Name))).
+			GroupBy(append([]string{"p.cq_id"}, columns...)...).
+			Having(having).
+			OrderBy(columns...).
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []Row
+		if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) == 0 {
+			return
+		}
+		offenders := make([]string, len(rows))
+		for i, row := range rows {
+			offenders[i] = fmt.Sprintf("%s (%v children)", formatPrimaryKey(parent, row, pks), row["child_count"])
+		}
+		bounds := fmt.Sprintf("[%d, %d]", minPerParent, maxPerParent)
+		if maxPerParent < 0 {
+			bounds = fmt.Sprintf("[%d, inf)", minPerParent)
+		}
+		t.Errorf("RelationCardinalityVerifier failed: %d parents in %s have a number of %s rows outside %s: %s",
+			len(rows), parent.Name, rel.Name, bounds, strings.Join(offenders, "; "))
+	}
+}
+
+// AggregateVerifier verifies the numeric aggregate SQL expression expr, e.g. "SUM(size_bytes)" or "AVG(latency)",
+// computed over the rows of the main table satisfies predicate, failing with the computed value and desc describing
+// the expectation. An aggregate that's NULL, e.g. over an empty table, fails as well.
+func AggregateVerifier(expr string, predicate func(float64) bool, desc string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select(fmt.Sprintf("(%s)::float8", expr)).
+			From(strconv.Quote(table.Name)).
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value *float64
+		if err := pgxscan.Get(context.Background(), conn, &value, query, args...); err != nil {
+			t.Fatalf("AggregateVerifier failed: %s of table %s: %s", expr, table.Name, err)
+		}
+		if value == nil {
+			t.Errorf("AggregateVerifier failed: %s of table %s is NULL, expected %s", expr, table.Name, desc)
+			return
+		}
+		if !predicate(*value) {
+			t.Errorf("AggregateVerifier failed: %s of table %s is %v, expected %s", expr, table.Name, *value, desc)
+		}
+	}
+}
+
+// SyncTimeVerifier verifies all rows of the tables in the schema (main table and its relations) with the SyncTime option
+// share the same _cq_sync_time, i.e. they were all stored by the same fetch, reporting the distinct sync times found
+// per table otherwise. It fails if none of the tables has the option.
+func SyncTimeVerifier() Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		syncTimes := make(map[string][]string)
+		var tables []string
+		var collect func(table *schema.Table)
+		collect = func(table *schema.Table) {
+			if table.Options.SyncTime {
+				var times []string
+				query := fmt.Sprintf("SELECT DISTINCT _cq_sync_time::text FROM %s ORDER BY 1", strconv.Quote(table.Name))
+				if err := pgxscan.Select(context.Background(), conn, &times, query); err != nil {
+					t.Fatal(err)
+				}
+				for _, st := range times {
+					syncTimes[st] = append(syncTimes[st], table.Name)
+				}
+				tables = append(tables, table.Name)
+			}
+			for _, rel := range table.Relations {
+				collect(rel)
+			}
+		}
+		collect(table)
+		if len(tables) == 0 {
+			t.Fatalf("SyncTimeVerifier failed: no table in %s has the SyncTime option", table.Name)
+		}
+		if len(syncTimes) <= 1 {
+			return
+		}
+		found := make([]string, 0, len(syncTimes))
+		for st, tables := range syncTimes {
+			found = append(found, fmt.Sprintf("%s (%s)", st, strings.Join(tables, ",")))
+		}
+		sort.Strings(found)
+		t.Errorf("SyncTimeVerifier failed: rows of %s have %d different sync times: %s", strings.Join(tables, ","), len(syncTimes), strings.Join(found, "; "))
+	}
+}
+
+// ClientCoverageVerifier verifies every one of clients, e.g. the accounts the provider is multiplexed over, has at
+// least one row in table identified by column, reporting the clients that produced nothing. Unlike a table without any
+// rows, this catches issues such as missing permissions affecting only some of the clients. Relations aren't verified,
+// as they may legitimately have no rows for some clients.
+func ClientCoverageVerifier(column string, clients ...string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		if table.Column(column) == nil {
+			t.Fatalf("ClientCoverageVerifier failed: column %s doesn't exist in table %s", column, table.Name)
+		}
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select(strconv.Quote(column) + "::text").
+			Distinct().
+			From(strconv.Quote(table.Name)).
+			Where(sq.NotEq{strconv.Quote(column): nil}).
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var observed []string
+		if err := pgxscan.Select(context.Background(), conn, &observed, query, args...); err != nil {
+			t.Fatal(err)
+		}
+		if missing := funk.SubtractString(clients, observed); len(missing) > 0 {
+			t.Errorf("ClientCoverageVerifier failed: table %s has no rows for the clients %s of column %s", table.Name, strings.Join(missing, ", "), column)
+		}
+	}
+}
+
+// ColumnCommentVerifier verifies every column of the tables in the schema (main table and its relations) declaring a
+// Description has it as its comment in the database, reporting the columns whose comment is missing or differs.
+// migration.CreateTableDefinitions creates the comments, so this catches provider migrations lagging behind the schema.
+func ColumnCommentVerifier() Verifier {
+	var verifier Verifier
+	verifier = func(t *testing.T, table *schema.Table, conn pgxscan.Querier, shouldSkipIgnoreInTest bool) {
+		t.Helper()
+		for _, rel := range table.Relations {
+			verifier(t, rel, conn, shouldSkipIgnoreInTest)
+		}
+		var rows []struct {
+			Name    string  `db:"name"`
+			Comment *string `db:"comment"`
+		}
+		if err := pgxscan.Select(context.Background(), conn, &rows,
+			"SELECT a.attname AS name, col_description(a.attrelid, a.attnum) AS comment FROM pg_attribute a "+
+				"WHERE a.attrelid = to_regclass($1) AND a.attnum > 0 AND NOT a.attisdropped", strconv.Quote(table.Name)); err != nil {
+			t.Fatal(err)
+		}
+		comments := make(map[string]*string, len(rows))
+		for _, row := range rows {
+			comments[row.Name] = row.Comment
+		}
+		for _, mismatch := range commentMismatches(table, comments) {
+			t.Errorf("ColumnCommentVerifier failed: %s", mismatch)
+		}
+	}
+	return verifier
+}
+
+// commentMismatches returns the columns of table whose Description differs from their comment in comments, which maps
+// the names of the database columns to their comment, nil if they have none
+func commentMismatches(table *schema.Table, comments map[string]*string) []string {
+	var mismatches []string
+	for _, c := range table.Columns {
+		if c.Description == "" {
+			continue
+		}
+		comment, ok := comments[c.Name]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, fmt.Sprintf("column %s of table %s doesn't exist in the database", c.Name, table.Name))
+		case comment == nil:
+			mismatches = append(mismatches, fmt.Sprintf("column %s of table %s has no comment, expected %q", c.Name, table.Name, c.Description))
+		case *comment != c.Description:
+			mismatches = append(mismatches, fmt.Sprintf("column %s of table %s has comment %q, expected %q", c.Name, table.Name, *comment, c.Description))
+		}
+	}
+	return mismatches
+}
+
+// NumericPrecisionVerifier verifies column, a TypeNumeric column, is stored exactly in every table of the schema (main
+// table and its relations) declaring it: the database column must be numeric of the declared Precision and Scale,
+// rather than e.g. a double precision column rounding values, and the stored values must read back as decimals fitting
+// them, failing with the offending values otherwise.
+func NumericPrecisionVerifier(column string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		tables := tablesWithColumn(table, column)
+		if len(tables) == 0 {
+			t.Fatalf("NumericPrecisionVerifier failed: column %s doesn't exist in table %s or its relations", column, table.Name)
+		}
+		for _, tbl := range tables {
+			c := tbl.Column(column)
+			var info struct {
+				DataType  string `db:"data_type"`
+				Precision *int   `db:"numeric_precision"`
+				Scale     *int   `db:"numeric_scale"`
+			}
+			if err := pgxscan.Get(context.Background(), conn, &info,
+				"SELECT data_type, numeric_precision, numeric_scale FROM information_schema.columns "+
+					"WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2", tbl.Name, column); err != nil {
+				t.Fatal(err)
+			}
+			if mismatch := numericColumnMismatch(*c, info.DataType, info.Precision, info.Scale); mismatch != "" {
+				t.Errorf("NumericPrecisionVerifier failed: column %s of table %s %s", column, tbl.Name, mismatch)
+				continue
+			}
+			query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+				Select(strconv.Quote(column) + "::text").
+				From(strconv.Quote(tbl.Name)).
+				Where(sq.NotEq{strconv.Quote(column): nil}).
+				ToSql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []string
+			if err := pgxscan.Select(context.Background(), conn, &values, query, args...); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range values {
+				if err := c.ValidateType(v); err != nil {
+					t.Errorf("NumericPrecisionVerifier failed: table %s: %s", tbl.Name, err)
+				}
+			}
+		}
+	}
+}
+
+// numericColumnMismatch describes how the database column of c, of the given data type, precision and scale as in
+// information_schema.columns, doesn't store c's values exactly, or returns an empty string if it does
+func numericColumnMismatch(c schema.Column, dataType string, precision, scale *int) string {
+	if c.Type != schema.TypeNumeric {
+		return fmt.Sprintf("is declared as %s, expected %s", c.Type, schema.TypeNumeric)
+	}
+	if dataType != "numeric" {
+		return fmt.Sprintf("is stored as %s, expected numeric", dataType)
+	}
+	if c.Precision <= 0 {
+		if precision != nil {
+			return fmt.Sprintf("is stored as numeric(%d,%d), expected numeric of any precision", *precision, derefInt(scale))
+		}
+		return ""
+	}
+	if precision == nil || *precision != c.Precision || derefInt(scale) != c.Scale {
+		stored := "numeric of any precision"
+		if precision != nil {
+			stored = fmt.Sprintf("numeric(%d,%d)", *precision, derefInt(scale))
+		}
+		return fmt.Sprintf("is stored as %s, expected numeric(%d,%d)", stored, c.Precision, c.Scale)
+	}
+	return ""
+}
+
+func derefInt(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+// ParentLinkVerifier verifies the rows of relation reference the parent row that produced them, not merely an existing
+// one: the parent of each row is traced by links, mapping columns of relation to the parent's columns they're resolved
+// from, e.g. {"instance_id": "id"}, and its cq_id must equal the row's parent id column. This catches resolvers setting
+// the id of the wrong parent, which OrphanVerifier can't. A sample of sampleSize rows of relation is verified, ordered by
+// cq_id so the sample is the same between runs, or all rows if sampleSize isn't positive.
+func ParentLinkVerifier(relation string, sampleSize int, links map[string]string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		parent, rel := findRelation(table, relation)
+		if rel == nil {
+			t.Fatalf("ParentLinkVerifier failed: relation %s doesn't exist in table %s", relation, table.Name)
+		}
+		if len(links) == 0 {
+			t.Fatalf("ParentLinkVerifier failed: no columns linking relation %s to its parent %s", relation, parent.Name)
+		}
+		pc := schema.FindParentIdColumn(rel)
+		if pc == nil {
+			t.Fatalf("ParentLinkVerifier failed: relation %s has no parent id column", rel.Name)
+		}
+		children := make([]string, 0, len(links))
+		for child := range links {
+			children = append(children, child)
+		}
+		sort.Strings(children)
+		on := make([]string, len(children))
+		for i, child := range children {
+			on[i] = fmt.Sprintf("p.%s = c.%s", strconv.Quote(links[child]), strconv.Quote(child))
+		}
+		pks := schema.PostgresDialect{}.PrimaryKeys(rel)
+		columns := []string{fmt.Sprintf("c.%s::text AS parent_cq_id", strconv.Quote(pc.Name))}
+		for _, pk := range pks {
+			columns = append(columns, fmt.Sprintf("c.%[1]s::text AS %[1]s", strconv.Quote(pk)))
+		}
+		sample := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).Select("*").From(strconv.Quote(rel.Name)).OrderBy("cq_id")
+		if sampleSize > 0 {
+			sample = sample.Limit(uint64(sampleSize))
+		}
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select(append(columns, "string_agg(p.cq_id::text, ',' ORDER BY p.cq_id) AS linked_cq_ids")...).
+			FromSelect(sample, "c").
+			LeftJoin(fmt.Sprintf("%s p ON %s", strconv.Quote(parent.Name), strings.Join(on, " AND "))).
+			GroupBy(append([]string{"c.cq_id", "c." + strconv.Quote(pc.Name)}, prefixIdentifiers("c.", pks)...)...).
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []Row
+		if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+			t.Fatal(err)
+		}
+		for _, mismatch := range parentLinkMismatches(parent, rel, pks, children, links, rows) {
+			t.Errorf("ParentLinkVerifier failed: %s", mismatch)
+		}
+	}
+}
+
+// parentLinkMismatches returns the rows of rel whose parent_cq_id isn't one of the cq_ids of the parent rows linked to
+// them, linked_cq_ids holding them comma separated or nil if no parent row matches the row's links
+func parentLinkMismatches(parent, rel *schema.Table, pks, children []string, links map[string]string, rows []Row) []string {
+	var mismatches []string
+	for _, row := range rows {
+		linked, _ := row["linked_cq_ids"].(string)
+		if linked == "" {
+			link := make([]string, len(children))
+			for i, child := range children {
+				link[i] = fmt.Sprintf("%s=%s", links[child], child)
+			}
+			mismatches = append(mismatches, fmt.Sprintf("relation %s row %s has no parent in %s matching %s", rel.Name, formatPrimaryKey(rel, row, pks), parent.Name, strings.Join(link, ",")))
+			continue
+		}
+		ids := strings.Split(linked, ",")
+		if actual, _ := row["parent_cq_id"].(string); !funk.ContainsString(ids, actual) {
+			mismatches = append(mismatches, fmt.Sprintf("relation %s row %s references parent %v, expected %s", rel.Name, formatPrimaryKey(rel, row, pks), row["parent_cq_id"], strings.Join(ids, " or ")))
+		}
+	}
+	return mismatches
+}
+
+// ParentIDLeakVerifier verifies the parent id column of every relation of table (recursively) only holds cq_ids of its
+// declared parent table, failing with the leaked ids and the table they belong to. This is stronger than OrphanVerifier:
+// a relation resolved with the item of a sibling table has parent ids which exist, only in the wrong table. Ids found in
+// no table of the schema are reported as well. Only ids missing from the declared parent are leaks, ids of the parent
+// found in other tables too aren't, as cq_ids derived from primary keys (see schema.Table Options) may collide.
+func ParentIDLeakVerifier() Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		verifyNoParentIDLeaks(t, table, table, conn)
+	}
+}
+
+func verifyNoParentIDLeaks(t *testing.T, root, parent *schema.Table, conn pgxscan.Querier) {
+	t.Helper()
+	for _, rel := range parent.Relations {
+		verifyNoParentIDLeaks(t, root, rel, conn)
+		pc := schema.FindParentIdColumn(rel)
+		if pc == nil {
+			continue
+		}
+		// ids missing from the declared parent are leaked, every other table of the schema is a possible source of them
+		var sources []string
+		var args []interface{}
+		for _, other := range schemaTables(root) {
+			if other != parent {
+				sources = append(sources, fmt.Sprintf("SELECT cq_id, ?::text AS source FROM %s", strconv.Quote(other.Name)))
+				args = append(args, other.Name)
+			}
+		}
+		column := "c." + strconv.Quote(pc.Name)
+		query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select(column+"::text AS leaked_cq_id", "s.source").
+			Distinct().
+			From(strconv.Quote(rel.Name)+" c").
+			LeftJoin(fmt.Sprintf("(%s) s ON s.cq_id = %s", strings.Join(sources, " UNION ALL "), column), args...).
+			Where(fmt.Sprintf("%[1]s IS NOT NULL AND NOT EXISTS (SELECT 1 FROM %[2]s p WHERE p.cq_id = %[1]s)", column, strconv.Quote(parent.Name))).
+			OrderBy("s.source", "leaked_cq_id").
+			ToSql()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []Row
+		if err := pgxscan.Select(context.Background(), conn, &rows, query, args...); err != nil {
+			t.Fatal(err)
+		}
+		for _, leak := range parentIDLeaks(parent, rel, rows) {
+			t.Errorf("ParentIDLeakVerifier failed: %s", leak)
+		}
+	}
+}
+
+// parentIDLeaks groups the leaked_cq_id of rows by the source table they belong to, nil if they're in no table
+func parentIDLeaks(parent, rel *schema.Table, rows []Row) []string {
+	var sources []string
+	leaked := make(map[string][]string)
+	for _, row := range rows {
+		source, _ := row["source"].(string)
+		if _, ok := leaked[source]; !ok {
+			sources = append(sources, source)
+		}
+		leaked[source] = append(leaked[source], fmt.Sprintf("%v", row["leaked_cq_id"]))
+	}
+	leaks := make([]string, len(sources))
+	for i, source := range sources {
+		if source == "" {
+			leaks[i] = fmt.Sprintf("relation %s references %d ids of no table instead of its parent %s: %s", rel.Name, len(leaked[source]), parent.Name, strings.Join(leaked[source], ", "))
+			continue
+		}
+		leaks[i] = fmt.Sprintf("relation %s references %d ids of %s instead of its parent %s: %s", rel.Name, len(leaked[source]), source, parent.Name, strings.Join(leaked[source], ", "))
+	}
+	return leaks
+}
+
+// ColumnOrderVerifier verifies the columns of tableName, the main table or one of its relations, are ordered in the
+// database as in expected, the canonical order downstream consumers rely on, failing with the actual and expected
+// orders otherwise. Unlike AssertDDLColumnOrder it checks the live table, e.g. as left by the provider's migrations.
+func ColumnOrderVerifier(tableName string, expected []string) Verifier {
+	return func(t *testing.T, table *schema.Table, conn pgxscan.Querier, _ bool) {
+		t.Helper()
+		if tableName != table.Name {
+			if _, rel := findRelation(table, tableName); rel == nil {
+				t.Fatalf("ColumnOrderVerifier failed: table %s doesn't exist in table %s or its relations", tableName, table.Name)
+			}
+		}
+		var actual []string
+		if err := pgxscan.Select(context.Background(), conn, &actual,
+			"SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position", tableName); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("ColumnOrderVerifier failed: table %s has columns ordered %s, expected %s", tableName, strings.Join(actual, ", "), strings.Join(expected, ", "))
+		}
+	}
+}
+
+// PartitionColumnVerifier verifies every relation of table (recursively) declares column, the partition column of the
+// main table, so the whole subtree can be partitioned by it and its rows stored together with their parent's. It fails
+// listing the relations missing the column, and if the main table doesn't declare it either.
+func PartitionColumnVerifier(column string) Verifier {
+	return func(t *testing.T, table *schema.Table, _ pgxscan.Querier, _ bool) {
+		t.Helper()
+		if table.Column(column) == nil {
+			t.Fatalf("PartitionColumnVerifier failed: partition column %s doesn't exist in table %s", column, table.Name)
+		}
+		if missing := relationsMissingColumn(table, column); len(missing) > 0 {
+			t.Errorf("PartitionColumnVerifier failed: relations of %s don't declare partition column %s: %s", table.Name, column, strings.Join(missing, ", "))
+		}
+	}
+}
+
+// relationsMissingColumn returns the names of the relations of table (recursively) which don't declare column
+func relationsMissingColumn(table *schema.Table, column string) []string {
+	var missing []string
+	for _, rel := range table.Relations {
+		if rel.Column(column) == nil {
+			missing = append(missing, rel.Name)
+		}
+		missing = append(missing, relationsMissingColumn(rel, column)...)
+	}
+	return missing
+}
+
+// findRelation returns the relation with the given name among table's relations (recursively), and its parent
+func findRelation(table *schema.Table, name string) (parent, relation *schema.Table) {
+	for _, rel := range table.Relations {
+		if rel.Name == name {
+			return table, rel
+		}
+		if parent, relation := findRelation(rel, name); relation != nil {
+			return parent, relation
+		}
+	}
+	return nil, nil
+}
+
+// schemaTables returns table and its relations (recursively)
+func schemaTables(table *schema.Table) []*schema.Table {
+	tables := []*schema.Table{table}
+	for _, rel := range table.Relations {
+		tables = append(tables, schemaTables(rel)...)
+	}
+	return tables
+}
+
+// tablesWithColumn returns table and its relations (recursively) which declare the given column
+func tablesWithColumn(table *schema.Table, column string) []*schema.Table {
+	var tables []*schema.Table
+	if table.Column(column) != nil {
+		tables = append(tables, table)
+	}
+	for _, rel := range table.Relations {
+		tables = append(tables, tablesWithColumn(rel, column)...)
+	}
+	return tables
+}
+
+// formatPrimaryKey formats the primary key values of row as "pk1=v1,pk2=v2", masking the values of sensitive columns
+func formatPrimaryKey(table *schema.Table, row Row, pks []string) string {
+	kv := make([]string, len(pks))
+	for i, pk := range pks {
+		kv[i] = fmt.Sprintf("%s=%v", pk, maskValue(table, pk, row[pk]))
+	}
+	return strings.Join(kv, ",")
+}
+
+// maskValue returns the value of a table's column for rendering in test output, see schema.Column Mask
+func maskValue(table *schema.Table, column string, v interface{}) interface{} {
+	if c := table.Column(column); c != nil {
+		return c.Mask(v)
+	}
+	return v
+}
+
+func quoteIdentifiers(identifiers []string) []string {
+	ret := make([]string, len(identifiers))
+	for i, v := range identifiers {
+		ret[i] = strconv.Quote(v)
+	}
+	return ret
+}
+
+func prefixIdentifiers(prefix string, identifiers []string) []string {
+	ret := make([]string, len(identifiers))
+	for i, v := range identifiers {
+		ret[i] = prefix + strconv.Quote(v)
+	}
+	return ret
+}
@@ -0,0 +1,536 @@
+package testing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/cloudquery/cq-provider-sdk/provider/schema"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJSONValue(t *testing.T) {
+	for _, v := range []string{`{}`, `{"key": "value"}`, `[]`, `[1, {"key": null}]`} {
+		assert.NoError(t, validateJSONValue(v), v)
+	}
+	assert.EqualError(t, validateJSONValue(`"{\"key\": \"value\"}"`), "expected JSON object or array, got string")
+	assert.EqualError(t, validateJSONValue(`5`), "expected JSON object or array, got float64")
+	assert.EqualError(t, validateJSONValue(`null`), "expected JSON object or array, got <nil>")
+	assert.Error(t, validateJSONValue(`{"key": `))
+	assert.Error(t, validateJSONValue(``))
+}
+
+func TestIsEmptyColumnValue(t *testing.T) {
+	assert.True(t, isEmptyColumnValue("tags", []interface{}{}, []string{"tags"}))
+	assert.False(t, isEmptyColumnValue("tags", []interface{}{"a"}, []string{"tags"}))
+	assert.False(t, isEmptyColumnValue("tags", nil, []string{"tags"}))
+	// empty arrays of columns not opted in are populated values
+	assert.False(t, isEmptyColumnValue("names", []interface{}{}, []string{"tags"}))
+}
+
+func TestCheckEncryptedValues(t *testing.T) {
+	xor := func(b []byte) ([]byte, error) {
+		r := make([]byte, len(b))
+		for i := range b {
+			r[i] = b[i] ^ 0xff
+		}
+		return r, nil
+	}
+	c := schema.Column{Name: "token", Type: schema.TypeString, Encrypt: xor, Decrypt: xor}
+	ciphertext, _ := xor([]byte("secret"))
+	assert.NoError(t, checkEncryptedValues(c, [][]byte{ciphertext}))
+	assert.NoError(t, checkEncryptedValues(c, nil))
+
+	identity := func(b []byte) ([]byte, error) { return b, nil }
+	c.Encrypt, c.Decrypt = identity, identity
+	assert.EqualError(t, checkEncryptedValues(c, [][]byte{[]byte("secret")}), "column token is stored as plaintext, its Encrypt function doesn't encrypt")
+
+	c.Decrypt = func([]byte) ([]byte, error) { return nil, errors.New("bad key") }
+	assert.EqualError(t, checkEncryptedValues(c, [][]byte{ciphertext}), "failed to decrypt a stored value of column token: bad key")
+
+	c.Decrypt = nil
+	assert.EqualError(t, checkEncryptedValues(c, nil), "column token has Encrypt set without Decrypt, its stored values can't be verified")
+}
+
+func TestVerifyRowPredicateInTable_Decrypts(t *testing.T) {
+	xor := func(b []byte) ([]byte, error) {
+		r := make([]byte, len(b))
+		for i := range b {
+			r[i] = b[i] ^ 0xff
+		}
+		return r, nil
+	}
+	ciphertext, _ := xor([]byte("secret"))
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte("json_agg"), DataTypeOID: pgtype.JSONOID, Format: pgx.TextFormatCode}},
+		values:   [][][]byte{{[]byte(`[{"id": "a", "token": "\\x` + hex.EncodeToString(ciphertext) + `"}, {"id": "b", "token": null}]`)}},
+		current:  -1,
+	}}
+	table := &schema.Table{Name: "test_secrets", Columns: []schema.Column{
+		{Name: "id", Type: schema.TypeString},
+		{Name: "token", Type: schema.TypeString, Encrypt: xor, Decrypt: xor},
+	}}
+	var rows []Row
+	VerifyRowPredicateInTable("test_secrets", func(_ *testing.T, row Row) { rows = append(rows, row) })(t, table, conn, false)
+	assert.Equal(t, []Row{{"id": "a", "token": "secret"}, {"id": "b", "token": nil}}, rows)
+
+	row := map[string]interface{}{"token": "not hex"}
+	assert.Error(t, decryptRow(table, row))
+	table.Columns[1].Decrypt = func([]byte) ([]byte, error) { return nil, errors.New("bad key") }
+	row = map[string]interface{}{"token": `\x` + hex.EncodeToString(ciphertext)}
+	assert.EqualError(t, decryptRow(table, row), "failed to decrypt column token: bad key")
+}
+
+// instance is a domain struct the rows of a table are scanned into
+type instance struct {
+	ID       string `db:"id"`
+	CPUCount int64
+	Ignored  string `db:"-"`
+	instanceMeta
+}
+
+type instanceMeta struct {
+	Region string
+}
+
+// staticQuerier returns rows for every query, recording the last one and its arguments
+type staticQuerier struct {
+	rows  *bufferedRows
+	query string
+	args  []interface{}
+}
+
+func (q *staticQuerier) Query(_ context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	q.query, q.args = query, args
+	return q.rows, nil
+}
+
+func TestNumericColumnMismatch(t *testing.T) {
+	five, two := 5, 2
+	price := schema.Column{Name: "price", Type: schema.TypeNumeric, Precision: 5, Scale: 2}
+	assert.Empty(t, numericColumnMismatch(price, "numeric", &five, &two))
+	assert.Equal(t, "is stored as double precision, expected numeric", numericColumnMismatch(price, "double precision", nil, nil))
+	assert.Equal(t, "is stored as numeric of any precision, expected numeric(5,2)", numericColumnMismatch(price, "numeric", nil, nil))
+	assert.Equal(t, "is stored as numeric(5,5), expected numeric(5,2)", numericColumnMismatch(price, "numeric", &five, &five))
+
+	amount := schema.Column{Name: "amount", Type: schema.TypeNumeric}
+	assert.Empty(t, numericColumnMismatch(amount, "numeric", nil, nil))
+	assert.Equal(t, "is stored as numeric(5,2), expected numeric of any precision", numericColumnMismatch(amount, "numeric", &five, &two))
+	assert.Equal(t, "is declared as TypeFloat, expected TypeNumeric", numericColumnMismatch(schema.Column{Type: schema.TypeFloat}, "double precision", nil, nil))
+}
+
+func TestParentLinkVerifier(t *testing.T) {
+	text := func(name string) pgproto3.FieldDescription {
+		return pgproto3.FieldDescription{Name: []byte(name), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}
+	}
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{text("parent_cq_id"), text("id"), text("linked_cq_ids")},
+		values:   [][][]byte{{[]byte("p1"), []byte("disk-1"), []byte("p1")}, {[]byte("p2"), []byte("disk-2"), []byte("p0,p2")}},
+		current:  -1,
+	}}
+	rel := &schema.Table{
+		Name: "test_instance_disks",
+		Columns: []schema.Column{
+			{Name: "instance_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver},
+			{Name: "instance_id", Type: schema.TypeString},
+			{Name: "id", Type: schema.TypeString},
+		},
+		Options: schema.TableCreationOptions{PrimaryKeys: []string{"id"}},
+	}
+	table := &schema.Table{Name: "test_instances", Columns: []schema.Column{{Name: "id", Type: schema.TypeString}}, Relations: []*schema.Table{rel}}
+	links := map[string]string{"instance_id": "id"}
+	ParentLinkVerifier("test_instance_disks", 100, links)(t, table, conn, false)
+	assert.Equal(t, `SELECT c."instance_cq_id"::text AS parent_cq_id, c."id"::text AS "id", string_agg(p.cq_id::text, ',' ORDER BY p.cq_id) AS linked_cq_ids `+
+		`FROM (SELECT * FROM "test_instance_disks" ORDER BY cq_id LIMIT 100) AS c LEFT JOIN "test_instances" p ON p."id" = c."instance_id" GROUP BY c.cq_id, c."instance_cq_id", c."id"`, conn.query)
+
+	assert.Equal(t, []string{
+		"relation test_instance_disks row id=disk-2 references parent p2, expected p0 or p1",
+		"relation test_instance_disks row id=disk-3 has no parent in test_instances matching id=instance_id",
+	}, parentLinkMismatches(table, rel, []string{"id"}, []string{"instance_id"}, links, []Row{
+		{"parent_cq_id": "p1", "id": "disk-1", "linked_cq_ids": "p1"},
+		{"parent_cq_id": "p2", "id": "disk-2", "linked_cq_ids": "p0,p1"},
+		{"parent_cq_id": "p3", "id": "disk-3", "linked_cq_ids": nil},
+	}))
+}
+
+func TestParentIDLeakVerifier(t *testing.T) {
+	text := func(name string) pgproto3.FieldDescription {
+		return pgproto3.FieldDescription{Name: []byte(name), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}
+	}
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{text("leaked_cq_id"), text("source")},
+		current:  -1,
+	}}
+	rel := &schema.Table{
+		Name:    "test_instance_disks",
+		Columns: []schema.Column{{Name: "instance_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver}},
+	}
+	table := &schema.Table{
+		Name: "test_account",
+		Relations: []*schema.Table{
+			{Name: "test_instances", Relations: []*schema.Table{rel}},
+			{Name: "test_volumes"},
+		},
+	}
+	ParentIDLeakVerifier()(t, table, conn, false)
+	assert.Equal(t, `SELECT DISTINCT c."instance_cq_id"::text AS leaked_cq_id, s.source FROM "test_instance_disks" c `+
+		`LEFT JOIN (SELECT cq_id, $1::text AS source FROM "test_account" UNION ALL SELECT cq_id, $2::text AS source FROM "test_instance_disks" `+
+		`UNION ALL SELECT cq_id, $3::text AS source FROM "test_volumes") s ON s.cq_id = c."instance_cq_id" `+
+		`WHERE c."instance_cq_id" IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "test_instances" p WHERE p.cq_id = c."instance_cq_id") `+
+		`ORDER BY s.source, leaked_cq_id`, conn.query)
+
+	assert.Equal(t, []string{
+		"relation test_instance_disks references 2 ids of test_volumes instead of its parent test_instances: v1, v2",
+		"relation test_instance_disks references 1 ids of no table instead of its parent test_instances: x1",
+	}, parentIDLeaks(table.Relations[0], rel, []Row{
+		{"leaked_cq_id": "v1", "source": "test_volumes"},
+		{"leaked_cq_id": "v2", "source": "test_volumes"},
+		{"leaked_cq_id": "x1", "source": nil},
+	}))
+}
+
+func TestColumnOrderVerifier(t *testing.T) {
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte("column_name"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}},
+		values:   [][][]byte{{[]byte("cq_id")}, {[]byte("instance_cq_id")}, {[]byte("id")}},
+		current:  -1,
+	}}
+	rel := &schema.Table{Name: "test_instance_disks", Columns: []schema.Column{{Name: "id", Type: schema.TypeString}}}
+	table := &schema.Table{Name: "test_instances", Relations: []*schema.Table{rel}}
+	ColumnOrderVerifier("test_instance_disks", []string{"cq_id", "instance_cq_id", "id"})(t, table, conn, false)
+	assert.Equal(t, "SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position", conn.query)
+}
+
+func TestRelationsMissingColumn(t *testing.T) {
+	table := &schema.Table{
+		Name:    "test_accounts",
+		Columns: []schema.Column{{Name: "region", Type: schema.TypeString}},
+		Relations: []*schema.Table{
+			{
+				Name:    "test_account_users",
+				Columns: []schema.Column{{Name: "region", Type: schema.TypeString}},
+				Relations: []*schema.Table{
+					{Name: "test_account_user_keys", Columns: []schema.Column{{Name: "id", Type: schema.TypeString}}},
+				},
+			},
+			{Name: "test_account_roles", Columns: []schema.Column{{Name: "id", Type: schema.TypeString}}},
+		},
+	}
+	assert.Equal(t, []string{"test_account_user_keys", "test_account_roles"}, relationsMissingColumn(table, "region"))
+	assert.Empty(t, relationsMissingColumn(table.Relations[0].Relations[0], "region"))
+}
+
+func TestClientCoverageVerifier(t *testing.T) {
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte("account_id"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}},
+		values:   [][][]byte{{[]byte("111")}, {[]byte("222")}},
+		current:  -1,
+	}}
+	table := &schema.Table{Name: "test_instances", Columns: []schema.Column{{Name: "account_id", Type: schema.TypeString}}}
+	ClientCoverageVerifier("account_id", "222", "111")(t, table, conn, false)
+	assert.Equal(t, `SELECT DISTINCT "account_id"::text FROM "test_instances" WHERE "account_id" IS NOT NULL`, conn.query)
+}
+
+func TestCommentMismatches(t *testing.T) {
+	documented, stale := "The name of the instance", "Old description"
+	table := &schema.Table{
+		Name: "test_instances",
+		Columns: []schema.Column{
+			{Name: "name", Type: schema.TypeString, Description: documented},
+			{Name: "region", Type: schema.TypeString, Description: "The region of the instance"},
+			{Name: "state", Type: schema.TypeString, Description: "The state of the instance"},
+			{Name: "missing", Type: schema.TypeString, Description: "Not migrated"},
+			{Name: "undocumented", Type: schema.TypeString},
+		},
+	}
+	assert.Equal(t, []string{
+		`column region of table test_instances has no comment, expected "The region of the instance"`,
+		`column state of table test_instances has comment "Old description", expected "The state of the instance"`,
+		`column missing of table test_instances doesn't exist in the database`,
+	}, commentMismatches(table, map[string]*string{
+		"name":         &documented,
+		"region":       nil,
+		"state":        &stale,
+		"undocumented": &stale,
+	}))
+}
+
+func TestScanVerifier(t *testing.T) {
+	assert.Equal(t, []string{"id", "cpu_count", "region"}, scanColumns(reflect.TypeOf(instance{})))
+
+	_, err := scanRowsType(reflect.ValueOf(func(*testing.T, []string) {}))
+	assert.EqualError(t, err, "expected the rows of func(*testing.T, []string) to be structs")
+	_, err = scanRowsType(reflect.ValueOf(func([]instance) {}))
+	assert.EqualError(t, err, "expected a func(*testing.T, []T), got func([]testing.instance)")
+
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields: []pgproto3.FieldDescription{
+			{Name: []byte("id"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode},
+			{Name: []byte("cpu_count"), DataTypeOID: pgtype.Int8OID, Format: pgx.TextFormatCode},
+			{Name: []byte("region"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode},
+		},
+		values:  [][][]byte{{[]byte("i-1"), []byte("4"), []byte("us-east-1")}},
+		current: -1,
+	}}
+	var scanned []*instance
+	table := &schema.Table{Name: "parent", Relations: []*schema.Table{{Name: "test_instances"}}}
+	ScanVerifier("test_instances", func(t *testing.T, rows []*instance) {
+		scanned = rows
+	})(t, table, conn, false)
+	assert.Equal(t, `SELECT "id", "cpu_count", "region" FROM "test_instances"`, conn.query)
+	assert.Equal(t, []*instance{{ID: "i-1", CPUCount: 4, instanceMeta: instanceMeta{Region: "us-east-1"}}}, scanned)
+}
+
+func ExampleScanVerifier() {
+	type instance struct {
+		ID         string    `db:"id"`
+		LaunchTime time.Time `db:"launch_time"`
+		CPUCount   int64     `db:"cpu_count"`
+	}
+	_ = ResourceTestCase{
+		Verifiers: map[string][]Verifier{
+			"ec2.instances": {
+				ScanVerifier("aws_ec2_instances", func(t *testing.T, rows []instance) {
+					for _, i := range rows {
+						assert.Positive(t, i.CPUCount, i.ID)
+						assert.True(t, i.LaunchTime.Before(time.Now()), i.ID)
+					}
+				}),
+			},
+		},
+	}
+}
+
+func TestTimestampRangeVerifier(t *testing.T) {
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte("id"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}},
+		current:  -1,
+	}}
+	rel := &schema.Table{
+		Name:    "test_instance_events",
+		Columns: []schema.Column{{Name: "id", Type: schema.TypeString}, {Name: "created_at", Type: schema.TypeTimestamp}},
+		Options: schema.TableCreationOptions{PrimaryKeys: []string{"id"}},
+	}
+	table := &schema.Table{Name: "test_instances", Columns: []schema.Column{{Name: "id", Type: schema.TypeString}}, Relations: []*schema.Table{rel}}
+	min, max := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	TimestampRangeVerifier("created_at", min, max)(t, table, conn, false)
+	assert.Equal(t, `SELECT "id", "created_at" FROM "test_instance_events" WHERE "created_at" IS NOT NULL AND ("created_at" < $1::timestamp OR "created_at" > $2::timestamp)`, conn.query)
+	assert.Equal(t, []interface{}{min, max}, conn.args)
+
+	// a zero min defaults to the unix epoch
+	TimestampRangeVerifier("created_at", time.Time{}, max)(t, table, conn, false)
+	assert.Equal(t, []interface{}{time.Unix(0, 0).UTC(), max}, conn.args)
+
+	rel.Columns = append(rel.Columns, schema.Column{Name: "owner", Type: schema.TypeString, Sensitive: true})
+	assert.Equal(t, []string{"id=e-1 (created_at=1969-12-31 00:00:00)", "id=e-2 (created_at=2999-01-01 00:00:00)"},
+		timestampRangeOffenders(rel, "created_at", []string{"id"}, []Row{
+			{"id": "e-1", "created_at": "1969-12-31 00:00:00"},
+			{"id": "e-2", "created_at": "2999-01-01 00:00:00"},
+		}))
+	assert.Equal(t, []string{"owner=" + schema.MaskedValue + " (created_at=1969-12-31 00:00:00)"},
+		timestampRangeOffenders(rel, "created_at", []string{"owner"}, []Row{{"owner": "alice", "created_at": "1969-12-31 00:00:00"}}))
+}
+
+func TestOrphanVerifier(t *testing.T) {
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte("id"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}},
+		current:  -1,
+	}}
+	rel := &schema.Table{
+		Name: "test_instance_disks",
+		Columns: []schema.Column{
+			{Name: "instance_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver},
+			{Name: "id", Type: schema.TypeString},
+		},
+		Options: schema.TableCreationOptions{PrimaryKeys: []string{"id"}},
+	}
+	table := &schema.Table{
+		Name:      "test_accounts",
+		Relations: []*schema.Table{{Name: "test_instances", Relations: []*schema.Table{rel}}, {Name: "test_volumes"}},
+	}
+	// only the listed relations are checked, however deep
+	OrphanVerifier("test_instance_disks")(t, table, conn, false)
+	assert.Equal(t, `SELECT "id" FROM "test_instance_disks" c WHERE NOT EXISTS (SELECT 1 FROM "test_instances" p WHERE p.cq_id = c."instance_cq_id")`, conn.query)
+
+	rel.Options.PrimaryKeys = nil
+	OrphanVerifier("test_instance_disks")(t, table, conn, false)
+	assert.Equal(t, `SELECT "cq_id" FROM "test_instance_disks" c WHERE NOT EXISTS (SELECT 1 FROM "test_instances" p WHERE p.cq_id = c."instance_cq_id")`, conn.query)
+}
+
+func TestUniqueCQIDsVerifier(t *testing.T) {
+	conn := &staticQuerier{rows: &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte("cq_id"), DataTypeOID: pgtype.TextOID, Format: pgx.TextFormatCode}},
+		current:  -1,
+	}}
+	rel := &schema.Table{
+		Name:    "test_instance_disks",
+		Columns: []schema.Column{{Name: "region", Type: schema.TypeString}, {Name: "id", Type: schema.TypeString}},
+		Options: schema.TableCreationOptions{PrimaryKeys: []string{"region", "id"}},
+	}
+	// tables keyed by cq_id are skipped, their cq_ids are unique already
+	table := &schema.Table{Name: "test_instances", Relations: []*schema.Table{rel}}
+	UniqueCQIDsVerifier()(t, table, conn, false)
+	assert.Equal(t, `SELECT cq_id, "region", "id" FROM "test_instance_disks" WHERE cq_id IN (SELECT cq_id FROM "test_instance_disks" GROUP BY cq_id HAVING count(*) > 1) ORDER BY cq_id`, conn.query)
+
+	assert.Equal(t, []string{
+		"table test_instance_disks has 2 rows with cq_id a: region=us-east-1,id=d-1; region=us-west-2,id=d-1",
+		"table test_instance_disks has 3 rows with cq_id b: region=us-east-1,id=d-2; region=us-east-1,id=d-3; region=us-east-1,id=d-4",
+	}, cqIDCollisions(rel, []string{"region", "id"}, []Row{
+		{"cq_id": "a", "region": "us-east-1", "id": "d-1"},
+		{"cq_id": "a", "region": "us-west-2", "id": "d-1"},
+		{"cq_id": "b", "region": "us-east-1", "id": "d-2"},
+		{"cq_id": "b", "region": "us-east-1", "id": "d-3"},
+		{"cq_id": "b", "region": "us-east-1", "id": "d-4"},
+	}))
+	assert.Empty(t, cqIDCollisions(rel, []string{"region", "id"}, nil))
+}
+
+// scriptedQuerier answers every query with the rows returned by answer, recording all queries and their arguments
+type scriptedQuerier struct {
+	answer  func(query string) *bufferedRows
+	queries []string
+	args    [][]interface{}
+}
+
+func (q *scriptedQuerier) Query(_ context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	q.queries = append(q.queries, query)
+	q.args = append(q.args, args)
+	return q.answer(query), nil
+}
+
+// singleValueRows returns rows of a single column holding value in text format
+func singleValueRows(name string, oid uint32, value string) *bufferedRows {
+	return &bufferedRows{
+		connInfo: pgtype.NewConnInfo(),
+		fields:   []pgproto3.FieldDescription{{Name: []byte(name), DataTypeOID: oid, Format: pgx.TextFormatCode}},
+		values:   [][][]byte{{[]byte(value)}},
+		current:  -1,
+	}
+}
+
+func TestVerifyNoEmptyColumns_HeavyColumns(t *testing.T) {
+	conn := &scriptedQuerier{answer: func(query string) *bufferedRows {
+		if strings.HasPrefix(query, "SELECT count(*)") {
+			return singleValueRows("count", pgtype.Int8OID, "1")
+		}
+		return singleValueRows("json_agg", pgtype.JSONOID, `[{"cq_id": "a", "cq_meta": {}, "id": "i-1", "instance_cq_id": "p"}]`)
+	}}
+	table := &schema.Table{
+		Name: "test_instances",
+		Columns: []schema.Column{
+			{Name: "id", Type: schema.TypeString},
+			{Name: "document", Type: schema.TypeJSON},
+			{Name: "tags", Type: schema.TypeStringArray},
+		},
+		Relations: []*schema.Table{{
+			Name: "test_instance_disks",
+			Columns: []schema.Column{
+				{Name: "instance_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver},
+				{Name: "id", Type: schema.TypeString},
+			},
+		}},
+	}
+	verifyNoEmptyColumns(t, table, conn, false, []string{"document", "tags"}, []string{"tags"}, nil, 0)
+	// heavy columns are left out of the json_agg and counted instead, relations without any are aggregated whole
+	assert.Equal(t, []string{
+		`SELECT json_agg(light) FROM (SELECT "cq_id", "cq_meta", "id" FROM "test_instances") AS light`,
+		`SELECT count(*) FILTER (WHERE "document" IS NOT NULL) FROM "test_instances"`,
+		`SELECT count(*) FILTER (WHERE cardinality("tags") > 0) FROM "test_instances"`,
+		`SELECT json_agg(test_instance_disks) FROM test_instance_disks`,
+	}, conn.queries)
+}
+
+func TestEmptyColumns(t *testing.T) {
+	table := &schema.Table{
+		Name: "test_instances",
+		Columns: []schema.Column{
+			{Name: "id", Type: schema.TypeString},
+			{Name: "name", Type: schema.TypeString},
+			{Name: "tags", Type: schema.TypeStringArray},
+			{Name: "document", Type: schema.TypeJSON},
+			{Name: "policy", Type: schema.TypeJSON},
+			{Name: "legacy", Type: schema.TypeString, IgnoreInTests: true},
+		},
+	}
+	rows := []map[string]interface{}{
+		{"cq_id": "a", "id": "i-1", "name": nil, "tags": []interface{}{}, "legacy": nil},
+		{"cq_id": "b", "id": "i-2", "name": nil, "tags": []interface{}{}, "legacy": nil},
+	}
+	populatedHeavy := map[string]bool{"document": true, "policy": false}
+	assert.Equal(t, []string{"name", "policy"}, emptyColumns(table, rows, populatedHeavy, false, nil))
+	// empty arrays of columns in nonEmptyArrays are empty values, IgnoreInTests columns are verified once skipping is off
+	assert.Equal(t, []string{"legacy", "name", "policy", "tags"}, emptyColumns(table, rows, populatedHeavy, true, []string{"tags"}))
+
+	rows[1]["name"] = "web"
+	populatedHeavy["policy"] = true
+	assert.Empty(t, emptyColumns(table, rows, populatedHeavy, false, nil))
+}
+
+func TestBooleanVarietyVerifier(t *testing.T) {
+	conn := &scriptedQuerier{answer: func(string) *bufferedRows {
+		return &bufferedRows{
+			connInfo: pgtype.NewConnInfo(),
+			fields:   []pgproto3.FieldDescription{{Name: []byte("enabled"), DataTypeOID: pgtype.BoolOID, Format: pgx.TextFormatCode}},
+			values:   [][][]byte{{[]byte("f")}, {[]byte("t")}},
+			current:  -1,
+		}
+	}}
+	enabled := schema.Column{Name: "enabled", Type: schema.TypeBool}
+	table := &schema.Table{
+		Name:    "test_instances",
+		Columns: []schema.Column{enabled},
+		Relations: []*schema.Table{
+			{Name: "test_instance_disks", Columns: []schema.Column{enabled}},
+			{Name: "test_instance_tags", Columns: []schema.Column{{Name: "key", Type: schema.TypeString}}},
+		},
+	}
+	// tables allowed to be constant aren't queried
+	BooleanVarietyVerifier("enabled", "test_instance_disks")(t, table, conn, false)
+	assert.Equal(t, []string{`SELECT DISTINCT "enabled" FROM "test_instances" WHERE "enabled" IS NOT NULL`}, conn.queries)
+
+	assert.Equal(t, "is false in every row", constantBoolean([]bool{false}))
+	assert.Equal(t, "is true in every row", constantBoolean([]bool{true}))
+	assert.Empty(t, constantBoolean([]bool{false, true}))
+	assert.Empty(t, constantBoolean(nil))
+}
+
+func TestNoEmptyColumnsVerifierWhere(t *testing.T) {
+	conn := &scriptedQuerier{answer: func(string) *bufferedRows {
+		return singleValueRows("json_agg", pgtype.JSONOID, `[{"cq_id": "a", "cq_meta": {}, "account_id": "111", "parent_cq_id": "p"}]`)
+	}}
+	parentID := schema.Column{Name: "parent_cq_id", Type: schema.TypeUUID, Resolver: schema.ParentIdResolver}
+	table := &schema.Table{
+		Name:    "test_accounts",
+		Columns: []schema.Column{{Name: "account_id", Type: schema.TypeString}},
+		Relations: []*schema.Table{{
+			Name:      "test_account_users",
+			Columns:   []schema.Column{parentID},
+			Relations: []*schema.Table{{Name: "test_account_user_keys", Columns: []schema.Column{parentID}}},
+		}},
+	}
+	NoEmptyColumnsVerifierWhere(sq.Eq{"account_id": "111"})(t, table, conn, false)
+	// relations are scoped to the rows descending from the filtered rows of the main table
+	assert.Equal(t, []string{
+		`SELECT json_agg(test_accounts) FROM test_accounts WHERE account_id = $1`,
+		`SELECT json_agg(test_account_users) FROM test_account_users WHERE "parent_cq_id" IN (SELECT cq_id FROM "test_accounts" WHERE account_id = $1)`,
+		`SELECT json_agg(test_account_user_keys) FROM test_account_user_keys WHERE "parent_cq_id" IN (SELECT cq_id FROM "test_account_users" WHERE "parent_cq_id" IN (SELECT cq_id FROM "test_accounts" WHERE account_id = $1))`,
+	}, conn.queries)
+	assert.Equal(t, [][]interface{}{{"111"}, {"111"}, {"111"}}, conn.args)
+}
@@ -2,7 +2,6 @@ package testing
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
 	"github.com/cloudquery/cq-provider-sdk/provider"
@@ -21,7 +20,7 @@ type ViewTestCase struct {
 func HelperTestView(t *testing.T, resource ViewTestCase) {
 	t.Helper()
 
-	conn, err := setupDatabase()
+	conn, err := setupDatabase(getEnv("DATABASE_URL", defaultDatabaseURL))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -30,10 +29,8 @@ func HelperTestView(t *testing.T, resource ViewTestCase) {
 	l.SetLevel(hclog.Info)
 	resource.Provider.Logger = l
 
-	for _, table := range resource.Provider.ResourceMap {
-		if err := dropAndCreateTable(context.Background(), conn, table); err != nil {
-			assert.FailNow(t, fmt.Sprintf("failed to create tables %s", table.Name), err)
-		}
+	if err := dropAndCreateTables(context.Background(), conn, "", providerTables(resource.Provider), nil); err != nil {
+		assert.FailNow(t, "failed to create tables", err)
 	}
 
 	if err := conn.Exec(context.Background(), resource.SQLView); err != nil {